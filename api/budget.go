@@ -0,0 +1,223 @@
+package api
+
+import (
+	"fmt"
+	"launchbot/config"
+	"launchbot/utils"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hako/durafmt"
+	"github.com/rs/zerolog/log"
+)
+
+// RequestBudget keeps track of API requests made during the trailing hour, so that
+// scheduled updates can be delayed before the API starts throttling us. Capacity for
+// one update is always reserved for the refresh done before notifications are sent.
+type RequestBudget struct {
+	Limit          int         // Requests allowed per hour
+	Reserved       int         // Requests reserved for pre-notification refreshes
+	Requests       []time.Time // Times of requests made during the trailing hour
+	ThrottledUntil time.Time   // Set when the API responds with a 429
+	Throttles      int         // Count of 429 responses received
+	Clock          utils.Clock // Source of the current time (defaults to the system clock)
+	Mutex          sync.Mutex
+}
+
+// Returned by the LL2 source when a request is throttled
+type RateLimitError struct {
+	RetryAfter time.Duration // How long to wait until requests are allowed again
+}
+
+func (err *RateLimitError) Error() string {
+	return fmt.Sprintf("Request throttled, retry after %s", durafmt.Parse(err.RetryAfter).LimitFirstN(2))
+}
+
+// The request budget used by the updater
+var budget *RequestBudget
+
+// Matches LL2's throttle message, e.g. "Expected available in 1234 seconds."
+var retryAfterRegex = regexp.MustCompile(`available in (\d+) seconds`)
+
+// Initializes the request budget for the session's launch source, and exposes it to the bot
+func InitializeBudget(session *config.Session) *RequestBudget {
+	// Always reserve enough requests for one full update
+	budget = NewRequestBudget(session.Config.ApiRateLimit, launchSource(session).RequestCost())
+
+	// Follow the session's clock, even if it is swapped out later
+	budget.Clock = session
+
+	if session.Telegram != nil {
+		session.Telegram.ApiBudget = budget
+	}
+
+	log.Debug().Msgf("Request budget initialized: %s", budget)
+	return budget
+}
+
+// Returns the request budget, initializing it if required
+func requestBudget(session *config.Session) *RequestBudget {
+	if budget == nil {
+		return InitializeBudget(session)
+	}
+
+	return budget
+}
+
+// Creates a new request budget with an hourly limit and a reserve
+func NewRequestBudget(limit int, reserved int) *RequestBudget {
+	if reserved >= limit {
+		log.Warn().Msgf("Reserved requests (%d) exceed the hourly limit (%d): all updates are critical",
+			reserved, limit)
+	}
+
+	return &RequestBudget{Limit: limit, Reserved: reserved}
+}
+
+// Returns the current time from the budget's clock
+func (budget *RequestBudget) now() time.Time {
+	if budget.Clock == nil {
+		return time.Now()
+	}
+
+	return budget.Clock.Now()
+}
+
+// Drops requests older than an hour. Mutex must be held by the caller.
+func (budget *RequestBudget) prune(now time.Time) {
+	idx := 0
+
+	for idx < len(budget.Requests) && now.Sub(budget.Requests[idx]) >= time.Hour {
+		idx++
+	}
+
+	budget.Requests = budget.Requests[idx:]
+}
+
+// Records requests made to the API
+func (budget *RequestBudget) Record(count int) {
+	budget.Mutex.Lock()
+	defer budget.Mutex.Unlock()
+
+	now := budget.now()
+
+	for i := 0; i < count; i++ {
+		budget.Requests = append(budget.Requests, now)
+	}
+
+	budget.prune(now)
+}
+
+// Blocks all requests until retryAfter has passed
+func (budget *RequestBudget) Throttle(retryAfter time.Duration) {
+	budget.Mutex.Lock()
+	defer budget.Mutex.Unlock()
+
+	budget.Throttles++
+	budget.ThrottledUntil = budget.now().Add(retryAfter)
+
+	log.Warn().Msgf("API throttled our requests: blocking requests for %s",
+		durafmt.Parse(retryAfter).LimitFirstN(2))
+}
+
+// Returns the count of requests left for the trailing hour
+func (budget *RequestBudget) Remaining() int {
+	budget.Mutex.Lock()
+	defer budget.Mutex.Unlock()
+
+	budget.prune(budget.now())
+	return budget.Limit - len(budget.Requests)
+}
+
+/*
+Returns how long an update costing $cost requests has to wait until it fits
+in the budget. A zero duration means the update can be done now.
+
+Critical updates may use the reserved requests, while non-critical updates
+are delayed until the budget has room for them on top of the reserve.
+*/
+func (budget *RequestBudget) Delay(cost int, critical bool) time.Duration {
+	if cost == 0 {
+		// Sources without a cost are never limited
+		return 0
+	}
+
+	budget.Mutex.Lock()
+	defer budget.Mutex.Unlock()
+
+	now := budget.now()
+	budget.prune(now)
+
+	// A 429 blocks all requests, critical or not
+	if now.Before(budget.ThrottledUntil) {
+		return budget.ThrottledUntil.Sub(now)
+	}
+
+	// Requests this update needs to have available
+	needed := cost
+
+	if !critical {
+		needed += budget.Reserved
+	}
+
+	available := budget.Limit - len(budget.Requests)
+
+	if available >= needed {
+		return 0
+	}
+
+	// Wait until enough of the oldest requests have aged out of the window
+	expiring := needed - available
+
+	if expiring > len(budget.Requests) {
+		// The update can never fit in the budget: retry in an hour
+		return time.Hour
+	}
+
+	return budget.Requests[expiring-1].Add(time.Hour).Sub(now)
+}
+
+// Returns a human-readable summary of the budget's state
+func (budget *RequestBudget) String() string {
+	remaining := budget.Remaining()
+
+	budget.Mutex.Lock()
+	defer budget.Mutex.Unlock()
+
+	text := fmt.Sprintf("%d/%d requests left (%d reserved, %d throttled)",
+		remaining, budget.Limit, budget.Reserved, budget.Throttles)
+
+	if now := budget.now(); now.Before(budget.ThrottledUntil) {
+		text += fmt.Sprintf(", blocked for %s",
+			durafmt.Parse(budget.ThrottledUntil.Sub(now)).LimitFirstN(2))
+	}
+
+	return text
+}
+
+// Parses the time to wait from a 429 response, using the Retry-After header or the response body
+func parseRetryAfter(header http.Header, body []byte) time.Duration {
+	if retryAfter := header.Get("Retry-After"); retryAfter != "" {
+		// Retry-After is either a count of seconds, or an HTTP-date
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+
+		if date, err := http.ParseTime(retryAfter); err == nil {
+			return time.Until(date)
+		}
+	}
+
+	// Fall back to LL2's throttle message
+	if match := retryAfterRegex.FindSubmatch(body); match != nil {
+		seconds, _ := strconv.Atoi(string(match[1]))
+		return time.Duration(seconds) * time.Second
+	}
+
+	// No information available: wait for a reasonable amount of time
+	log.Warn().Msgf("Unable to parse Retry-After from a throttled response: defaulting to 15 minutes")
+	return 15 * time.Minute
+}
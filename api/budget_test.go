@@ -0,0 +1,84 @@
+package api
+
+import (
+	"launchbot/utils"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Tests that non-critical updates leave the reserve untouched, while critical updates may use it
+func TestRequestBudgetDelay(t *testing.T) {
+	budget := NewRequestBudget(5, 2)
+
+	// Two requests made 50 minutes ago, one made 10 minutes ago
+	budget.Requests = []time.Time{
+		time.Now().Add(-50 * time.Minute), time.Now().Add(-50 * time.Minute),
+		time.Now().Add(-10 * time.Minute),
+	}
+
+	if delay := budget.Delay(0, false); delay != 0 {
+		t.Errorf("expected free sources to never be delayed, got %s", delay)
+	}
+
+	if delay := budget.Delay(1, true); delay != 0 {
+		t.Errorf("expected a critical update to use the reserve, got delay=%s", delay)
+	}
+
+	// Non-critical update needs 1+2 requests, but only 2 are available: wait for the oldest to expire
+	delay := budget.Delay(1, false)
+
+	if delay < 9*time.Minute || delay > 10*time.Minute {
+		t.Errorf("expected a non-critical update to be delayed by ~10 minutes, got %s", delay)
+	}
+
+	// A 429 blocks even critical updates
+	budget.Throttle(30 * time.Minute)
+
+	if delay := budget.Delay(1, true); delay < 29*time.Minute {
+		t.Errorf("expected a throttled budget to block critical updates, got delay=%s", delay)
+	}
+
+	// Recorded requests count against the budget
+	budget.Record(1)
+
+	if remaining := budget.Remaining(); remaining != 1 {
+		t.Errorf("expected 1 request remaining, got %d", remaining)
+	}
+}
+
+// Tests that the budget follows its clock, freeing requests and throttles as it advances
+func TestRequestBudgetClock(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now())
+	budget := NewRequestBudget(3, 1)
+	budget.Clock = clock
+
+	budget.Record(3)
+	budget.Throttle(10 * time.Minute)
+
+	if delay := budget.Delay(1, true); delay != 10*time.Minute {
+		t.Errorf("expected a delay of 10 minutes, got %s", delay)
+	}
+
+	// After an hour, the throttle has passed and the requests have aged out
+	clock.Advance(time.Hour)
+
+	if delay := budget.Delay(1, false); delay != 0 || budget.Remaining() != 3 {
+		t.Errorf("expected a full budget, got delay=%s with %d remaining", delay, budget.Remaining())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "120")
+
+	if retryAfter := parseRetryAfter(header, nil); retryAfter != 2*time.Minute {
+		t.Errorf("expected 2 minutes from the header, got %s", retryAfter)
+	}
+
+	body := []byte(`{"detail":"Request was throttled. Expected available in 1234 seconds."}`)
+
+	if retryAfter := parseRetryAfter(http.Header{}, body); retryAfter != 1234*time.Second {
+		t.Errorf("expected 1234 seconds from the body, got %s", retryAfter)
+	}
+}
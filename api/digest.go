@@ -0,0 +1,121 @@
+package api
+
+import (
+	"launchbot/config"
+	"launchbot/sendables"
+	"time"
+
+	"github.com/go-co-op/gocron"
+	"github.com/rs/zerolog/log"
+	tb "gopkg.in/telebot.v3"
+)
+
+/*
+Digests are delivered at a local hour chosen by each chat. Instead of a job per
+chat, chats are bucketed by their time zone: each time zone with digests enabled
+has one hourly job, running at the top of every local hour, which sends the digests
+of the chats in that time zone whose digest hour it is.
+*/
+
+// Schedules the digest jobs, and lets the bot re-schedule them when digest settings change
+func InitializeDigests(session *config.Session) {
+	if session.Telegram != nil {
+		session.Telegram.RescheduleDigests = func() { ScheduleDigests(session) }
+	}
+
+	ScheduleDigests(session)
+}
+
+// Creates an hourly digest job for each time zone chats with digests are in, and
+// removes the jobs of time zones no chat has digests enabled in anymore.
+func ScheduleDigests(session *config.Session) {
+	if session.Scheduler == nil {
+		log.Warn().Msg("No scheduler available: not scheduling digests")
+		return
+	}
+
+	zones := session.Db.DigestTimeZones("tg")
+
+	session.Mutex.Lock()
+	defer session.Mutex.Unlock()
+
+	if session.DigestJobs == nil {
+		session.DigestJobs = make(map[string]*gocron.Job)
+	}
+
+	active := make(map[string]bool)
+
+	for _, zone := range zones {
+		active[zone] = true
+
+		if _, ok := session.DigestJobs[zone]; ok {
+			continue
+		}
+
+		location, err := time.LoadLocation(zone)
+
+		if err != nil {
+			log.Error().Err(err).Msgf("Loading time zone=%s for digests failed", zone)
+			continue
+		}
+
+		// The next top of the hour in the time zone, as zones may be offset by e.g. 30 minutes
+		local := session.Now().In(location)
+		nextHour := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), 0, 0, 0, location).Add(time.Hour)
+
+		job, err := session.Scheduler.Every(1).Hour().StartAt(session.SchedulerTime(nextHour)).Do(SendDigests, session, zone)
+
+		if err != nil {
+			log.Error().Err(err).Msgf("Scheduling digests for time zone=%s failed", zone)
+			continue
+		}
+
+		session.DigestJobs[zone] = job
+		log.Debug().Msgf("Scheduled hourly digests for time zone=%s, starting at %s", zone, nextHour)
+	}
+
+	for zone, job := range session.DigestJobs {
+		if !active[zone] {
+			session.Scheduler.RemoveByReference(job)
+			delete(session.DigestJobs, zone)
+			log.Debug().Msgf("Removed digest job of time zone=%s", zone)
+		}
+	}
+
+	log.Info().Msgf("Digests scheduled for %d time zone(s)", len(session.DigestJobs))
+}
+
+// Sends the digests of the chats in a time zone whose digest is due this hour
+func SendDigests(session *config.Session, zone string) {
+	now := session.Now()
+	sent := 0
+
+	for _, chat := range session.Db.DigestChats(zone, "tg") {
+		if !chat.DigestDue(now) {
+			continue
+		}
+
+		msg := sendables.Message{
+			TextContent: session.Cache.DigestMessage(chat, now, session.Telegram.Username),
+			SendOptions: tb.SendOptions{
+				ParseMode:             "MarkdownV2",
+				DisableWebPagePreview: true,
+			},
+		}
+
+		// Digests are not about a single launch, so they have no launch ID
+		sendable := sendables.Sendable{
+			Type:             sendables.Notification,
+			NotificationType: "digest",
+			Message:          &msg,
+		}
+
+		sendable.AddRecipient(chat, false)
+		session.Telegram.Enqueue(&sendable, false)
+		sent++
+	}
+
+	if sent != 0 {
+		log.Info().Msgf("Sent %d digest(s) to chats in time zone=%s", sent, zone)
+	}
+}
@@ -0,0 +1,91 @@
+// Package ll2test provides a fake LL2 API server, serving a configurable list of
+// upcoming launches for offline tests.
+package ll2test
+
+import (
+	"encoding/json"
+	"fmt"
+	"launchbot/db"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server is a fake LL2 API server. Point an LL2 source's endpoint at its URL.
+type Server struct {
+	*httptest.Server
+	launches []*db.Launch
+	requests int
+	Mutex    sync.Mutex
+}
+
+// Starts a new fake LL2 server. Close it when done.
+func NewServer() *Server {
+	server := &Server{}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+	return server
+}
+
+// Sets the launches served by the upcoming-endpoint, in NET order
+func (server *Server) SetLaunches(launches ...*db.Launch) {
+	server.Mutex.Lock()
+	defer server.Mutex.Unlock()
+
+	server.launches = launches
+}
+
+// Returns the count of requests served
+func (server *Server) Requests() int {
+	server.Mutex.Lock()
+	defer server.Mutex.Unlock()
+
+	return server.requests
+}
+
+// Serves a page of upcoming launches, paginated with limit and offset like LL2
+func (server *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "launch/upcoming") {
+		http.NotFound(w, r)
+		return
+	}
+
+	server.Mutex.Lock()
+	defer server.Mutex.Unlock()
+
+	server.requests++
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	// Slice the requested page
+	start, end := offset, offset+limit
+
+	if start > len(server.launches) {
+		start = len(server.launches)
+	}
+
+	if end > len(server.launches) {
+		end = len(server.launches)
+	}
+
+	// Link to the next page, if there are more launches
+	var next interface{}
+
+	if end < len(server.launches) {
+		next = fmt.Sprintf("%s%s?mode=detailed&limit=%d&offset=%d", server.URL, r.URL.Path, limit, end)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":   len(server.launches),
+		"next":    next,
+		"results": server.launches[start:end],
+	})
+}
@@ -34,63 +34,66 @@ func getHighestPriorityVideoLink(links []db.ContentURL) *db.ContentURL {
 	return &links[highestPriorityIndex]
 }
 
-// Parses the launcher info we receive from the API into something more digestible
+// Parses the launcher info we receive from the API into something more digestible.
+// Launch vehicles may have any number of stages, e.g. a core and several side boosters.
 func parseLauncherInfo(launch *db.Launch) {
-	launch.Rocket.Launchers.Count = len(launch.Rocket.UnparsedLauncherInfo)
-	launch.Rocket.Launchers.Core = db.Launcher{}
-
-	// Ugly init for boosters
-	launch.Rocket.Launchers.Booster1 = db.Launcher{}
-	launch.Rocket.Launchers.Booster2 = db.Launcher{}
-	boosterCount := 0
-
-	for _, launcher := range launch.Rocket.UnparsedLauncherInfo {
-		if strings.ToLower(launcher.Type) == "core" {
-			// This init is ugly, but we can't use same types due to JSON/Gorm funkiness
-			launch.Rocket.Launchers.Core = db.Launcher{
-				Serial:          launcher.Detailed.Serial,
-				Reused:          launcher.Reused,
-				FlightNumber:    launcher.FlightNumber,
-				Flights:         launcher.Detailed.Flights,
-				FirstLaunchDate: launcher.Detailed.FirstLaunchDate,
-				LastLaunchData:  launcher.Detailed.LastLaunchDate,
-				LandingAttempt:  launcher.Landing.Attempt,
-				LandingSuccess:  launcher.Landing.Success,
-				LandingLocation: launcher.Landing.Location,
-				LandingType:     launcher.Landing.Type,
-			}
-		} else {
-			if boosterCount == 0 {
-				launch.Rocket.Launchers.Booster1 = db.Launcher{
-					Serial:          launcher.Detailed.Serial,
-					Reused:          launcher.Reused,
-					FlightNumber:    launcher.FlightNumber,
-					Flights:         launcher.Detailed.Flights,
-					FirstLaunchDate: launcher.Detailed.FirstLaunchDate,
-					LastLaunchData:  launcher.Detailed.LastLaunchDate,
-					LandingAttempt:  launcher.Landing.Attempt,
-					LandingSuccess:  launcher.Landing.Success,
-					LandingLocation: launcher.Landing.Location,
-					LandingType:     launcher.Landing.Type,
-				}
-			} else if boosterCount == 1 {
-				launch.Rocket.Launchers.Booster2 = db.Launcher{
-					Serial:          launcher.Detailed.Serial,
-					Reused:          launcher.Reused,
-					FlightNumber:    launcher.FlightNumber,
-					Flights:         launcher.Detailed.Flights,
-					FirstLaunchDate: launcher.Detailed.FirstLaunchDate,
-					LastLaunchData:  launcher.Detailed.LastLaunchDate,
-					LandingAttempt:  launcher.Landing.Attempt,
-					LandingSuccess:  launcher.Landing.Success,
-					LandingLocation: launcher.Landing.Location,
-					LandingType:     launcher.Landing.Type,
-				}
-			} else {
-				log.Warn().Msgf("Booster-count reached %d", boosterCount)
+	launch.Rocket.Stages = make([]db.Launcher, 0, len(launch.Rocket.UnparsedLauncherInfo))
+
+	for i, launcher := range launch.Rocket.UnparsedLauncherInfo {
+		launch.Rocket.Stages = append(launch.Rocket.Stages, db.Launcher{
+			LaunchId:        launch.Id,
+			Position:        i,
+			Role:            launcher.Type,
+			Serial:          launcher.Detailed.Serial,
+			Reused:          launcher.Reused,
+			FlightNumber:    launcher.FlightNumber,
+			Flights:         launcher.Detailed.Flights,
+			FirstLaunchDate: launcher.Detailed.FirstLaunchDate,
+			LastLaunchData:  launcher.Detailed.LastLaunchDate,
+			LandingAttempt:  launcher.Landing.Attempt,
+			LandingSuccess:  launcher.Landing.Success,
+			LandingLocation: launcher.Landing.Location,
+			LandingType:     launcher.Landing.Type,
+		})
+	}
+}
+
+// Parses the launch, onboard and landing crews of the spacecraft into a single crew list,
+// with each crew ordered by the seniority of their role
+func parseCrew(launch *db.Launch) {
+	stage := &launch.Rocket.SpacecraftStage
+	stage.Crew = []db.CrewMember{}
+
+	crews := []struct {
+		name        string
+		assignments []db.CrewAssignment
+	}{
+		{"launch", stage.LaunchCrew}, {"onboard", stage.OnboardCrew}, {"landing", stage.LandingCrew},
+	}
+
+	for _, crew := range crews {
+		assignments := crew.assignments
+
+		sort.SliceStable(assignments, func(i, j int) bool {
+			return assignments[i].Role.Priority < assignments[j].Role.Priority
+		})
+
+		for _, assignment := range assignments {
+			// Prefer the agency's abbreviation, e.g. "NASA"
+			agency := assignment.Astronaut.Agency.Abbrev
+
+			if agency == "" {
+				agency = assignment.Astronaut.Agency.Name
 			}
 
-			boosterCount++
+			stage.Crew = append(stage.Crew, db.CrewMember{
+				Name:        assignment.Astronaut.Name,
+				Role:        assignment.Role.Role,
+				Agency:      agency,
+				Nationality: assignment.Astronaut.Nationality,
+				Flights:     assignment.Astronaut.FlightsCount,
+				Stage:       crew.name,
+			})
 		}
 	}
 }
@@ -123,7 +126,7 @@ func netParser(cache *db.Cache, freshLaunch *db.Launch) (bool, db.Postpone) {
 		}
 
 		// Check if this postponement resets any notification states
-		anyReset, resetStates := cacheLaunch.AnyStatesResetByNetSlip(netSlip)
+		anyReset, resetStates := cacheLaunch.AnyStatesResetByNetSlip(netSlip, cache.Now())
 
 		if anyReset {
 			// Launch had one or more notification states reset: all handled behind the scenes.
@@ -138,6 +141,48 @@ func netParser(cache *db.Cache, freshLaunch *db.Launch) (bool, db.Postpone) {
 	return false, db.Postpone{}
 }
 
+// Checks if a launch reached an outcome (success, failure, partial failure) since the last update.
+// Only launches that had a final notification (e.g. the 5-minute one) sent are considered.
+func outcomeParser(cache *db.Cache, freshLaunch *db.Launch) bool {
+	if !freshLaunch.Launched {
+		return false
+	}
+
+	// Launched launches are dropped from the cache, so an outcome is only ever seen once
+	cacheLaunch, ok := cache.LaunchMap[freshLaunch.Id]
+
+	if !ok || cacheLaunch.Launched {
+		return false
+	}
+
+	if !cacheLaunch.NotificationState.FinalNotificationSent() {
+		log.Debug().Msgf("Launch reached an outcome, but no final notification was sent (%s)",
+			freshLaunch.Slug)
+		return false
+	}
+
+	log.Debug().Msgf("Launch reached an outcome with status=%s (%s)", freshLaunch.Status.Abbrev, freshLaunch.Slug)
+	return true
+}
+
+// Detects changes in a launch since the last update, by comparing it to the cached version
+func changeParser(cache *db.Cache, freshLaunch *db.Launch) []*db.ChangeEvent {
+	cacheLaunch, ok := cache.LaunchMap[freshLaunch.Id]
+
+	if !ok {
+		// New launches have nothing to compare against
+		return nil
+	}
+
+	events := db.DiffLaunches(cacheLaunch, freshLaunch, cache.Now())
+
+	for _, event := range events {
+		log.Debug().Msgf("Detected change in launch=%s: %s", freshLaunch.Slug, event)
+	}
+
+	return events
+}
+
 // Process a single launch; function is run concurrently.
 func processLaunch(launch *db.Launch, update *db.LaunchUpdate, idx int, cache *db.Cache, wg *sync.WaitGroup) {
 	// Parse the datetime string as RFC3339 into a time.Time object in UTC
@@ -191,17 +236,24 @@ func processLaunch(launch *db.Launch, update *db.LaunchUpdate, idx int, cache *d
 	highestPriorityUrl := getHighestPriorityVideoLink(launch.VidURL)
 	launch.WebcastLink = highestPriorityUrl.Url
 
-	// If booster/launcher information, parse it
-	// TODO implement for multiple boosters (e.g. Falcon Heavy)
-	if len(launch.Rocket.UnparsedLauncherInfo) != 0 {
-		parseLauncherInfo(launch)
-	} else {
-		launch.Rocket.Launchers = db.Launchers{Count: 0}
-	}
+	// Add the provider to the registry, if it has not been seen before
+	db.Providers.Register(&launch.LaunchProvider)
+
+	// Parse booster/launcher information, if any
+	parseLauncherInfo(launch)
+
+	// Parse the crew, if this is a crewed flight
+	parseCrew(launch)
 
 	// If launch slipped enough to reset a notification state, save it
 	wasPostponed, postponeStatus := netParser(cache, launch)
 
+	// If launch reached an outcome since the last update, save it
+	hasOutcome := outcomeParser(cache, launch)
+
+	// Detect any other changes since the last update
+	events := changeParser(cache, launch)
+
 	// Lock mutex so we can save the launch
 	update.Mutex.Lock()
 	defer update.Mutex.Unlock()
@@ -211,6 +263,12 @@ func processLaunch(launch *db.Launch, update *db.LaunchUpdate, idx int, cache *d
 		update.Postponed[launch] = postponeStatus
 	}
 
+	if hasOutcome {
+		update.Outcomes = append(update.Outcomes, launch)
+	}
+
+	update.Events = append(update.Events, events...)
+
 	// Update launch in launchUpdate (Mutex is locked so this is thread-safe)
 	update.Launches[idx] = launch
 
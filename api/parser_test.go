@@ -1,18 +1,20 @@
 package api
 
 import (
+	"encoding/json"
+	"launchbot/config"
 	"launchbot/db"
 	"launchbot/users"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
-func MoveNET(cache *db.Cache, fakeOrigNet int64, fakeNewNet int64, fakeNotifStates map[string]bool) *db.Launch {
+func MoveNET(cache *db.Cache, fakeOrigNet int64, fakeNewNet int64, fakeNotifStates map[int]bool) *db.Launch {
 	/* Create a fake launch using the cache (deref)
 	This is the "old" launch that the net will be compared against */
 	launch := *cache.Launches[0]
@@ -25,9 +27,8 @@ func MoveNET(cache *db.Cache, fakeOrigNet int64, fakeNewNet int64, fakeNotifStat
 	cache.Launches[0] = &launch
 	cache.LaunchMap[launch.Id] = &launch
 
-	// Modify notification send-states, update the bool-flags
-	launch.NotificationState.Map = fakeNotifStates
-	launch.NotificationState.UpdateFlags(&launch)
+	// Modify notification send-states, keyed by lead time
+	launch.NotificationState.Sent = fakeNotifStates
 
 	// Create the fake "fresh" launch, move NET so that it has been postponed
 	freshLaunch := launch
@@ -38,9 +39,7 @@ func MoveNET(cache *db.Cache, fakeOrigNet int64, fakeNewNet int64, fakeNotifStat
 
 // Initializes the cache and database with data from the LL2 dev endpoint
 func initDevDatabase(cache *db.Cache) error {
-	client := resty.New()
-	client.SetTimeout(time.Duration(30 * time.Second))
-	update, err := apiCall(client, true)
+	update, err := NewLL2Source("launchbot-tests", true, config.Horizon{Launches: 30}).Fetch()
 	if err != nil {
 		return err
 	}
@@ -83,7 +82,7 @@ func TestPostponeFunctions(t *testing.T) {
 	/////////////////////////////////////////////////////////////////////////////
 	// Test a postpone out of the [24h...12h] window into the pre-24h window
 	log.Info().Msg("postpone out of the [24h...12h] window into the pre-24h window //////////")
-	modifiedMap := map[string]bool{"Sent24h": true, "Sent12h": false, "Sent1h": false, "Sent5min": false}
+	modifiedMap := map[int]bool{1440: true, 720: false, 60: false, 5: false}
 	origNet := time.Now().Unix() + 12*3600
 	newNet := time.Now().Unix() + 26*3600
 	postponedLaunch := MoveNET(cache, origNet, newNet, modifiedMap)
@@ -102,7 +101,7 @@ func TestPostponeFunctions(t *testing.T) {
 	/////////////////////////////////////////////////////////////////////////////
 	// Test a postpone out of the [5min...launch] window into the pre-24h window
 	log.Info().Msg("Postpone out of the [5min...launch] window into the pre-24h window //////////")
-	modifiedMap = map[string]bool{"Sent24h": true, "Sent12h": true, "Sent1h": true, "Sent5min": true}
+	modifiedMap = map[int]bool{1440: true, 720: true, 60: true, 5: true}
 	origNet = time.Now().Unix() + 3*60
 	newNet = time.Now().Unix() + 26*3600
 	postponedLaunch = MoveNET(cache, origNet, newNet, modifiedMap)
@@ -121,7 +120,7 @@ func TestPostponeFunctions(t *testing.T) {
 	/////////////////////////////////////////////////////////////////////////////
 	// Test a postpone that does not reset any notification states
 	log.Info().Msg("Postpone that does not reset any notification states //////////")
-	modifiedMap = map[string]bool{"Sent24h": true, "Sent12h": false, "Sent1h": false, "Sent5min": false}
+	modifiedMap = map[int]bool{1440: true, 720: false, 60: false, 5: false}
 	origNet = time.Now().Unix() + 16*3600 // Original net 16 hours from now (24h sent)
 	newNet = time.Now().Unix() + 22*3600  // New net 22 hours from now (no reset)
 	postponedLaunch = MoveNET(cache, origNet, newNet, modifiedMap)
@@ -137,3 +136,76 @@ func TestPostponeFunctions(t *testing.T) {
 		postponedLaunch.PostponeNotificationSendable(cache.Database, postpone, "tg")
 	}
 }
+
+// Tests that crew assignments are parsed, persisted, and shown in launch messages
+func TestParseCrew(t *testing.T) {
+	raw := []byte(`{
+		"id": "crewed", "name": "Falcon 9 Block 5 | Crew-9", "net": "2024-09-28T17:17:00Z",
+		"rocket": {"spacecraft_stage": {
+			"launch_crew": [
+				{"role": {"role": "Pilot", "priority": 1},
+				 "astronaut": {"name": "Aleksandr Gorbunov", "nationality": "Russian", "flights_count": 1, "agency": {"name": "Russian Federal Space Agency (ROSCOSMOS)", "abbrev": "RFSA"}}},
+				{"role": {"role": "Commander", "priority": 0},
+				 "astronaut": {"name": "Nick Hague", "nationality": "American", "flights_count": 3, "agency": {"name": "National Aeronautics and Space Administration", "abbrev": "NASA"}}}
+			],
+			"landing_crew": [
+				{"role": {"role": "Commander", "priority": 0},
+				 "astronaut": {"name": "Matthew Dominick", "flights_count": 2, "agency": {"name": "National Aeronautics and Space Administration"}}}
+			]
+		}}
+	}`)
+
+	launch := db.Launch{}
+
+	if err := json.Unmarshal(raw, &launch); err != nil {
+		t.Fatalf("unmarshaling launch failed: %v", err)
+	}
+
+	parseCrew(&launch)
+
+	crew := launch.Rocket.SpacecraftStage.Crew
+
+	if len(crew) != 3 || !launch.IsCrewed() {
+		t.Fatalf("expected a crewed launch with 3 crew members, got %#v", crew)
+	}
+
+	// Launch crew is ordered by role priority, and followed by the landing crew
+	if crew[0].Name != "Nick Hague" || crew[1].Agency != "RFSA" || crew[2].Stage != "landing" {
+		t.Errorf("unexpected crew order or contents: %#v", crew)
+	}
+
+	// Agency falls back to its full name, if no abbreviation exists
+	if crew[2].Agency != "National Aeronautics and Space Administration" {
+		t.Errorf("expected agency to fall back to its name, got %s", crew[2].Agency)
+	}
+
+	expanded := launch.MessageBodyText(true, false, time.Now())
+
+	for _, substring := range []string{"*Crew*", "*Commander* `Nick` `Hague` `(NASA,` `American,` `3` `flights)`", "*Landing crew*"} {
+		if !strings.Contains(expanded, substring) {
+			t.Errorf("expanded message is missing %q:\n%s", substring, expanded)
+		}
+	}
+
+	if compact := launch.MessageBodyText(false, false, time.Now()); !strings.Contains(compact, "*Crew* `2` `astronaut(s)`") {
+		t.Errorf("compact message is missing the crew size:\n%s", compact)
+	}
+
+	// The parsed crew survives a round-trip through the database
+	database := db.Database{}
+	database.Cache = &db.Cache{Database: &database, LaunchMap: make(map[string]*db.Launch), Users: &users.UserCache{}}
+
+	if !database.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	if err := database.Update([]*db.Launch{&launch}, true, false); err != nil {
+		t.Fatalf("saving launch failed: %v", err)
+	}
+
+	loaded, err := database.Cache.FindLaunchById("crewed")
+
+	if err != nil || len(loaded.Rocket.SpacecraftStage.Crew) != 3 {
+		t.Fatalf("expected the crew to be loaded from disk (err=%v)", err)
+	}
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"fmt"
+	"launchbot/config"
+	"launchbot/db"
+	"launchbot/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Format of the fetch timestamp in recorded file names, sortable as a string
+const recordTimeFormat = "20060102T150405.000Z"
+
+// Saves a raw LL2 response body into the record directory. Files are named
+// after the time of the fetch and the page, e.g. 20221005T120000.000Z-p00.json,
+// so that the pages of one fetch can be grouped together when replaying.
+func recordResponse(dir string, fetched time.Time, page int, body []byte) {
+	// Create the directory, if it does not exist
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		log.Error().Err(err).Msgf("Error creating record directory at %s", dir)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-p%02d.json", fetched.UTC().Format(recordTimeFormat), page)
+	err := os.WriteFile(filepath.Join(dir, filename), body, 0o644)
+
+	if err != nil {
+		log.Error().Err(err).Msgf("Error recording response to %s", filename)
+		return
+	}
+
+	log.Debug().Msgf("Recorded response to %s", filename)
+}
+
+// A recorded fetch, consisting of one or more pages
+type snapshot struct {
+	Fetched time.Time // Time of the fetch
+	Pages   []string  // Paths to the pages, in order
+}
+
+// Loads recorded snapshots from a directory, ordered by fetch time
+func loadSnapshots(dir string) ([]*snapshot, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*-p*.json"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Sorting by name orders the snapshots by time, and pages by index
+	sort.Strings(files)
+
+	snapshots := []*snapshot{}
+	snapshotMap := make(map[string]*snapshot)
+
+	for _, file := range files {
+		// Split "20221005T120000.000Z-p00.json" into a timestamp and a page
+		timestamp, _, _ := strings.Cut(filepath.Base(file), "-p")
+		fetched, err := time.Parse(recordTimeFormat, timestamp)
+
+		if err != nil {
+			log.Warn().Msgf("Skipping file with an invalid timestamp: %s", filepath.Base(file))
+			continue
+		}
+
+		snap, ok := snapshotMap[timestamp]
+
+		if !ok {
+			snap = &snapshot{Fetched: fetched}
+			snapshotMap[timestamp] = snap
+			snapshots = append(snapshots, snap)
+		}
+
+		snap.Pages = append(snap.Pages, file)
+	}
+
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("No recorded responses found in %s", dir)
+	}
+
+	return snapshots, nil
+}
+
+// Reads all pages of a snapshot into a single launch update
+func (snap *snapshot) read() (*db.LaunchUpdate, error) {
+	update := &db.LaunchUpdate{Postponed: make(map[*db.Launch]db.Postpone)}
+
+	for _, path := range snap.Pages {
+		body, err := os.ReadFile(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		page, err := unmarshalLaunchUpdate(body)
+
+		if err != nil {
+			return nil, err
+		}
+
+		update.Launches = append(update.Launches, page.Launches...)
+		update.Next = page.Next
+	}
+
+	// If the last page links to more results, the snapshot only covers a part of the manifest
+	update.Horizon = updateHorizon(update.Launches, update.Next != "", 0)
+	return update, nil
+}
+
+// Replays recorded LL2 responses through the parser and the database, in order.
+// Notifications are never sent: postpones and outcomes are only logged, together
+// with the count of chats they would have been sent to. Each snapshot is applied
+// at the time it was fetched, and never against the live database.
+func Replay(session *config.Session, dir string) error {
+	if session.Config != nil && session.Db.Path != "" {
+		liveFolder, _ := filepath.Abs(session.Config.DbFolder)
+
+		if filepath.Dir(session.Db.Path) == liveFolder {
+			return fmt.Errorf("Refusing to replay against the live database at %s", session.Db.Path)
+		}
+	}
+
+	snapshots, err := loadSnapshots(dir)
+
+	if err != nil {
+		return err
+	}
+
+	// Run the replay on a clock set to the time of each snapshot
+	clock := utils.NewFakeClock(snapshots[0].Fetched)
+	sessionClock, cacheClock := session.Clock, session.Cache.Clock
+	session.Clock, session.Cache.Clock = clock, clock
+
+	defer func() {
+		session.Clock, session.Cache.Clock = sessionClock, cacheClock
+	}()
+
+	log.Info().Msgf("Replaying %d snapshot(s) from %s", len(snapshots), dir)
+
+	for i, snap := range snapshots {
+		update, err := snap.read()
+
+		if err != nil {
+			log.Error().Err(err).Msgf("[%d] Reading snapshot from %s failed", i+1, snap.Fetched)
+			return err
+		}
+
+		log.Info().Msgf("[%d] Snapshot from %s: %d launch(es) in %d page(s)",
+			i+1, snap.Fetched.Format(time.RFC3339), len(update.Launches), len(snap.Pages))
+
+		clock.Set(snap.Fetched)
+		postponedLaunches, err := applyUpdate(session, update)
+
+		if err != nil {
+			return err
+		}
+
+		for launch, postpone := range postponedLaunches {
+			sendable := launch.PostponeNotificationSendable(session.Db, postpone, "tg")
+
+			log.Info().Msgf("[%d] ➙ Postponed by %d seconds: %s (%d recipient(s), reset states: %v)",
+				i+1, postpone.PostponedBy, launch.Slug, len(sendable.Recipients), postpone.ResetStates)
+		}
+
+		for _, launch := range update.Outcomes {
+			sendable := launch.OutcomeNotificationSendable(session.Db, "tg")
+
+			log.Info().Msgf("[%d] ➙ Outcome status=%s: %s (%d recipient(s))",
+				i+1, launch.Status.Abbrev, launch.Slug, len(sendable.Recipients))
+		}
+
+		for _, launch := range update.LiveWebcasts() {
+			sendable := launch.WebcastNotificationSendable(session.Db, "tg")
+
+			log.Info().Msgf("[%d] ➙ Webcast live: %s (%d recipient(s))",
+				i+1, launch.Slug, len(sendable.Recipients))
+		}
+
+		for _, event := range update.EventsOfType(db.StatusChange) {
+			if event.IsHold() || event.IsScrub() {
+				sendable := event.Launch.HoldNotificationSendable(session.Db, event.IsScrub(), "tg")
+
+				log.Info().Msgf("[%d] ➙ Hold or scrub (%s ➙ %s): %s (%d recipient(s))",
+					i+1, event.Old, event.New, event.Launch.Slug, len(sendable.Recipients))
+			}
+		}
+
+		for _, event := range update.Events {
+			log.Info().Msgf("[%d] ➙ Change %s", i+1, event)
+		}
+
+		// Log the next notification, as it would be scheduled after this snapshot
+		notification := session.Cache.FindNextNotification()
+
+		if notification.SendTime != 0 {
+			log.Info().Msgf("[%d] ➙ Next notification: type=%s for %s at %s",
+				i+1, notification.Type, notification.LaunchName,
+				time.Unix(notification.SendTime, 0).UTC().Format(time.RFC3339))
+		}
+	}
+
+	log.Info().Msgf("Replay finished: %d launch(es) in the cache", len(session.Cache.Launches))
+	return nil
+}
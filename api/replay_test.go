@@ -0,0 +1,96 @@
+package api
+
+import (
+	"fmt"
+	"launchbot/config"
+	"launchbot/db"
+	"launchbot/users"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Tests that recorded responses are grouped into snapshots, and replayed in order
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	net := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+
+	page := func(next string, ids ...string) []byte {
+		results := ""
+
+		for i, id := range ids {
+			if i > 0 {
+				results += ","
+			}
+
+			results += fmt.Sprintf(`{"id": "%s", "name": "Launch %s", "net": "%s", "status": {"id": 8, "abbrev": "TBC"}}`,
+				id, id, net.Format(time.RFC3339))
+		}
+
+		return []byte(fmt.Sprintf(`{"next": "%s", "results": [%s]}`, next, results))
+	}
+
+	// First snapshot is paginated, while the second one consists of a single page
+	first := time.Now().Add(-time.Hour)
+	recordResponse(dir, first, 0, page("next-page", "alpha"))
+	recordResponse(dir, first, 1, page("", "bravo"))
+	recordResponse(dir, first.Add(15*time.Minute), 0, page("", "alpha", "bravo", "charlie"))
+
+	snapshots, err := loadSnapshots(dir)
+
+	if err != nil {
+		t.Fatalf("loading snapshots failed: %v", err)
+	}
+
+	if len(snapshots) != 2 || len(snapshots[0].Pages) != 2 || len(snapshots[1].Pages) != 1 {
+		t.Fatalf("expected snapshots with 2 and 1 page(s), got %d snapshot(s)", len(snapshots))
+	}
+
+	update, err := snapshots[0].read()
+
+	if err != nil {
+		t.Fatalf("reading snapshot failed: %v", err)
+	}
+
+	if len(update.Launches) != 2 || update.Horizon != 0 {
+		t.Errorf("expected 2 launches without a horizon, got %d with horizon=%d",
+			len(update.Launches), update.Horizon)
+	}
+
+	// Replay both snapshots against an empty database
+	session := &config.Session{}
+	session.Cache = &db.Cache{LaunchMap: make(map[string]*db.Launch), Users: &users.UserCache{}}
+	session.Db = &db.Database{Cache: session.Cache}
+	session.Cache.Database = session.Db
+
+	if !session.Db.Open(t.TempDir()) {
+		t.Fatal("failed to open database")
+	}
+
+	if err := Replay(session, dir); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+
+	if len(session.Cache.Launches) != 3 {
+		t.Errorf("expected 3 launches in the cache after replay, got %d", len(session.Cache.Launches))
+	}
+
+	// The last snapshot was applied at the time it was fetched
+	if fetched := snapshots[1].Fetched; !session.Cache.Updated.Equal(fetched) {
+		t.Errorf("expected the cache to be updated at %s, got %s", fetched, session.Cache.Updated)
+	}
+
+	// The live database is never replayed against
+	session.Config = &config.Config{DbFolder: filepath.Dir(session.Db.Path)}
+
+	if err := Replay(session, dir); err == nil {
+		t.Errorf("expected an error when replaying against the live database")
+	}
+
+	session.Config = nil
+
+	// A directory without recordings cannot be replayed
+	if err := Replay(session, t.TempDir()); err == nil {
+		t.Errorf("expected an error for a directory without recordings")
+	}
+}
@@ -1,10 +1,10 @@
 package api
 
 import (
-	"fmt"
 	"launchbot/config"
 	"launchbot/db"
 	"launchbot/sendables"
+	"launchbot/users"
 	"time"
 
 	"github.com/hako/durafmt"
@@ -18,13 +18,14 @@ type PostLaunch struct {
 	LaunchId string
 }
 
-// Notify creates a notification sendable
-func Notify(launch *db.Launch, database *db.Database, username string) *sendables.Sendable {
+// Notify creates a notification sendable. The chats with the notification's lead time
+// enabled can be passed as candidates, or nil to load them.
+func Notify(launch *db.Launch, database *db.Database, username string, candidates []*users.User) *sendables.Sendable {
 	// Pull the notification type we are sending (could be e.g. cached)
 	notification := launch.NextNotification(database)
 
 	// Text content of the notification
-	text := launch.NotificationMessage(notification.Type, false, username)
+	text := launch.NotificationMessage(notification.Type, false, username, database.Now())
 	kb := launch.TelegramNotificationKeyboard(notification.Type)
 
 	// FUTURE make launch.NotificationMessage produce sendables for multiple platforms
@@ -39,12 +40,18 @@ func Notify(launch *db.Launch, database *db.Database, username string) *sendable
 			ParseMode:   "MarkdownV2",
 			ReplyMarkup: &tb.ReplyMarkup{InlineKeyboard: kb},
 		},
+		WebcastLink: launch.WebcastLink,
+		Webcasts:    launch.Webcasts(),
 	}
 
 	// Get list of recipients
 	platform := "tg"
-	log.Debug().Msgf("Calling NotificationRecipients from scheduler.Notify()")
-	recipients := launch.NotificationRecipients(database, notification.Type, platform)
+
+	if candidates == nil {
+		candidates = database.NotificationSubscribers(notification.Type, platform)
+	}
+
+	recipients := launch.FilterRecipients(database, candidates)
 
 	// Create sendable
 	sendable := sendables.Sendable{
@@ -55,47 +62,23 @@ func Notify(launch *db.Launch, database *db.Database, username string) *sendable
 		Recipients:       recipients,
 	}
 
-	/* Loop over the sent-flags, and ensure every previous state is flagged.
+	/* Flag the notification, and every notification with a longer lead time, as sent.
 	This is important for launches that come out of the blue, namely launches
 	by e.g. China/Chinese companies, where the exact NET may only appear less
 	than 24 hours before lift-off.
 
 	As an example, the first notification we send might be the 1-hour notification.
 	In this case, we will need to flag the 12-hour and 24-hour notification types
-	as sent, as they are no-longer relevant. This is done below. */
-	times := []string{"5min", "1h", "12h", "24h"}
-
-	iterMap := map[string]string{
-		"5min": "1h",
-		"1h":   "12h",
-		"12h":  "24h",
-	}
-
-	// Toggle the current state as sent, after which all flags will be toggled.
-	passed := false
-	for _, notificationType := range times {
-		// Get the notification type that should have been sent before this one
-		previousType, ok := iterMap[notificationType]
-
-		if !passed && notificationType == notification.Type {
-			// This notification was sent: set state, sprintf to correct format.
-			launch.NotificationState.Map[fmt.Sprintf("Sent%s", notificationType)] = true
-			passed = true
-		}
-
-		if passed && ok {
-			// If flag has been set, and last type is flagged as unsent, update flag
-			if !launch.NotificationState.Map[fmt.Sprintf("Sent%s", previousType)] {
-				log.Debug().Msgf("Set %s to true for launch=%s", previousType, launch.Id)
-				launch.NotificationState.Map[fmt.Sprintf("Sent%s", previousType)] = true
+	as sent, as they are no-longer relevant. */
+	if !notification.AllSent {
+		for _, leadTime := range database.LeadTimesInUse() {
+			if leadTime >= notification.LeadTime && !launch.NotificationState.IsSent(leadTime) {
+				log.Debug().Msgf("Set %s to sent for launch=%s", users.LeadTimeType(leadTime), launch.Id)
+				launch.NotificationState.MarkSent(leadTime)
 			}
 		}
 	}
 
-	// Flags in the notification-state map have been set: automatically update the
-	// boolean values that are thrown into the database.
-	launch.NotificationState.UpdateFlags(launch)
-
 	// Push changes to database
 	err := database.Update([]*db.Launch{launch}, false, true)
 
@@ -110,10 +93,19 @@ func Notify(launch *db.Launch, database *db.Database, username string) *sendable
 }
 
 // NotificationWrapper is called when scheduled notifications are prepared for sending.
-func notificationWrapper(session *config.Session, launchIds []string, refreshData bool) {
+// Launches are grouped by lead time, so the chats of each lead time are loaded once.
+func notificationWrapper(session *config.Session, groups []db.NotificationGroup, refreshData bool) {
 	if refreshData {
-		// Run updater
-		updateWrapper(session, false)
+		// Data this fresh is not refreshed again, e.g. for each lead time due close together
+		const minRefreshInterval = time.Duration(5) * time.Minute
+
+		if sinceUpdate := session.Now().Sub(session.Cache.Updated); sinceUpdate < minRefreshInterval {
+			log.Info().Msgf("Data updated %s ago: not refreshing before notifications",
+				durafmt.Parse(sinceUpdate).LimitFirstN(2))
+		} else {
+			// Run updater
+			updateWrapper(session, false, true)
+		}
 
 		// Re-get all notifications
 		_, notification := session.Cache.NextScheduledUpdateIn()
@@ -125,36 +117,52 @@ func notificationWrapper(session *config.Session, launchIds []string, refreshDat
 		log.Debug().Msg("Not refreshing data before notification scheduling...")
 	}
 
-	// If we schedule a 5-min notification, schedule a post-launch API update
+	// If we schedule a final notification, schedule a post-launch API update
 	var postLaunchUpdate *PostLaunch
 
-	for i, launchId := range launchIds {
-		// Pull launch from the cache
-		launch, error := session.Cache.FindLaunchById(launchId)
+	for _, group := range groups {
+		notificationType := users.LeadTimeType(group.LeadTime)
+		candidates := session.Db.NotificationSubscribers(notificationType, "tg")
 
-		if error != nil {
-			log.Error().Err(error).Msgf("[notificationWrapper] Launch with id=%s not found in cache", launchId)
-			continue
-		}
+		log.Info().Msgf("Creating sendables for %d launch(es) with type=%s (%d candidate chat(s))",
+			len(group.IDs), notificationType, len(candidates))
+
+		for i, launchId := range group.IDs {
+			// Pull launch from the cache
+			launch, error := session.Cache.FindLaunchById(launchId)
 
-		log.Info().Msgf("[%d] Creating sendable for launch with name=%s", i+1, launch.Name)
+			if error != nil {
+				log.Error().Err(error).Msgf("[notificationWrapper] Launch with id=%s not found in cache", launchId)
+				continue
+			}
 
-		// Create the sendable for this notification
-		sendable := Notify(launch, session.Db, session.Telegram.Username)
+			log.Info().Msgf("[%d] Creating sendable for launch with name=%s", i+1, launch.Name)
 
-		if sendable.NotificationType == "5min" {
-			// If we're sending a 5-min notification, schedule a post-launch update
-			log.Debug().Msgf("Sendable has NotificationType==5min, setting queuePostLaunchUpdate=true")
-			postLaunchUpdate = &PostLaunch{NET: launch.NETUnix, LaunchId: launch.Id}
-		}
+			// The candidates are only valid if the launch's next notification is still of this group's type
+			launchCandidates := candidates
+
+			if next := launch.NextNotification(session.Db); next.LeadTime != group.LeadTime {
+				log.Warn().Msgf("Launch=%s moved from type=%s to type=%s since scheduling", launch.Slug, notificationType, next.Type)
+				launchCandidates = nil
+			}
+
+			// Create the sendable for this notification
+			sendable := Notify(launch, session.Db, session.Telegram.Username, launchCandidates)
+
+			if leadTime, ok := users.LeadTimeOfType(sendable.NotificationType); ok && leadTime <= users.FinalLeadTime {
+				// If we're sending a final notification, schedule a post-launch update
+				log.Debug().Msgf("Sendable has NotificationType==%s, setting queuePostLaunchUpdate=true", sendable.NotificationType)
+				postLaunchUpdate = &PostLaunch{NET: launch.NETUnix, LaunchId: launch.Id}
+			}
 
-		// Enqueue the sendable
-		session.Telegram.Enqueue(sendable, false)
+			// Enqueue the sendable
+			session.Telegram.Enqueue(sendable, false)
+		}
 	}
 
 	log.Debug().Msg("[notificationWrapper] Exiting normally: running scheduler...")
 
-	// Notifications processed: queue post-launch check if this is a 5-minute notification
+	// Notifications processed: queue post-launch check if this is a final notification
 	Scheduler(session, false, postLaunchUpdate, false)
 }
 
@@ -176,7 +184,7 @@ func NotificationScheduler(session *config.Session, notifTime *db.Notification,
 	}
 
 	// Create task - use StartAt for one-time scheduling at specific time
-	job, err := session.Scheduler.Every(1).Second().StartAt(scheduledTime).Do(notificationWrapper, session, notifTime.IDs, refresh)
+	job, err := session.Scheduler.Every(1).Second().StartAt(session.SchedulerTime(scheduledTime)).Do(notificationWrapper, session, notifTime.Groups, refresh)
 	if err == nil && job != nil {
 		// Make it a one-time job
 		job.LimitRunsTo(1)
@@ -195,7 +203,7 @@ func NotificationScheduler(session *config.Session, notifTime *db.Notification,
 	session.Mutex.Unlock()
 
 	log.Info().Msgf("Notifications scheduled for %d launch(es), in %s",
-		len(notifTime.IDs), durafmt.Parse(time.Until(scheduledTime)).LimitFirstN(2))
+		len(notifTime.IDs), durafmt.Parse(scheduledTime.Sub(session.Now())).LimitFirstN(2))
 
 	return true
 }
@@ -206,14 +214,18 @@ func Scheduler(session *config.Session, startup bool, postLaunchCheck *PostLaunc
 	// Get interval until next API update and the next upcoming notification
 	untilNextUpdate, notification := session.Cache.NextScheduledUpdateIn()
 
+	// Current time, according to the session's clock
+	now := session.Now()
+
 	if startup {
 		// On startup, check if database needs an immediate update
 		updateNow, sinceLast := session.Db.RequiresImmediateUpdate(untilNextUpdate)
-		session.Telegram.Stats.LastApiUpdate = time.Now().Add(-sinceLast)
+		session.Telegram.Stats.LastApiUpdate = now.Add(-sinceLast)
 
 		if updateNow {
-			// Database is out of date: update now
-			return Updater(session, true)
+			// Database is out of date: update now, if the request budget allows it
+			updateWrapper(session, true, false)
+			return true
 		}
 
 		// No need to update now, but deduct the time since last update from next update
@@ -221,10 +233,10 @@ func Scheduler(session *config.Session, startup bool, postLaunchCheck *PostLaunc
 	}
 
 	// Time of next scheduled API update, based on the next notification's type
-	autoUpdateTime := time.Now().Add(untilNextUpdate)
+	autoUpdateTime := now.Add(untilNextUpdate)
 
 	// Time until next notification must be sent
-	untilNotification := time.Until(time.Unix(notification.SendTime, 0))
+	untilNotification := time.Unix(notification.SendTime, 0).Sub(now)
 
 	// Save time of next notification
 	session.Telegram.Stats.NextNotification = time.Unix(notification.SendTime, 0)
@@ -236,12 +248,12 @@ func Scheduler(session *config.Session, startup bool, postLaunchCheck *PostLaunc
 			log.Debug().Msgf("postLaunchCheck set properly, scheduling 10 minutes after NET=%d", postLaunchCheck.NET)
 		} else {
 			// If notifiation has LaunchNET set to zero, schedule for 15 minutes from now
-			autoUpdateTime = time.Now().Add(time.Duration(15) * time.Minute)
+			autoUpdateTime = now.Add(time.Duration(15) * time.Minute)
 			log.Warn().Msgf("postLaunchCheck has NET set to zero, postLaunchCheck=%#v", postLaunchCheck)
 		}
 
 		log.Debug().Msgf("postLaunchCheck==true, set autoUpdateTime to %s",
-			durafmt.Parse(time.Until(autoUpdateTime)).LimitFirstN(2))
+			durafmt.Parse(autoUpdateTime.Sub(now)).LimitFirstN(2))
 	}
 
 	/* Compare the scheduled update to the notification send-time, and use
@@ -253,17 +265,17 @@ func Scheduler(session *config.Session, startup bool, postLaunchCheck *PostLaunc
 
 	This is due to the fact that the notification sender needs to check that the
 	data is still up to date, and has not changed from the last update. */
-	if (time.Until(autoUpdateTime) > untilNotification) && (untilNotification.Minutes() > -5.0) {
+	if (autoUpdateTime.Sub(now) > untilNotification) && (untilNotification.Minutes() > -5.0) {
 		log.Info().Msgf("A notification (type=%s) is coming up before next API update, scheduling...",
 			notification.Type)
 
 		// Save stats
-		session.Telegram.Stats.NextApiUpdate = time.Now().Add(untilNotification)
+		session.Telegram.Stats.NextApiUpdate = now.Add(untilNotification)
 		return NotificationScheduler(session, notification, true)
 	}
 
 	// Schedule next auto-update, since no notifications are incoming soon
-	job, err := session.Scheduler.Every(1).Second().StartAt(autoUpdateTime).Do(updateWrapper, session, true)
+	job, err := session.Scheduler.Every(1).Second().StartAt(session.SchedulerTime(autoUpdateTime)).Do(updateWrapper, session, true, false)
 	if err == nil && job != nil {
 		// Make it a one-time job
 		job.LimitRunsTo(1)
@@ -280,14 +292,14 @@ func Scheduler(session *config.Session, startup bool, postLaunchCheck *PostLaunc
 	session.Mutex.Unlock()
 
 	log.Info().Msgf("Next auto-update in %s (%s)",
-		durafmt.Parse(time.Until(autoUpdateTime)).LimitFirstN(2),
+		durafmt.Parse(autoUpdateTime.Sub(now)).LimitFirstN(2),
 		autoUpdateTime.Format(time.RFC1123))
 
 	// Save stats
 	session.Telegram.Stats.NextApiUpdate = autoUpdateTime
 
 	// Clean user cache, if it is safe to do so and no notifications are coming up soon
-	if !startup && (time.Until(autoUpdateTime) > time.Duration(1)*time.Hour) {
+	if !startup && (autoUpdateTime.Sub(now) > time.Duration(1)*time.Hour) {
 		log.Debug().Msgf("[Scheduler] SafeToFlushCache is set to %v", safeToFlushCache)
 
 		if safeToFlushCache {
@@ -302,7 +314,7 @@ func Scheduler(session *config.Session, startup bool, postLaunchCheck *PostLaunc
 			if untilNotification > time.Duration(2)*time.Hour && postLaunchCheck == nil {
 				/* More than two hours until next notif, more than an hour until next API
 				update, no post-launch check scheduled. Schedule a cache flush for later */
-				job, err := session.Scheduler.Every(1).Second().StartAt(time.Now().Add(time.Minute*time.Duration(15))).Do(
+				job, err := session.Scheduler.Every(1).Second().StartAt(session.SchedulerTime(session.Now().Add(time.Minute*time.Duration(15)))).Do(
 					session.Cache.CleanUserCache, session.Db, false, false)
 				if err == nil && job != nil {
 					// Make it a one-time job
@@ -0,0 +1,328 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"launchbot/config"
+	"launchbot/db"
+	"launchbot/utils"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// A source of launch data, called by the updater
+type LaunchSource interface {
+	// Fetches a fresh launch update from the source
+	Fetch() (*db.LaunchUpdate, error)
+
+	// Returns a short name for the source, used in logs
+	Name() string
+
+	// Returns the most API requests a single fetch can cost
+	RequestCost() int
+}
+
+// LL2Source is the default launch source, fetching data from the LL2 API
+type LL2Source struct {
+	Client         *resty.Client  // Http-client used for requests
+	Endpoint       string         // Base URL of the API (optional, defaults to LL2)
+	UseDevEndpoint bool           // Configure to use LL2's development endpoint
+	Horizon        config.Horizon // How far ahead launches are fetched
+	MaxRequests    int            // Most requests a fetch may use without a launch limit (optional)
+	RecordDir      string         // If set, raw responses are saved to this directory
+	Clock          utils.Clock    // Source of the current time (defaults to the system clock)
+}
+
+// Without a launch limit, a fetch follows at most this many pages by default
+const defaultMaxRequests = 3
+
+// FileSource reads launch updates from a directory of JSON fixtures. Each fetch
+// returns the next fixture, ordered by file name. Once all fixtures have been
+// read, the last one is returned indefinitely.
+type FileSource struct {
+	Directory string     // Directory the fixtures are read from
+	files     []string   // Ordered list of fixture files
+	next      int        // Index of the next fixture to read
+	Mutex     sync.Mutex // Avoid concurrent reads advancing the index
+}
+
+// The launch source used by the updater: defaults to LL2 if not set
+var source LaunchSource
+
+// Sets the launch source the updater uses
+func SetLaunchSource(launchSource LaunchSource) {
+	log.Info().Msgf("Using launch source=%s", launchSource.Name())
+	source = launchSource
+}
+
+// Returns the configured launch source, initializing an LL2 source if none is set
+func launchSource(session *config.Session) LaunchSource {
+	if source == nil {
+		userAgent := fmt.Sprintf("%s (telegram @%s)", session.Github, session.Telegram.Username)
+		ll2 := NewLL2Source(userAgent, session.UseDevEndpoint, session.Config.ApiHorizon)
+		ll2.RecordDir = session.RecordDir
+		ll2.Clock = session
+
+		if ll2.RecordDir != "" {
+			log.Info().Msgf("Recording LL2 responses to %s", ll2.RecordDir)
+		}
+
+		source = ll2
+	}
+
+	return source
+}
+
+// Creates a new LL2 launch source with a user-agent
+func NewLL2Source(userAgent string, useDevEndpoint bool, horizon config.Horizon) *LL2Source {
+	// Create http-client
+	client := resty.New()
+	client.SetTimeout(time.Duration(30 * time.Second))
+	client.SetHeader("user-agent", userAgent)
+
+	return &LL2Source{Client: client, UseDevEndpoint: useDevEndpoint, Horizon: horizon}
+}
+
+// Performs a single LL2 API call. Page is the index of the page within the fetch.
+func (ll2 *LL2Source) get(url string, fetched time.Time, page int) (*db.LaunchUpdate, error) {
+	// Do request
+	resp, err := ll2.Client.R().Get(url)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Error performing GET request")
+		return &db.LaunchUpdate{}, err
+	}
+
+	// Check if the request was throttled
+	if resp.StatusCode() == http.StatusTooManyRequests {
+		return &db.LaunchUpdate{}, &RateLimitError{
+			RetryAfter: parseRetryAfter(resp.Header(), resp.Body()),
+		}
+	}
+
+	// Check status code
+	if resp.StatusCode() != 200 {
+		err = fmt.Errorf("Status code != 200 (code %d)", resp.StatusCode())
+		return &db.LaunchUpdate{}, err
+	}
+
+	if ll2.RecordDir != "" {
+		recordResponse(ll2.RecordDir, fetched, page, resp.Body())
+	}
+
+	return unmarshalLaunchUpdate(resp.Body())
+}
+
+// Fetches upcoming launches from LL2, following the paginated results up to the horizon
+func (ll2 *LL2Source) Fetch() (*db.LaunchUpdate, error) {
+	const (
+		apiVersion  = "2.2.0"
+		requestPath = "launch/upcoming"
+	)
+
+	var endpoint string
+
+	if ll2.Endpoint != "" {
+		endpoint = ll2.Endpoint
+	} else if ll2.UseDevEndpoint {
+		log.Warn().Msg("Using LL2 development endpoint")
+		endpoint = "https://lldev.thespacedevs.com"
+	} else {
+		endpoint = "https://ll.thespacedevs.com"
+	}
+
+	apiParams := fmt.Sprintf("mode=detailed&limit=%d", ll2.pageSize())
+
+	// If the horizon is limited in time, only request launches up to the horizon
+	var horizon int64
+
+	now := time.Now()
+
+	if ll2.Clock != nil {
+		now = ll2.Clock.Now()
+	}
+
+	if ll2.Horizon.Days > 0 {
+		horizonTime := now.Add(time.Duration(ll2.Horizon.Days) * 24 * time.Hour).UTC()
+		apiParams += fmt.Sprintf("&net__lte=%s", horizonTime.Format(time.RFC3339))
+		horizon = horizonTime.Unix()
+	}
+
+	// Construct the URL
+	url := fmt.Sprintf("%s/%s/%s?%s", endpoint, apiVersion, requestPath, apiParams)
+
+	update := &db.LaunchUpdate{Postponed: make(map[*db.Launch]db.Postpone)}
+	truncated := false
+	fetched := now
+
+	for url != "" {
+		page, err := ll2.get(url, fetched, update.Requests)
+		update.Requests++
+
+		if err != nil {
+			return &db.LaunchUpdate{Requests: update.Requests}, err
+		}
+
+		update.Launches = append(update.Launches, page.Launches...)
+		url = page.Next
+
+		if ll2.Horizon.Launches > 0 && len(update.Launches) >= ll2.Horizon.Launches {
+			// Horizon reached: drop any extra launches, and stop paginating
+			truncated = url != "" || len(update.Launches) > ll2.Horizon.Launches
+			update.Launches = update.Launches[:ll2.Horizon.Launches]
+			break
+		}
+
+		if ll2.Horizon.Launches <= 0 && url != "" && update.Requests >= ll2.RequestCost() {
+			// Request budget of an unlimited horizon used up: the update ends at the last launch
+			truncated = true
+			break
+		}
+	}
+
+	// If the update was cut short, it only covers launches up to the last one received
+	update.Horizon = updateHorizon(update.Launches, truncated, horizon)
+
+	log.Debug().Msgf("Fetched %d launch(es) in %d request(s)", len(update.Launches), update.Requests)
+	return update, nil
+}
+
+// Returns the page size used for requests: request as few pages as possible
+func (ll2 *LL2Source) pageSize() int {
+	const maxPageSize = 100
+
+	if ll2.Horizon.Launches <= 0 || ll2.Horizon.Launches > maxPageSize {
+		return maxPageSize
+	}
+
+	return ll2.Horizon.Launches
+}
+
+// Returns the count of pages required to reach the horizon
+func (ll2 *LL2Source) RequestCost() int {
+	if ll2.Horizon.Launches <= 0 {
+		// No launch limit: the fetch is bounded by the days-horizon and the page cap
+		if ll2.MaxRequests > 0 {
+			return ll2.MaxRequests
+		}
+
+		return defaultMaxRequests
+	}
+
+	return (ll2.Horizon.Launches + ll2.pageSize() - 1) / ll2.pageSize()
+}
+
+func (ll2 *LL2Source) Name() string {
+	if ll2.UseDevEndpoint {
+		return "ll2-dev"
+	}
+
+	return "ll2"
+}
+
+// Creates a new file-backed launch source, reading fixtures from a directory
+func NewFileSource(directory string) (*FileSource, error) {
+	files, err := filepath.Glob(filepath.Join(directory, "*.json"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("No JSON fixtures found in %s", directory)
+	}
+
+	// Fixtures are read in file-name order
+	sort.Strings(files)
+
+	log.Debug().Msgf("Found %d fixture(s) in %s", len(files), directory)
+	return &FileSource{Directory: directory, files: files}, nil
+}
+
+// Reads the next fixture from the directory
+func (fs *FileSource) Fetch() (*db.LaunchUpdate, error) {
+	fs.Mutex.Lock()
+	defer fs.Mutex.Unlock()
+
+	path := fs.files[fs.next]
+
+	// Advance to the next fixture, unless this is the last one
+	if fs.next < len(fs.files)-1 {
+		fs.next++
+	}
+
+	body, err := os.ReadFile(path)
+
+	if err != nil {
+		log.Error().Err(err).Msgf("Error reading fixture at %s", path)
+		return &db.LaunchUpdate{}, err
+	}
+
+	log.Debug().Msgf("Read launch update from fixture=%s", filepath.Base(path))
+	update, err := unmarshalLaunchUpdate(body)
+
+	if err != nil {
+		return update, err
+	}
+
+	// Fixtures may be single pages of a longer manifest
+	update.Horizon = updateHorizon(update.Launches, update.Next != "", 0)
+	return update, nil
+}
+
+// Reading fixtures does not cost any API requests
+func (fs *FileSource) RequestCost() int {
+	return 0
+}
+
+func (fs *FileSource) Name() string {
+	return fmt.Sprintf("files (%s)", strings.TrimSuffix(fs.Directory, "/"))
+}
+
+/* Returns the unix-time an update covers launches up to. If more launches were
+available than were fetched, the update only covers launches until the NET of the
+last launch received. Otherwise, the horizon is left as-is. */
+func updateHorizon(launches []*db.Launch, truncated bool, horizon int64) int64 {
+	if !truncated || len(launches) == 0 {
+		return horizon
+	}
+
+	// Launches are ordered by NET
+	lastNet, err := time.Parse(time.RFC3339, launches[len(launches)-1].NET)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Error parsing NET of last launch when setting update horizon")
+		return horizon
+	}
+
+	if horizon == 0 || lastNet.Unix() < horizon {
+		return lastNet.Unix()
+	}
+
+	return horizon
+}
+
+// Unmarshals a raw LL2 response body into a launch update
+func unmarshalLaunchUpdate(body []byte) (*db.LaunchUpdate, error) {
+	// Unmarshal into a launch update struct
+	var update db.LaunchUpdate
+	err := json.Unmarshal(body, &update)
+
+	// Init the postponed map of the update
+	update.Postponed = make(map[*db.Launch]db.Postpone)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Error unmarshaling JSON")
+		return &db.LaunchUpdate{}, err
+	}
+
+	return &update, nil
+}
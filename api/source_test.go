@@ -0,0 +1,166 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"launchbot/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Tests that the file source reads fixtures in order, and repeats the last one
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+
+	fixtures := map[string]string{
+		"001.json": `{"results": [{"id": "first", "name": "First launch"}]}`,
+		"002.json": `{"results": [{"id": "second", "name": "Second launch"}, {"id": "third"}]}`,
+	}
+
+	for name, content := range fixtures {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+
+	source, err := NewFileSource(dir)
+
+	if err != nil {
+		t.Fatalf("failed to create file source: %v", err)
+	}
+
+	expected := []string{"first", "second", "second"}
+
+	for i, id := range expected {
+		update, err := source.Fetch()
+
+		if err != nil {
+			t.Fatalf("fetch %d failed: %v", i, err)
+		}
+
+		if len(update.Launches) == 0 || update.Launches[0].Id != id {
+			t.Errorf("fetch %d: expected first launch id=%s, got %#v", i, id, update.Launches)
+		}
+
+		if update.Postponed == nil {
+			t.Errorf("fetch %d: postponed map not initialized", i)
+		}
+	}
+
+	// An empty directory should not produce a source
+	if _, err := NewFileSource(t.TempDir()); err == nil {
+		t.Errorf("expected an error for a directory without fixtures")
+	}
+}
+
+// Tests that the LL2 source follows paginated results up to the horizon
+func TestLL2SourcePagination(t *testing.T) {
+	// Three pages of two launches each, one day apart
+	start := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	requests := 0
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+		launches := []map[string]string{}
+		for i := 0; i < 2; i++ {
+			idx := page*2 + i
+			launches = append(launches, map[string]string{
+				"id":  fmt.Sprintf("launch-%d", idx),
+				"net": start.Add(time.Duration(idx) * 24 * time.Hour).Format(time.RFC3339),
+			})
+		}
+
+		next := ""
+		if page < 2 {
+			next = fmt.Sprintf("%s/2.2.0/launch/upcoming?page=%d", server.URL, page+1)
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"next": next, "results": launches})
+	}))
+	defer server.Close()
+
+	// Horizon cuts the manifest short in the middle of the second page
+	source := NewLL2Source("launchbot-tests", false, config.Horizon{Launches: 3})
+	source.Endpoint = server.URL
+
+	update, err := source.Fetch()
+
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+
+	if len(update.Launches) != 3 || update.Requests != 2 || requests != 2 {
+		t.Errorf("expected 3 launches in 2 requests, got %d launches in %d requests",
+			len(update.Launches), update.Requests)
+	}
+
+	if expected := start.Add(2 * 24 * time.Hour).Unix(); update.Horizon != expected {
+		t.Errorf("expected horizon=%d, got %d", expected, update.Horizon)
+	}
+
+	// A horizon larger than the manifest fetches everything, and has no limit
+	source.Horizon = config.Horizon{Launches: 10}
+	update, err = source.Fetch()
+
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+
+	if len(update.Launches) != 6 || update.Horizon != 0 {
+		t.Errorf("expected 6 launches with no horizon, got %d launches with horizon=%d",
+			len(update.Launches), update.Horizon)
+	}
+}
+
+// Tests that a fetch without a launch limit stops at the request cap
+func TestLL2SourceUnlimitedHorizon(t *testing.T) {
+	start := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	requests := 0
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+		// An endless manifest, one launch per page and one day apart
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"next": fmt.Sprintf("%s/2.2.0/launch/upcoming?page=%d", server.URL, page+1),
+			"results": []map[string]string{{
+				"id":  fmt.Sprintf("launch-%d", page),
+				"net": start.Add(time.Duration(page) * 24 * time.Hour).Format(time.RFC3339),
+			}},
+		})
+	}))
+	defer server.Close()
+
+	source := NewLL2Source("launchbot-tests", false, config.Horizon{Days: 30})
+	source.Endpoint = server.URL
+	source.MaxRequests = 2
+
+	if source.RequestCost() != 2 {
+		t.Errorf("expected a request cost of 2, got %d", source.RequestCost())
+	}
+
+	update, err := source.Fetch()
+
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+
+	if len(update.Launches) != 2 || requests != 2 {
+		t.Errorf("expected 2 launches in 2 requests, got %d launches in %d requests", len(update.Launches), requests)
+	}
+
+	// The update was cut short, so it only covers launches up to the last one received
+	if expected := start.Add(24 * time.Hour).Unix(); update.Horizon != expected {
+		t.Errorf("expected horizon=%d, got %d", expected, update.Horizon)
+	}
+}
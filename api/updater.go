@@ -1,80 +1,55 @@
 package api
 
 import (
-	"encoding/json"
-	"fmt"
+	"errors"
 	"launchbot/config"
 	"launchbot/db"
 	"math"
 	"time"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/hako/durafmt"
 	"github.com/rs/zerolog/log"
 )
 
-// Performs an LL2 API call
-func apiCall(client *resty.Client, useDevEndpoint bool) (*db.LaunchUpdate, error) {
-	const (
-		apiVersion  = "2.2.0"
-		requestPath = "launch/upcoming"
-		apiParams   = "mode=detailed&limit=30"
-	)
-
-	var endpoint string
-
-	if useDevEndpoint {
-		log.Warn().Msg("Using LL2 development endpoint")
-		endpoint = "https://lldev.thespacedevs.com"
-	} else {
-		endpoint = "https://ll.thespacedevs.com"
-	}
-
-	// Construct the URL
-	url := fmt.Sprintf("%s/%s/%s?%s", endpoint, apiVersion, requestPath, apiParams)
-
-	// Do request
-	resp, err := client.R().Get(url)
-
-	if err != nil {
-		log.Error().Err(err).Msg("Error performing GET request")
-		return &db.LaunchUpdate{}, err
-	}
-
-	// Check status code
-	if resp.StatusCode() != 200 {
-		err = fmt.Errorf("Status code != 200 (code %d)", resp.StatusCode())
-		return &db.LaunchUpdate{}, err
-	}
-
-	// Unmarshal into a launch update struct
-	var update db.LaunchUpdate
-	err = json.Unmarshal(resp.Body(), &update)
-
-	// Init the postponed map of the update
-	update.Postponed = make(map[*db.Launch]db.Postpone)
-
-	if err != nil {
-		log.Error().Err(err).Msg("Error unmarshaling JSON")
-		return &db.LaunchUpdate{}, err
-	}
-
-	return &update, nil
-}
-
-// Function that chrono calls when a scheduled API update runs.
-func updateWrapper(session *config.Session, scheduleNext bool) {
-	log.Debug().Msgf("Running updateWrapper with scheduleNext=%v", scheduleNext)
+// Function that chrono calls when a scheduled API update runs. Critical updates,
+// i.e. the refresh before notifications are sent, may use the reserved request budget.
+func updateWrapper(session *config.Session, scheduleNext bool, critical bool) {
+	log.Debug().Msgf("Running updateWrapper with scheduleNext=%v, critical=%v", scheduleNext, critical)
 
 	// Log start-time for failures
 	startTime := time.Now()
 
 	// Run updater in a re-try loop
 	for i := 1; ; i++ {
+		// Check if the request budget allows an update now
+		wait := requestBudget(session).Delay(launchSource(session).RequestCost(), critical)
+
+		if wait > 0 {
+			if critical {
+				// Never hold up notifications: send them with the cached data
+				log.Warn().Msgf("Request budget exhausted for %s: skipping critical update, using cached data (%s)",
+					durafmt.Parse(wait).LimitFirstN(2), budget)
+				return
+			}
+
+			// Delay non-critical updates until the budget allows them
+			log.Warn().Msgf("Request budget low: delaying update by %s (%s)",
+				durafmt.Parse(wait).LimitFirstN(2), budget)
+
+			delayUpdate(session, session.Now().Add(wait))
+			return
+		}
+
 		// Check for success
 		success := Updater(session, scheduleNext)
 
 		if !success {
+			if critical {
+				// Retrying would delay the notifications: send them with the cached data
+				log.Warn().Msg("Critical update failed, using cached data")
+				return
+			}
+
 			// If updater failed, do exponential back-off
 			retryAfter := math.Pow(2.0, float64(i))
 
@@ -101,24 +76,51 @@ func updateWrapper(session *config.Session, scheduleNext bool) {
 	log.Debug().Msgf("updateWrapper finished successfully")
 }
 
-// Handles the API request flow, requesting new data and updating the cached and on-disk data.
-func Updater(session *config.Session, scheduleNext bool) bool {
-	// Create http-client
-	client := resty.New()
-	client.SetTimeout(time.Duration(30 * time.Second))
-	client.SetHeader(
-		"user-agent", fmt.Sprintf("%s (telegram @%s)", session.Github, session.Telegram.Username),
-	)
+// Runs an API update now, e.g. on startup, and schedules the next one. The update goes
+// through the request budget, so a restart does not request while LL2 is throttling.
+func UpdateNow(session *config.Session) {
+	updateWrapper(session, true, false)
+}
 
-	// Do API call
-	log.Info().Msg("Running LL2 API updater...")
-	update, err := apiCall(client, session.UseDevEndpoint)
+// Re-schedules a delayed API update. If a notification comes up before the
+// delayed update, the notification is scheduled instead, as it refreshes the data.
+func delayUpdate(session *config.Session, updateTime time.Time) bool {
+	_, notification := session.Cache.NextScheduledUpdateIn()
 
-	if err != nil || len(update.Launches) == 0 {
-		apiErrorHandler(err)
+	now := session.Now()
+	untilNotification := time.Unix(notification.SendTime, 0).Sub(now)
+
+	if notification.SendTime != 0 && updateTime.Sub(now) > untilNotification && untilNotification.Minutes() > -5.0 {
+		log.Info().Msgf("A notification (type=%s) is coming up before the delayed update, scheduling...",
+			notification.Type)
+
+		session.Telegram.Stats.NextApiUpdate = now.Add(untilNotification)
+		return NotificationScheduler(session, notification, true)
+	}
+
+	job, err := session.Scheduler.Every(1).Second().StartAt(session.SchedulerTime(updateTime)).Do(updateWrapper, session, true, false)
+	if err == nil && job != nil {
+		// Make it a one-time job
+		job.LimitRunsTo(1)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msgf("Scheduling delayed update failed")
 		return false
 	}
 
+	// Lock session, add job to list of scheduled jobs
+	session.Mutex.Lock()
+	session.Tasks = append(session.Tasks, job)
+	session.Mutex.Unlock()
+
+	session.Telegram.Stats.NextApiUpdate = updateTime
+	return true
+}
+
+// Parses a launch update, and updates the cached and on-disk data with it.
+// Returns the launches that were postponed by the update.
+func applyUpdate(session *config.Session, update *db.LaunchUpdate) (map[*db.Launch]db.Postpone, error) {
 	// Parse any relevant data before dumping to disk
 	parseStartTime := time.Now()
 	launches, postponedLaunches, err := parseLaunchUpdate(session.Cache, update)
@@ -128,7 +130,7 @@ func Updater(session *config.Session, scheduleNext bool) bool {
 
 	if err != nil {
 		log.Error().Err(err).Msg("➙ Error parsing launch update")
-		return false
+		return nil, err
 	}
 
 	// Update hot launch cache
@@ -141,17 +143,61 @@ func Updater(session *config.Session, scheduleNext bool) bool {
 
 	if err != nil {
 		log.Error().Err(err).Msg("➙ Error inserting launches to database")
-		return false
+		return nil, err
+	}
+
+	// Persist the changes detected in this update
+	err = session.Db.SaveChangeEvents(update.Events)
+
+	if err != nil {
+		// Not critical: the update itself is still valid
+		log.Error().Err(err).Msg("➙ Error saving change events to database")
 	}
 
 	// Clean the launch database
-	err = session.Db.CleanSlippedLaunches()
+	err = session.Db.CleanSlippedLaunches(update.Horizon)
 
 	if err != nil {
 		log.Error().Err(err).Msg("➙ Error cleaning launch database")
+		return nil, err
+	}
+
+	return postponedLaunches, nil
+}
+
+// Handles the API request flow, requesting new data and updating the cached and on-disk data.
+func Updater(session *config.Session, scheduleNext bool) bool {
+	// Load the configured launch source
+	src := launchSource(session)
+
+	// Fetch a launch update from the source
+	log.Info().Msgf("Running API updater (source=%s)...", src.Name())
+	update, err := src.Fetch()
+
+	// Track the requests made, and any throttling
+	requestBudget(session).Record(update.Requests)
+
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		budget.Throttle(rateLimitErr.RetryAfter)
+	}
+
+	if err != nil || len(update.Launches) == 0 {
+		apiErrorHandler(err)
 		return false
 	}
 
+	// Parse the update, and store it in the cache and the database
+	postponedLaunches, err := applyUpdate(session, update)
+
+	if err != nil {
+		return false
+	}
+
+	if len(update.Events) != 0 {
+		log.Info().Msgf("➙ %d change(s) detected in launches", len(update.Events))
+	}
+
 	// Is flushing the user cache safe?
 	safeToFlushCache := false
 
@@ -170,13 +216,64 @@ func Updater(session *config.Session, scheduleNext bool) bool {
 		log.Debug().Msg("➙ No launches were postponed")
 	}
 
+	// If launches reached an outcome, notify
+	if len(update.Outcomes) != 0 {
+		log.Info().Msgf("➙ %d launches reached an outcome", len(update.Outcomes))
+
+		for _, launch := range update.Outcomes {
+			// Create and enqueue the sendable for this outcome
+			sendable := launch.OutcomeNotificationSendable(session.Db, "tg")
+			session.Telegram.Enqueue(sendable, false)
+		}
+	}
+
+	// If webcasts went live, notify
+	webcasts := update.LiveWebcasts()
+
+	if len(webcasts) != 0 {
+		log.Info().Msgf("➙ %d launch webcasts went live", len(webcasts))
+
+		for _, launch := range webcasts {
+			// Create and enqueue the sendable for this webcast
+			sendable := launch.WebcastNotificationSendable(session.Db, "tg")
+			session.Telegram.Enqueue(sendable, false)
+
+			// Flag the notification as sent, so it is only sent once
+			launch.NotificationState.SentWebcast = true
+		}
+
+		if err := session.Db.Update(webcasts, false, true); err != nil {
+			log.Error().Err(err).Msg("➙ Saving webcast notification states failed")
+		}
+	}
+
+	// If launches went on hold or were scrubbed, notify chats that have been notified of them
+	holds := 0
+
+	for _, event := range update.EventsOfType(db.StatusChange) {
+		if !event.IsHold() && !event.IsScrub() {
+			continue
+		}
+
+		sendable := event.Launch.HoldNotificationSendable(session.Db, event.IsScrub(), "tg")
+
+		if len(sendable.Recipients) != 0 {
+			session.Telegram.Enqueue(sendable, false)
+			holds++
+		}
+	}
+
+	if holds != 0 {
+		log.Info().Msgf("➙ %d launches went on hold or were scrubbed", holds)
+	}
+
 	// Save stats
-	session.Telegram.Stats.LastApiUpdate = time.Now()
-	session.Telegram.Stats.ApiRequests++
+	session.Telegram.Stats.LastApiUpdate = session.Now()
+	session.Telegram.Stats.ApiRequests += update.Requests
 
 	// Schedule next API update, if configured
 	if scheduleNext {
-		if len(postponedLaunches) == 0 && !session.Telegram.Spam.NotificationSendUnderway {
+		if len(postponedLaunches) == 0 && len(update.Outcomes) == 0 && len(webcasts) == 0 && holds == 0 && !session.Telegram.Spam.NotificationSendUnderway {
 			// Flushing cache is safe under these conditions
 			safeToFlushCache = true
 		}
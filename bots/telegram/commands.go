@@ -40,14 +40,23 @@ func (tg *Bot) adminCommand(ctx tb.Context) error {
 		return nil
 	}
 
+	// State of the API request budget
+	apiBudget := "not initialized"
+
+	if tg.ApiBudget != nil {
+		apiBudget = tg.ApiBudget.String()
+	}
+
 	text := fmt.Sprintf("🤖 *LaunchBot admin-panel*\n"+
 		"Cached launches: %d\n"+
 		"Cached users: %d\n\n"+
 		"Send in progress: %v\n"+
+		"API budget: %s\n"+
 		"Log-file size: %s",
 		len(tg.Cache.Launches),
 		len(tg.Cache.Users.InCache),
 		tg.Spam.NotificationSendUnderway,
+		apiBudget,
 		humanize.Bytes(uint64(logging.GetLogSize(""))),
 	)
 
@@ -124,6 +133,66 @@ func (tg *Bot) adminReply(ctx tb.Context) error {
 	return nil
 }
 
+// Admin-only command to list and edit the launch providers in the provider registry
+func (tg *Bot) adminProvider(ctx tb.Context) error {
+	// Owner-only function
+	if !tg.senderIsOwner(ctx) {
+		log.Error().Msgf("/provider called by non-owner (%d in %d)", ctx.Sender().ID, ctx.Chat().ID)
+		return nil
+	}
+
+	owner := tg.Cache.FindUser(fmt.Sprint(tg.Owner), "tg")
+
+	// Format: /provider [id] [name|flag|cc] [value...]
+	inputDataSplit := strings.Fields(ctx.Text())
+
+	var text string
+
+	switch len(inputDataSplit) {
+	case 1:
+		// List all providers by country
+		text = "🚀 *Launch providers*\n"
+
+		for _, cc := range db.Providers.CountryCodes() {
+			text += fmt.Sprintf("\n*%s*\n", db.CountryName(cc))
+
+			for _, id := range db.Providers.IdsByCountryCode(cc) {
+				provider, _ := db.Providers.Get(id)
+				text += fmt.Sprintf("%d: %s %s (%s)\n", provider.Id, provider.Flag, provider.ShortName, provider.Name)
+			}
+		}
+
+		text += "\nEdit with /provider [id] [name|flag|cc] [value]"
+
+	case 2, 3:
+		text = "⚠️ Incorrect data length. Format: /provider [id] [name|flag|cc] [value...]"
+
+	default:
+		id, err := strconv.Atoi(inputDataSplit[1])
+
+		if err != nil {
+			text = fmt.Sprintf("⚠️ Invalid provider ID: %s", inputDataSplit[1])
+			break
+		}
+
+		provider, err := db.Providers.Edit(id, strings.ToLower(inputDataSplit[2]), strings.Join(inputDataSplit[3:], " "))
+
+		if err != nil {
+			text = fmt.Sprintf("⚠️ Editing provider failed: %s", err.Error())
+			break
+		}
+
+		log.Info().Msgf("Provider id=%d edited: name=%s, flag=%s, cc=%s",
+			provider.Id, provider.ShortName, provider.Flag, provider.CountryCode)
+
+		text = fmt.Sprintf("✅ Provider updated\n%d: %s %s (%s)",
+			provider.Id, provider.Flag, provider.ShortName, db.CountryName(provider.CountryCode))
+	}
+
+	tg.Enqueue(sendables.TextOnlySendable(utils.PrepareInputForMarkdown(text, "text"), owner), true)
+	return nil
+}
+
 // Admin-only command to broadcast messages to all active subscribers
 func (tg *Bot) broadcastHandler(ctx tb.Context) error {
 	// Owner-only function
@@ -502,6 +571,44 @@ func (tg *Bot) scheduleHandler(ctx tb.Context) error {
 	return nil
 }
 
+// Handles the /calendar command
+func (tg *Bot) calendarHandler(ctx tb.Context) error {
+	// Load chat and generate the interaction
+	chat, interaction, err := tg.buildInteraction(ctx, false, "calendar")
+
+	if err != nil {
+		log.Warn().Msg("Running calendarHandler failed")
+		return nil
+	}
+
+	// Run permission and spam management
+	if !tg.Spam.PreHandler(interaction, chat, tg.Stats) {
+		return tg.interactionNotAllowed(ctx, interaction.IsCommand)
+	}
+
+	launches := tg.Cache.LaunchesUserHasSubscribedTo(chat)
+	text := utils.PrepareInputForMarkdown(tg.Template.Messages.Command.Calendar(len(launches), tg.Username), "italictext")
+
+	msg := sendables.Message{
+		TextContent: text,
+		SendOptions: tb.SendOptions{ParseMode: "MarkdownV2", DisableNotification: isChannel(ctx.Chat())},
+	}
+
+	if len(launches) != 0 {
+		msg.Attachment = &sendables.Attachment{
+			FileName: "launches.ics",
+			MIME:     "text/calendar",
+			Content:  db.LaunchCalendar(launches, "LaunchBot launches", tg.Cache.Now(), tg.Db.NetChangeCounts(launches)),
+		}
+	}
+
+	log.Debug().Msgf("Chat=%s exported %d launch(es) to a calendar", chat.Id, len(launches))
+
+	// Add to send queue as high-priority
+	tg.enqueueCommand(&msg, chat, ctx)
+	return nil
+}
+
 // Handles the /next command
 func (tg *Bot) nextHandler(ctx tb.Context) error {
 	// Load chat and generate the interaction
@@ -651,10 +758,10 @@ func (tg *Bot) settingsHandler(ctx tb.Context) error {
 	}
 
 	// Load keyboard based on chat-type
-	_, kb := tg.Template.Keyboard.Settings.Main(isGroup(ctx.Chat()))
+	_, kb := tg.Template.Keyboard.Settings.Main(isGroup(ctx.Chat()), tg.CalendarFeedURL != "")
 
 	// Load message text content based on chat-type
-	message := tg.Template.Messages.Settings.Main(isGroup(ctx.Chat()))
+	message := tg.Template.Messages.Settings.Main(isGroup(ctx.Chat()), tg.CalendarFeedURL != "")
 	message = utils.PrepareInputForMarkdown(message, "text")
 
 	// Construct message
@@ -707,7 +814,7 @@ func (tg *Bot) settingsCountryCodeView(ctx tb.Context) error {
 	tg.editCbMessage(ctx.Callback(), message, sendOptions)
 
 	// Respond to callback
-	return tg.respondToCallback(ctx, fmt.Sprintf("Loaded %s", db.CountryCodeToName[data[1]]), false)
+	return tg.respondToCallback(ctx, fmt.Sprintf("Loaded %s", db.CountryName(data[1])), false)
 }
 
 // Handles callbacks related to toggling notification settings
@@ -748,6 +855,20 @@ func (tg *Bot) notificationToggleCallback(ctx tb.Context) error {
 		cbText = fmt.Sprintf("%s all notifications", utils.NotificationToggleCallbackString(toggleTo))
 		showAlert = true
 
+	case "crewed":
+		// Toggle the crewed-only filter
+		toggleTo := utils.BinStringStateToBool[data[1]]
+		chat.CrewedOnly = toggleTo
+
+		// Update keyboard
+		_, updatedKeyboard = tg.Template.Keyboard.Settings.Subscription.Main(chat)
+
+		// Callback response
+		cbText = map[bool]string{
+			true:  "👩‍🚀 Only crewed flights will be notified",
+			false: "🚀 All flights will be notified",
+		}[toggleTo]
+
 	case "id":
 		// Toggle subscription for this ID
 		toggleTo := utils.BinStringStateToBool[data[2]]
@@ -755,12 +876,97 @@ func (tg *Bot) notificationToggleCallback(ctx tb.Context) error {
 
 		// Load updated keyboard
 		intId, _ := strconv.Atoi(data[1])
+		provider, _ := db.Providers.Get(intId)
 
 		// Update keyboard
-		_, updatedKeyboard = tg.Template.Keyboard.Settings.Subscription.ByCountryCode(chat, db.LSPShorthands[intId].Cc)
+		_, updatedKeyboard = tg.Template.Keyboard.Settings.Subscription.ByCountryCode(chat, provider.CountryCode)
 
 		// Callback response
-		cbText = fmt.Sprintf("%s %s", utils.NotificationToggleCallbackString(toggleTo), db.LSPShorthands[intId].Name)
+		cbText = fmt.Sprintf("%s %s", utils.NotificationToggleCallbackString(toggleTo), provider.ShortName)
+
+	case "site":
+		if len(data) < 3 {
+			log.Warn().Msgf("Insufficient data in site/ toggle endpoint: %d", len(data))
+			return nil
+		}
+
+		// Toggle subscription for this launch site
+		toggleTo := utils.BinStringStateToBool[data[2]]
+		siteId, _ := strconv.Atoi(data[1])
+		chat.ToggleLocationSubscription(siteId, toggleTo)
+
+		// Update keyboard
+		_, updatedKeyboard = tg.Template.Keyboard.Settings.Subscription.BySite(chat, tg.Db.LaunchSites())
+
+		// Callback response
+		site, _ := tg.Db.LaunchSite(siteId)
+		cbText = fmt.Sprintf("%s %s", utils.NotificationToggleCallbackString(toggleTo), site.ShortName())
+
+	case "vehicle", "family":
+		if len(data) == 2 && data[1] == "clear" {
+			// Unfollow all vehicles and families
+			chat.SubscribedVehicles, chat.SubscribedFamilies = "", ""
+			cbText = "🔕 Unfollowed all rockets"
+		} else if len(data) < 3 {
+			log.Warn().Msgf("Insufficient data in %s/ toggle endpoint: %d", data[0], len(data))
+			return nil
+		} else if data[0] == "vehicle" {
+			// Toggle subscription for this rocket configuration
+			toggleTo := utils.BinStringStateToBool[data[2]]
+			vehicleId, _ := strconv.Atoi(data[1])
+			chat.ToggleVehicleSubscription(vehicleId, toggleTo)
+
+			cbText = utils.NotificationToggleCallbackString(toggleTo)
+
+			for _, vehicle := range tg.Cache.Vehicles() {
+				if vehicle.Id == vehicleId {
+					cbText = fmt.Sprintf("%s %s", cbText, vehicle.Name)
+				}
+			}
+		} else {
+			// Toggle subscription for this rocket family
+			toggleTo := utils.BinStringStateToBool[data[2]]
+			chat.ToggleFamilySubscription(data[1], toggleTo)
+
+			cbText = fmt.Sprintf("%s all %s rockets", utils.NotificationToggleCallbackString(toggleTo), data[1])
+		}
+
+		// Update keyboard
+		_, updatedKeyboard = tg.Template.Keyboard.Settings.Subscription.ByVehicle(
+			chat, tg.Cache.RocketFamilies(), tg.Cache.Vehicles())
+
+	case "mf":
+		if len(data) == 2 && data[1] == "clear" {
+			// Clear all filters, and update the text, as it summarizes the filters
+			chat.ClearMissionFilters()
+
+			message := tg.Template.Messages.Settings.Mission.Main(chat)
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			sendOptions, _ := tg.Template.Keyboard.Settings.Mission.Main(chat)
+
+			go tg.Db.SaveUser(chat)
+			tg.editCbMessage(ctx.Callback(), message, sendOptions)
+			return tg.respondToCallback(ctx, "🗑️ Cleared all orbit & mission filters", false)
+		} else if len(data) < 3 || (data[1] != "orbit" && data[1] != "type") {
+			log.Warn().Msgf("Invalid data in mf/ toggle endpoint: %s", ctx.Callback().Data)
+			return nil
+		}
+
+		// Values may contain slashes, e.g. "Government/Top Secret"
+		value := strings.Join(data[2:], "/")
+		newState := chat.CycleMissionFilter(data[1], value)
+
+		// Update keyboard
+		_, updatedKeyboard = tg.Template.Keyboard.Settings.Mission.Values(
+			chat, data[1], tg.Cache.MissionFilterValues(data[1], chat))
+
+		// Callback response
+		cbText = map[users.FilterState]string{
+			users.FilterNone:    fmt.Sprintf("⚪ %s no longer filtered", value),
+			users.FilterInclude: fmt.Sprintf("✅ Included %s", value),
+			users.FilterExclude: fmt.Sprintf("🚫 Excluded %s", value),
+		}[newState]
 
 	case "cc":
 		// Load all IDs associated with this country-code
@@ -774,7 +980,7 @@ func (tg *Bot) notificationToggleCallback(ctx tb.Context) error {
 		_, updatedKeyboard = tg.Template.Keyboard.Settings.Subscription.ByCountryCode(chat, data[1])
 
 		// Callback response
-		cbText = fmt.Sprintf("%s all for %s", utils.NotificationToggleCallbackString(toggleTo), db.CountryCodeToName[data[1]])
+		cbText = fmt.Sprintf("%s all for %s", utils.NotificationToggleCallbackString(toggleTo), db.CountryName(data[1]))
 		showAlert = true
 
 	case "time":
@@ -791,7 +997,12 @@ func (tg *Bot) notificationToggleCallback(ctx tb.Context) error {
 		_, updatedKeyboard = tg.Template.Keyboard.Settings.Notifications(chat)
 
 		// Callback response
-		cbText = fmt.Sprintf("%s %s notifications", utils.NotificationToggleCallbackString(toggleTo), data[1])
+		label := data[1]
+		if leadTime, ok := users.LeadTimeOfType(data[1]); ok {
+			label = users.LeadTimeLabel(leadTime)
+		}
+
+		cbText = fmt.Sprintf("%s %s notifications", utils.NotificationToggleCallbackString(toggleTo), label)
 
 	case "cmd":
 		if len(data) < 3 {
@@ -950,10 +1161,10 @@ func (tg *Bot) settingsCallback(ctx tb.Context) error {
 	switch callbackData[0] {
 	case "main": // User requested main settings menu
 		// Load keyboard based on chat type
-		sendOptions, _ := tg.Template.Keyboard.Settings.Main(isGroup(cb.Message.Chat))
+		sendOptions, _ := tg.Template.Keyboard.Settings.Main(isGroup(cb.Message.Chat), tg.CalendarFeedURL != "")
 
 		// Init text so we don't need to run it twice thorugh the markdown escaper
-		message := tg.Template.Messages.Settings.Main(isGroup(cb.Message.Chat))
+		message := tg.Template.Messages.Settings.Main(isGroup(cb.Message.Chat), tg.CalendarFeedURL != "")
 		message = utils.PrepareInputForMarkdown(message, "text")
 
 		if len(callbackData) == 2 && callbackData[1] == "newMessage" {
@@ -1018,6 +1229,10 @@ func (tg *Bot) settingsCallback(ctx tb.Context) error {
 			chat.DeleteTimeZone()
 			tg.Db.SaveUser(chat)
 
+			if chat.DigestFrequency != users.DigestOff {
+				tg.digestSettingsChanged()
+			}
+
 			// Message
 			message := tg.Template.Messages.Settings.TimeZone.Deleted(chat.SavedTimeZoneInfo())
 			message = utils.PrepareInputForMarkdown(message, "text")
@@ -1042,6 +1257,30 @@ func (tg *Bot) settingsCallback(ctx tb.Context) error {
 
 			tg.editCbMessage(cb, message, sendOptions)
 			return tg.respondToCallback(ctx, "⏰ Loaded notification time settings", false)
+		case "leadtime":
+			// Send prompt with ForceReply, matched by its text in textMessageHandler
+			message := tg.Template.Messages.Settings.LeadTimePrompt()
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			msg := sendables.Message{
+				TextContent: message,
+				SendOptions: tb.SendOptions{
+					ParseMode:   "MarkdownV2",
+					ReplyMarkup: &tb.ReplyMarkup{ForceReply: true, Selective: true},
+				},
+			}
+
+			sendable := sendables.Sendable{
+				Type:    sendables.Command,
+				Message: &msg,
+			}
+			sendable.AddRecipient(chat, false)
+			tg.Enqueue(&sendable, true)
+
+			// Delete the callback message to keep chat clean
+			_ = tg.Bot.Delete(cb.Message)
+
+			return tg.respondToCallback(ctx, "📝 Please enter a notification time", false)
 		case "bycountry":
 			// Dynamically generated notification preferences
 			sendOptions, _ := tg.Template.Keyboard.Settings.Subscription.Main(chat)
@@ -1052,6 +1291,25 @@ func (tg *Bot) settingsCallback(ctx tb.Context) error {
 
 			tg.editCbMessage(cb, message, sendOptions)
 			return tg.respondToCallback(ctx, "🔔 Notification settings loaded", false)
+		case "bysite":
+			// Launch sites seen in API updates
+			sendOptions, _ := tg.Template.Keyboard.Settings.Subscription.BySite(chat, tg.Db.LaunchSites())
+
+			message := tg.Template.Messages.Settings.Subscription.BySite()
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			tg.editCbMessage(cb, message, sendOptions)
+			return tg.respondToCallback(ctx, "📍 Launch site settings loaded", false)
+		case "byvehicle":
+			// Vehicles and families of the cached launches
+			sendOptions, _ := tg.Template.Keyboard.Settings.Subscription.ByVehicle(
+				chat, tg.Cache.RocketFamilies(), tg.Cache.Vehicles())
+
+			message := tg.Template.Messages.Settings.Subscription.ByVehicle()
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			tg.editCbMessage(cb, message, sendOptions)
+			return tg.respondToCallback(ctx, "🚀 Rocket settings loaded", false)
 		}
 
 	case "group":
@@ -1066,6 +1324,116 @@ func (tg *Bot) settingsCallback(ctx tb.Context) error {
 		tg.editCbMessage(cb, text, sendOptions)
 		return tg.respondToCallback(ctx, "👷 Loaded group settings", false)
 
+	case "digest":
+		// Launch digest settings
+		if len(callbackData) < 2 {
+			return tg.respondToCallback(ctx, "⚠️ Invalid data", true)
+		}
+
+		cbText := "📰 Loaded launch digest settings"
+
+		switch callbackData[1] {
+		case "main":
+		case "freq":
+			frequency := users.DigestFrequency(strings.Replace(callbackData[len(callbackData)-1], "off", "", 1))
+
+			if len(callbackData) < 3 || !chat.SetDigestFrequency(frequency) {
+				return tg.respondToCallback(ctx, "⚠️ Invalid data", true)
+			}
+
+			cbText = "📰 Launch digest updated"
+		case "hour", "day":
+			value, err := strconv.Atoi(callbackData[len(callbackData)-1])
+
+			if len(callbackData) < 3 || err != nil {
+				return tg.respondToCallback(ctx, "⚠️ Invalid data", true)
+			}
+
+			if callbackData[1] == "hour" {
+				chat.SetDigestHour(value)
+			} else {
+				chat.SetDigestWeekday(value)
+			}
+
+			cbText = "📰 Launch digest updated"
+		default:
+			return tg.respondToCallback(ctx, "⚠️ Invalid data", true)
+		}
+
+		if callbackData[1] != "main" {
+			tg.Db.SaveUser(chat)
+			tg.digestSettingsChanged()
+		}
+
+		message := tg.Template.Messages.Settings.Digest.Main(chat)
+		message = utils.PrepareInputForMarkdown(message, "text")
+		sendOptions, _ := tg.Template.Keyboard.Settings.Digest.Main(chat)
+
+		tg.editCbMessage(cb, message, sendOptions)
+		return tg.respondToCallback(ctx, cbText, false)
+
+	case "quiet":
+		// Quiet hours settings
+		if len(callbackData) < 2 {
+			return tg.respondToCallback(ctx, "⚠️ Invalid data", true)
+		}
+
+		switch callbackData[1] {
+		case "main":
+			message := tg.Template.Messages.Settings.QuietHours.Main(chat)
+			message = utils.PrepareInputForMarkdown(message, "text")
+			sendOptions, _ := tg.Template.Keyboard.Settings.QuietHours.Main(chat)
+
+			tg.editCbMessage(cb, message, sendOptions)
+			return tg.respondToCallback(ctx, "🌙 Loaded quiet hours", false)
+
+		case "off":
+			chat.QuietHours = ""
+			tg.Db.SaveUser(chat)
+
+			message := tg.Template.Messages.Settings.QuietHours.Main(chat)
+			message = utils.PrepareInputForMarkdown(message, "text")
+			sendOptions, _ := tg.Template.Keyboard.Settings.QuietHours.Main(chat)
+
+			tg.editCbMessage(cb, message, sendOptions)
+			return tg.respondToCallback(ctx, "🌙 Quiet hours turned off", false)
+
+		case "policy":
+			if len(callbackData) < 3 || !chat.SetQuietPolicy(users.QuietPolicy(callbackData[2])) {
+				return tg.respondToCallback(ctx, "⚠️ Invalid data", true)
+			}
+
+			tg.Db.SaveUser(chat)
+
+			_, kb := tg.Template.Keyboard.Settings.QuietHours.Main(chat)
+			sent, err := tg.Bot.EditReplyMarkup(cb.Message, &tb.ReplyMarkup{InlineKeyboard: kb})
+
+			if err != nil {
+				if !tg.handleError(nil, sent, err, ctx.Chat().ID) {
+					return errors.New("Updating the quiet hours keyboard failed")
+				}
+			}
+
+			return tg.respondToCallback(ctx, "🌙 Quiet hours policy updated", false)
+
+		case "setprompt":
+			message := tg.Template.Messages.Settings.QuietHours.SetPrompt()
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			msg := sendables.Message{
+				TextContent: message,
+				SendOptions: tb.SendOptions{
+					ParseMode:   "MarkdownV2",
+					ReplyMarkup: &tb.ReplyMarkup{ForceReply: true, Selective: true},
+				},
+			}
+
+			tg.enqueueCommand(&msg, chat, ctx)
+
+			_ = tg.Bot.Delete(cb.Message)
+			return tg.respondToCallback(ctx, "📝 Please enter your quiet hours", false)
+		}
+
 	case "topic":
 		if !isGroup(ctx.Chat()) {
 			return tg.respondToCallback(ctx, "Topics are only available in groups", true)
@@ -1124,6 +1492,95 @@ func (tg *Bot) settingsCallback(ctx tb.Context) error {
 			tg.editCbMessage(cb, message, sendOptions)
 			return tg.respondToCallback(ctx, "🔍 Keyword filters loaded", false)
 		}
+
+	case "mission":
+		// Orbit and mission type filter settings
+		switch callbackData[1] {
+		case "main":
+			message := tg.Template.Messages.Settings.Mission.Main(chat)
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			sendOptions, _ := tg.Template.Keyboard.Settings.Mission.Main(chat)
+
+			tg.editCbMessage(cb, message, sendOptions)
+			return tg.respondToCallback(ctx, "🛰️ Loaded orbit & mission filters", false)
+
+		case "orbit", "type":
+			message := tg.Template.Messages.Settings.Mission.Values(callbackData[1])
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			sendOptions, _ := tg.Template.Keyboard.Settings.Mission.Values(
+				chat, callbackData[1], tg.Cache.MissionFilterValues(callbackData[1], chat))
+
+			tg.editCbMessage(cb, message, sendOptions)
+			return tg.respondToCallback(ctx, "🛰️ Loaded filters", false)
+		}
+
+	case "webcast":
+		// Webcast language and source settings
+		cbText := "📺 Loaded webcast preferences"
+
+		if callbackData[1] == "toggle" && len(callbackData) == 4 {
+			newState := utils.BinStringStateToBool[callbackData[3]]
+			chat.ToggleWebcastLanguage(callbackData[2], newState)
+			tg.Db.SaveUser(chat)
+
+			cbText = fmt.Sprintf("📺 Webcast language %s %s", strings.ToUpper(callbackData[2]),
+				strings.ToLower(utils.BoolStateString[newState]))
+		} else if callbackData[1] == "source" && len(callbackData) == 3 {
+			source := users.WebcastSource(callbackData[2])
+
+			if source == "any" {
+				source = users.WebcastAnySource
+			}
+
+			if chat.SetWebcastSource(source) {
+				tg.Db.SaveUser(chat)
+				cbText = fmt.Sprintf("📺 Webcast source set to %s", callbackData[2])
+			}
+		}
+
+		message := tg.Template.Messages.Settings.Webcast.Main(chat)
+		message = utils.PrepareInputForMarkdown(message, "text")
+
+		sendOptions, _ := tg.Template.Keyboard.Settings.Webcast.Main(chat)
+
+		tg.editCbMessage(cb, message, sendOptions)
+		return tg.respondToCallback(ctx, cbText, false)
+
+	case "calendar":
+		// Calendar feed settings
+		if tg.CalendarFeedURL == "" || len(callbackData) < 2 {
+			return tg.respondToCallback(ctx, "⚠️ Calendar feeds are not available", true)
+		}
+
+		cbText := "🗓️ Loaded calendar feed settings"
+
+		switch callbackData[1] {
+		case "main":
+		case "generate":
+			if err := chat.GenerateCalendarToken(); err != nil {
+				log.Error().Err(err).Msgf("Generating a calendar token for chat=%s failed", chat.Id)
+				return tg.respondToCallback(ctx, "⚠️ Creating a feed link failed, please try again", true)
+			}
+
+			tg.Db.SaveUser(chat)
+			cbText = "🗓️ New feed link created"
+		case "revoke":
+			chat.RevokeCalendarToken()
+			tg.Db.SaveUser(chat)
+			cbText = "🗓️ Feed link revoked"
+		default:
+			return tg.respondToCallback(ctx, "⚠️ Invalid data", true)
+		}
+
+		message := tg.Template.Messages.Settings.Calendar.Main(chat, tg.CalendarFeedURL)
+		message = utils.PrepareInputForMarkdown(message, "italictext")
+
+		sendOptions, _ := tg.Template.Keyboard.Settings.Calendar.Main(chat)
+
+		tg.editCbMessage(cb, message, sendOptions)
+		return tg.respondToCallback(ctx, cbText, false)
 	}
 
 	return nil
@@ -1313,6 +1770,96 @@ func (tg *Bot) keywordsCallback(ctx tb.Context) error {
 			return tg.respondToCallback(ctx, "📝 Please enter a keyword", false)
 		}
 
+	case "rules":
+		if len(callbackData) < 2 {
+			return tg.respondToCallback(ctx, "⚠️ Invalid data", true)
+		}
+
+		switch callbackData[1] {
+		case "view":
+			message := tg.Template.Messages.Settings.Keywords.Rules(chat)
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			sendOptions, _ := tg.Template.Keyboard.Settings.Keywords.Rules(chat)
+
+			tg.editCbMessage(cb, message, sendOptions)
+			return tg.respondToCallback(ctx, "📐 Filter rules loaded", false)
+
+		case "remove":
+			if len(callbackData) < 4 {
+				return tg.respondToCallback(ctx, "⚠️ Invalid data", true)
+			}
+
+			action := callbackData[2]
+			index, err := strconv.Atoi(callbackData[3])
+
+			if err != nil || !chat.RemoveRule(action, index) {
+				return tg.respondToCallback(ctx, "⚠️ Rule not found", true)
+			}
+
+			tg.Db.SaveUser(chat)
+			log.Info().Str("user", chat.Id).Str("action", action).Int("index", index).Msg("User removed a filter rule")
+
+			message := tg.Template.Messages.Settings.Keywords.Rules(chat)
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			sendOptions, _ := tg.Template.Keyboard.Settings.Keywords.Rules(chat)
+
+			tg.editCbMessage(cb, message, sendOptions)
+			return tg.respondToCallback(ctx, "✅ Rule removed", true)
+
+		case "add":
+			if len(callbackData) < 3 || (callbackData[2] != "block" && callbackData[2] != "allow") {
+				return tg.respondToCallback(ctx, "⚠️ Invalid data", true)
+			}
+
+			// Send prompt with ForceReply, matched by its text in textMessageHandler
+			message := tg.Template.Messages.Settings.Keywords.RuleAddPrompt(callbackData[2])
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			msg := sendables.Message{
+				TextContent: message,
+				SendOptions: tb.SendOptions{
+					ParseMode:   "MarkdownV2",
+					ReplyMarkup: &tb.ReplyMarkup{ForceReply: true, Selective: true},
+				},
+			}
+
+			sendable := sendables.Sendable{
+				Type:    sendables.Command,
+				Message: &msg,
+			}
+			sendable.AddRecipient(chat, false)
+			tg.Enqueue(&sendable, true)
+
+			// Delete the callback message to keep chat clean
+			_ = tg.Bot.Delete(cb.Message)
+
+			return tg.respondToCallback(ctx, "📝 Please enter a rule", false)
+
+		case "help":
+			message := tg.Template.Messages.Settings.Keywords.RulesHelp()
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			kb := [][]tb.InlineButton{{
+				tb.InlineButton{
+					Unique: "keywords",
+					Text:   "⬅️ Back",
+					Data:   "rules/view",
+				},
+			}}
+
+			sendOptions := tb.SendOptions{
+				ParseMode:             "MarkdownV2",
+				DisableWebPagePreview: true,
+				ReplyMarkup:           &tb.ReplyMarkup{InlineKeyboard: kb},
+				Protected:             true,
+			}
+
+			tg.editCbMessage(cb, message, sendOptions)
+			return tg.respondToCallback(ctx, "❓ Rule syntax loaded", false)
+		}
+
 	case "help":
 		message := tg.Template.Messages.Settings.Keywords.Help()
 		message = utils.PrepareInputForMarkdown(message, "text")
@@ -1376,7 +1923,7 @@ func (tg *Bot) expandMessageContent(ctx tb.Context) error {
 	}
 
 	// Get text for this launch
-	newText := launch.NotificationMessage(notification, true, tg.Username)
+	newText := launch.NotificationMessage(notification, true, tg.Username, tg.Cache.Now())
 	newText = sendables.SetTime(newText, chat, launch.NETUnix, true, false, false)
 
 	// For channels, replace the footer with a "Powered by LaunchBot" text
@@ -1459,6 +2006,11 @@ func (tg *Bot) locationReplyHandler(ctx tb.Context) error {
 	chat.Locale = locale
 	tg.Db.SaveUser(chat)
 
+	// The chat's digest moves to its new time zone
+	if chat.DigestFrequency != users.DigestOff {
+		tg.digestSettingsChanged()
+	}
+
 	log.Info().Msgf("Saved locale=%s for chat=%s", locale, chat.Id)
 
 	// Notify user of success
@@ -1600,6 +2152,180 @@ func (tg *Bot) textMessageHandler(ctx tb.Context) error {
 		return nil
 	}
 
+	// Check if replying to a filter rule prompt
+	if strings.Contains(replyText, "Send me the filter rule") {
+		if isGroup(ctx.Chat()) {
+			senderIsAdmin, err := tg.senderIsAdmin(ctx)
+			if err != nil || !senderIsAdmin {
+				return nil
+			}
+		}
+
+		chat := tg.Cache.FindUser(fmt.Sprint(ctx.Chat().ID), "tg")
+
+		action := "allow"
+		if strings.Contains(replyText, "want to block") {
+			action = "block"
+		}
+
+		_ = tg.Bot.Delete(ctx.Message())
+
+		rule, err := chat.AddRule(action, ctx.Text())
+
+		if err != nil {
+			// Explain what went wrong, and prompt for the rule again
+			log.Debug().Err(err).Str("user", chat.Id).Msg("User sent an invalid filter rule")
+
+			message := tg.Template.Messages.Settings.Keywords.RuleInvalid(err, action)
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			msg := sendables.Message{
+				TextContent: message,
+				SendOptions: tb.SendOptions{
+					ParseMode:   "MarkdownV2",
+					ReplyMarkup: &tb.ReplyMarkup{ForceReply: true, Selective: true},
+				},
+			}
+
+			sendable := sendables.Sendable{Type: sendables.Command, Message: &msg}
+			sendable.AddRecipient(chat, false)
+			tg.Enqueue(&sendable, true)
+			return nil
+		}
+
+		tg.Db.SaveUser(chat)
+		log.Info().Str("user", chat.Id).Str("action", action).Str("rule", rule.Source).Msg("User added a filter rule")
+
+		message := tg.Template.Messages.Settings.Keywords.Rules(chat)
+		message = utils.PrepareInputForMarkdown(message, "text")
+		sendOptions, _ := tg.Template.Keyboard.Settings.Keywords.Rules(chat)
+
+		if ctx.Message().ReplyTo != nil {
+			_, err := tg.Bot.Edit(ctx.Message().ReplyTo, message, &sendOptions)
+			if err != nil {
+				_ = tg.Bot.Delete(ctx.Message().ReplyTo)
+				msg := sendables.Message{TextContent: message, SendOptions: sendOptions}
+				sendable := sendables.Sendable{Type: sendables.Command, Message: &msg}
+				sendable.AddRecipient(chat, false)
+				tg.Enqueue(&sendable, true)
+			}
+		}
+
+		return nil
+	}
+
+	// Check if replying to a quiet hours prompt
+	if strings.Contains(replyText, "Send me your quiet hours") {
+		if isGroup(ctx.Chat()) {
+			senderIsAdmin, err := tg.senderIsAdmin(ctx)
+			if err != nil || !senderIsAdmin {
+				return nil
+			}
+		}
+
+		chat := tg.Cache.FindUser(fmt.Sprint(ctx.Chat().ID), "tg")
+
+		_ = tg.Bot.Delete(ctx.Message())
+
+		if err := chat.SetQuietHours(ctx.Text()); err != nil {
+			// Explain what went wrong, and prompt for the hours again
+			log.Debug().Err(err).Str("user", chat.Id).Msg("User sent invalid quiet hours")
+
+			message := tg.Template.Messages.Settings.QuietHours.Invalid(err)
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			msg := sendables.Message{
+				TextContent: message,
+				SendOptions: tb.SendOptions{
+					ParseMode:   "MarkdownV2",
+					ReplyMarkup: &tb.ReplyMarkup{ForceReply: true, Selective: true},
+				},
+			}
+
+			tg.enqueueCommand(&msg, chat, ctx)
+			return nil
+		}
+
+		tg.Db.SaveUser(chat)
+		log.Info().Str("user", chat.Id).Str("quiet_hours", chat.QuietHours).Msg("User set quiet hours")
+
+		message := tg.Template.Messages.Settings.QuietHours.Main(chat)
+		message = utils.PrepareInputForMarkdown(message, "text")
+		sendOptions, _ := tg.Template.Keyboard.Settings.QuietHours.Main(chat)
+
+		if ctx.Message().ReplyTo != nil {
+			_, err := tg.Bot.Edit(ctx.Message().ReplyTo, message, &sendOptions)
+			if err != nil {
+				_ = tg.Bot.Delete(ctx.Message().ReplyTo)
+				msg := sendables.Message{TextContent: message, SendOptions: sendOptions}
+				tg.enqueueCommand(&msg, chat, ctx)
+			}
+		}
+
+		return nil
+	}
+
+	// Check if replying to a notification time prompt
+	if strings.Contains(replyText, "Send me the notification time") {
+		if isGroup(ctx.Chat()) {
+			senderIsAdmin, err := tg.senderIsAdmin(ctx)
+			if err != nil || !senderIsAdmin {
+				return nil
+			}
+		}
+
+		chat := tg.Cache.FindUser(fmt.Sprint(ctx.Chat().ID), "tg")
+
+		_ = tg.Bot.Delete(ctx.Message())
+
+		leadTime, err := users.ParseLeadTime(ctx.Text())
+
+		if err == nil {
+			err = chat.SetLeadTime(leadTime, true)
+		}
+
+		if err != nil {
+			// Explain what went wrong, and prompt for the time again
+			log.Debug().Err(err).Str("user", chat.Id).Msg("User sent an invalid notification time")
+
+			message := tg.Template.Messages.Settings.LeadTimeInvalid(err)
+			message = utils.PrepareInputForMarkdown(message, "text")
+
+			msg := sendables.Message{
+				TextContent: message,
+				SendOptions: tb.SendOptions{
+					ParseMode:   "MarkdownV2",
+					ReplyMarkup: &tb.ReplyMarkup{ForceReply: true, Selective: true},
+				},
+			}
+
+			sendable := sendables.Sendable{Type: sendables.Command, Message: &msg}
+			sendable.AddRecipient(chat, false)
+			tg.Enqueue(&sendable, true)
+			return nil
+		}
+
+		tg.Db.SaveUser(chat)
+		log.Info().Str("user", chat.Id).Str("lead_time", users.LeadTimeType(leadTime)).Msg("User added a notification time")
+
+		message := tg.Template.Messages.Settings.Notifications()
+		message = utils.PrepareInputForMarkdown(message, "text")
+		sendOptions, _ := tg.Template.Keyboard.Settings.Notifications(chat)
+
+		if ctx.Message().ReplyTo != nil {
+			_, err := tg.Bot.Edit(ctx.Message().ReplyTo, message, &sendOptions)
+			if err != nil {
+				_ = tg.Bot.Delete(ctx.Message().ReplyTo)
+				msg := sendables.Message{TextContent: message, SendOptions: sendOptions}
+				sendable := sendables.Sendable{Type: sendables.Command, Message: &msg}
+				sendable.AddRecipient(chat, false)
+				tg.Enqueue(&sendable, true)
+			}
+		}
+
+		return nil
+	}
+
 	// Check if it's a keyword input prompt
 	if !strings.Contains(replyText, "Send me the keyword") {
 		return nil
@@ -1911,7 +2637,7 @@ func (tg *Bot) testNotifConfirmCallback(ctx tb.Context) error {
 
 	notifType := "1h"
 
-	text := launch.NotificationMessage(notifType, false, tg.Username)
+	text := launch.NotificationMessage(notifType, false, tg.Username, tg.Cache.Now())
 	kb := launch.TelegramNotificationKeyboard(notifType)
 
 	// Message
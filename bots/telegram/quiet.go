@@ -0,0 +1,257 @@
+package telegram
+
+import (
+	"encoding/json"
+	"launchbot/db"
+	"launchbot/sendables"
+	"launchbot/users"
+	"launchbot/utils"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	tb "gopkg.in/telebot.v3"
+)
+
+// Ensures only one delivery of held notifications runs at a time
+var quietHoursDelivery sync.Mutex
+
+/*
+Holds back a notification from the recipients that are in their quiet hours,
+according to each chat's quiet hours policy, and returns the recipients the
+notification can be sent to right away. Held notifications are saved in the
+database, and delivered by DeliverQuietHourNotifications when quiet hours end.
+*/
+func (tg *Bot) filterQuietRecipients(sendable *sendables.Sendable) []*users.User {
+	now := tg.Cache.Now()
+
+	recipients := make([]*users.User, 0, len(sendable.Recipients))
+	deferred := []*db.DeferredNotification{}
+	dropped := 0
+
+	for _, chat := range sendable.Recipients {
+		if !chat.InQuietHours(now) {
+			recipients = append(recipients, chat)
+			continue
+		}
+
+		if chat.QuietHoursPolicy() == users.QuietDrop {
+			dropped++
+			continue
+		}
+
+		// Keep the chat's preferred webcast, as the webcasts are not stored
+		text, opts := sendables.SetPreferredWebcast(sendable.Message.TextContent, sendable.Message.SendOptions, sendable.Message, chat)
+
+		notification := &db.DeferredNotification{
+			ChatId:           chat.Id,
+			Platform:         chat.Platform,
+			LaunchId:         sendable.LaunchId,
+			NotificationType: sendable.NotificationType,
+			Text:             text,
+			DeliverAt:        chat.QuietHoursEnd(now),
+		}
+
+		if sendable.Message.AddUserTime {
+			notification.RefTime = sendable.Message.RefTime
+		}
+
+		if opts.ReplyMarkup != nil && len(opts.ReplyMarkup.InlineKeyboard) != 0 {
+			keyboard, err := json.Marshal(opts.ReplyMarkup.InlineKeyboard)
+
+			if err != nil {
+				log.Error().Err(err).Msg("Encoding the keyboard of a held notification failed")
+			} else {
+				notification.Keyboard = string(keyboard)
+			}
+		}
+
+		if launch, err := tg.Cache.FindLaunchById(sendable.LaunchId); err == nil {
+			notification.LaunchName = launch.Name
+			notification.LaunchNET = launch.NETUnix
+		}
+
+		deferred = append(deferred, notification)
+	}
+
+	if len(deferred) != 0 {
+		if err := tg.Db.DeferNotifications(deferred); err != nil {
+			log.Error().Err(err).Msgf("Saving %d held notification(s) failed", len(deferred))
+		}
+	}
+
+	if len(deferred) != 0 || dropped != 0 {
+		log.Info().Msgf("Quiet hours: held back %d and dropped %d notification(s) of type=%s (launch=%s)",
+			len(deferred), dropped, sendable.NotificationType, sendable.LaunchId)
+	}
+
+	return recipients
+}
+
+// Delivers the notifications held back during quiet hours that have ended. Depending on the
+// chat's quiet hours policy, the notifications are delivered as-is, or summarized in one message.
+func (tg *Bot) DeliverQuietHourNotifications() {
+	if !quietHoursDelivery.TryLock() {
+		log.Debug().Msg("Held notifications are already being delivered")
+		return
+	}
+
+	defer quietHoursDelivery.Unlock()
+
+	now := tg.Cache.Now()
+	due, err := tg.Db.DueDeferredNotifications(now)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Loading held notifications failed")
+		return
+	}
+
+	if len(due) == 0 {
+		return
+	}
+
+	// Notifications are ordered by chat: deliver each chat's notifications together
+	delivered := []*db.DeferredNotification{}
+
+	for start := 0; start < len(due); {
+		end := start
+		for end < len(due) && due[end].ChatId == due[start].ChatId && due[end].Platform == due[start].Platform {
+			end++
+		}
+
+		chatNotifications := due[start:end]
+		start = end
+
+		chat := tg.Cache.FindUser(chatNotifications[0].ChatId, chatNotifications[0].Platform)
+
+		if chat.InQuietHours(now) {
+			// The chat changed its quiet hours: deliver once the new quiet hours end
+			continue
+		}
+
+		switch chat.QuietHoursPolicy() {
+		case users.QuietSummary:
+			tg.sendQuietHoursSummary(chat, chatNotifications)
+		case users.QuietDrop:
+			log.Debug().Msgf("Chat=%s now drops quiet hour notifications: dropping %d held notification(s)",
+				chat.Id, len(chatNotifications))
+		default:
+			// Notifications that went stale while held are summarized, instead of sending outdated text
+			stale := []*db.DeferredNotification{}
+
+			for _, notification := range chatNotifications {
+				if tg.heldNotificationStale(notification, now) {
+					stale = append(stale, notification)
+				} else {
+					tg.sendHeldNotification(chat, notification)
+				}
+			}
+
+			if len(stale) != 0 {
+				tg.sendQuietHoursSummary(chat, stale)
+			}
+		}
+
+		delivered = append(delivered, chatNotifications...)
+	}
+
+	if err := tg.Db.RemoveDeferredNotifications(delivered); err != nil {
+		log.Error().Err(err).Msg("Removing delivered held notifications failed")
+	}
+
+	log.Info().Msgf("Delivered %d notification(s) held back during quiet hours", len(delivered))
+}
+
+// Returns true if a held lead time notification is no longer accurate: the launch is gone,
+// its NET has passed, or its NET has moved since the notification was held
+func (tg *Bot) heldNotificationStale(notification *db.DeferredNotification, now time.Time) bool {
+	if _, ok := users.LeadTimeOfType(notification.NotificationType); !ok {
+		return false
+	}
+
+	launch, err := tg.Cache.FindLaunchById(notification.LaunchId)
+
+	if err != nil {
+		return true
+	}
+
+	if launch.NETUnix <= now.Unix() || (notification.LaunchNET != 0 && launch.NETUnix != notification.LaunchNET) {
+		log.Debug().Msgf("Held notification of type=%s for launch=%s is stale: summarizing",
+			notification.NotificationType, launch.Slug)
+		return true
+	}
+
+	return false
+}
+
+// Sends a single notification held back during quiet hours
+func (tg *Bot) sendHeldNotification(chat *users.User, notification *db.DeferredNotification) {
+	msg := sendables.Message{
+		TextContent: tg.Template.Messages.Service.QuietHoursHeld() + notification.Text,
+		AddUserTime: notification.RefTime != 0,
+		RefTime:     notification.RefTime,
+		SendOptions: tb.SendOptions{ParseMode: "MarkdownV2"},
+	}
+
+	if notification.Keyboard != "" {
+		keyboard := [][]tb.InlineButton{}
+
+		if err := json.Unmarshal([]byte(notification.Keyboard), &keyboard); err != nil {
+			log.Error().Err(err).Msg("Decoding the keyboard of a held notification failed")
+		} else {
+			msg.SendOptions.ReplyMarkup = &tb.ReplyMarkup{InlineKeyboard: keyboard}
+		}
+	}
+
+	tg.sendQuietHoursMessage(chat, &msg, notification.NotificationType, notification.LaunchId)
+}
+
+// Sends one message summarizing the notifications held back during quiet hours
+func (tg *Bot) sendQuietHoursSummary(chat *users.User, notifications []*db.DeferredNotification) {
+	// The launches' current NETs, if they are still cached
+	nets := map[string]int64{}
+
+	for _, notification := range notifications {
+		if launch, err := tg.Cache.FindLaunchById(notification.LaunchId); err == nil {
+			nets[notification.LaunchId] = launch.NETUnix
+		}
+	}
+
+	text := tg.Template.Messages.Service.QuietHoursSummary(chat, notifications, nets)
+
+	msg := sendables.Message{
+		TextContent: utils.PrepareInputForMarkdown(text, "text"),
+		SendOptions: tb.SendOptions{ParseMode: "MarkdownV2"},
+	}
+
+	tg.sendQuietHoursMessage(chat, &msg, "summary", "")
+}
+
+// Sends a message through the notification sender, so e.g. forum topics are respected. Sent
+// messages are post-processed like other notifications, so the launch's sent message IDs include
+// them: outcome and hold notifications find the chat, and the next notification removes the message.
+func (tg *Bot) sendQuietHoursMessage(chat *users.User, msg *sendables.Message, notificationType string, launchId string) {
+	sendable := sendables.Sendable{
+		Type:             sendables.Notification,
+		NotificationType: notificationType,
+		LaunchId:         launchId,
+		Recipients:       []*users.User{chat},
+		Message:          msg,
+		Tokens:           1,
+	}
+
+	tg.Spam.GlobalLimiter(sendable.Tokens)
+
+	idPair, success := tg.SendNotification(&sendable, chat, 0)
+
+	if !success {
+		log.Warn().Msgf("Delivering held notification(s) to chat=%s failed", chat.Id)
+		return
+	}
+
+	chat.Stats.ReceivedNotifications++
+
+	// Post-processing marks itself done in the sender's wait group
+	tg.Quit.WaitGroup.Add(1)
+	tg.NotificationPostProcessing(&sendable, []string{idPair})
+}
@@ -1,6 +1,7 @@
 package telegram
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"launchbot/sendables"
@@ -52,11 +53,20 @@ func (tg *Bot) NotificationPostProcessing(sendable *sendables.Sendable, sentIds
 	tg.Stats.Notifications += len(sentIds)
 	tg.Db.SaveStatsToDisk(tg.Stats)
 
+	// Notifications not about a single launch, e.g. digests, have no IDs to save
+	if sendable.LaunchId == "" {
+		log.Debug().Msgf("Notification post-processing completed (type=%s, no launch)", sendable.NotificationType)
+		tg.Quit.WaitGroup.Done()
+		return
+	}
+
 	// Load launch from cache so we can save the IDs
 	launch, err := tg.Cache.FindLaunchById(sendable.LaunchId)
 
 	if err != nil {
 		log.Error().Err(err).Msgf("Unable to find launch while saving sent message IDs")
+		tg.Quit.WaitGroup.Done()
+		return
 	}
 
 	// Persist old notification IDs, if the user is not a current recipient
@@ -111,6 +121,11 @@ func (tg *Bot) NotificationPostProcessing(sendable *sendables.Sendable, sentIds
 		time.Sleep(250 * time.Millisecond)
 	}
 
+	// Track the chats that received a final notification, so they are sent the outcome
+	if leadTime, ok := users.LeadTimeOfType(sendable.NotificationType); ok && leadTime <= users.FinalLeadTime {
+		launch.AddFinalNotifiedChats(sentIds)
+	}
+
 	// Save the IDs of the sent notifications
 	log.Debug().Msg("Saving sent notification IDs")
 	launch.SaveSentNotificationIds(filteredNotificationIds, tg.Db)
@@ -129,6 +144,11 @@ func (tg *Bot) ProcessSendable(sendable *sendables.Sendable, workPool chan Messa
 	// Track average processing time for notifications and message deletions
 	processStartTime := time.Now()
 
+	// Hold back notifications from chats in their quiet hours
+	if sendable.Type == sendables.Notification {
+		sendable.Recipients = tg.filterQuietRecipients(sendable)
+	}
+
 	// Create the results channel
 	results := make(chan string, len(sendable.Recipients))
 
@@ -264,8 +284,23 @@ func (tg *Bot) SendCommand(message *sendables.Message, chat *users.User) bool {
 
 	id, _ := strconv.ParseInt(chat.Id, 10, 64)
 
+	var (
+		sent *tb.Message
+		err  error
+	)
+
 	// FUTURE use sendable.Send()
-	sent, err := tg.Bot.Send(tb.ChatID(id), text, &message.SendOptions)
+	if message.Attachment != nil {
+		// Send the file as a document, with the text as its caption
+		sent, err = tg.Bot.Send(tb.ChatID(id), &tb.Document{
+			File:     tb.FromReader(bytes.NewReader(message.Attachment.Content)),
+			FileName: message.Attachment.FileName,
+			MIME:     message.Attachment.MIME,
+			Caption:  text,
+		}, &message.SendOptions)
+	} else {
+		sent, err = tg.Bot.Send(tb.ChatID(id), text, &message.SendOptions)
+	}
 
 	if err != nil {
 		if !tg.handleError(nil, sent, err, int64(id)) {
@@ -529,6 +564,9 @@ func (tg *Bot) SendNotification(sendable *sendables.Sendable, user *users.User,
 		opts.ThreadID = int(user.TopicId)
 	}
 
+	// Use the chat's preferred webcast, if the launch has one in their language
+	text, opts = sendables.SetPreferredWebcast(text, opts, sendable.Message, user)
+
 	// Send message
 	sent, err := tg.Bot.Send(tb.ChatID(id), text, &opts)
 
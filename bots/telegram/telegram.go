@@ -30,6 +30,10 @@ type Bot struct {
 	Template          templates.Telegram
 	Username          string
 	Owner             int64
+	ApiBudget         fmt.Stringer // State of the API request budget, shown in /admin
+	ApiURL            string       // URL of the Bot API server (optional, defaults to Telegram's)
+	RescheduleDigests func()       // Re-schedules digest jobs after a chat's digest settings change (optional)
+	CalendarFeedURL   string       // Base URL of chats' calendar feeds (empty = feeds disabled)
 }
 
 // Quit is used to manage a graceful shutdown flow
@@ -51,6 +55,7 @@ const (
 	Statistics Command = "statistics"
 	Settings   Command = "settings"
 	Feedback   Command = "feedback"
+	Calendar   Command = "calendar"
 )
 
 // Command descriptions, in case we need to manually register them
@@ -60,10 +65,11 @@ var commandDescriptions = map[Command]string{
 	Statistics: "📊 LaunchBot statistics",
 	Settings:   "🔔 Notification settings",
 	Feedback:   "✍️ Send feedback to developer",
+	Calendar:   "🗓️ Export launches to your calendar",
 }
 
 // A list of all registered (public) commands: we may still handle non-public commands.
-var Commands = [5]Command{Next, Schedule, Statistics, Settings, Feedback}
+var Commands = [6]Command{Next, Schedule, Calendar, Statistics, Settings, Feedback}
 
 // Simple method to initialize the TelegramBot object
 func (tg *Bot) Initialize(token string) {
@@ -80,6 +86,7 @@ func (tg *Bot) Initialize(token string) {
 	transport.MaxIdleConnsPerHost = 10
 
 	tg.Bot, err = tb.NewBot(tb.Settings{
+		URL:    tg.ApiURL,
 		Token:  token,
 		Poller: &tb.LongPoller{Timeout: time.Second * 60},
 		Client: &http.Client{
@@ -102,11 +109,13 @@ func (tg *Bot) Initialize(token string) {
 	tg.Bot.Handle("/start", tg.permissionedStart)
 	tg.Bot.Handle("/next", tg.nextHandler)
 	tg.Bot.Handle("/schedule", tg.scheduleHandler)
+	tg.Bot.Handle("/calendar", tg.calendarHandler)
 	tg.Bot.Handle("/statistics", tg.statsHandler)
 	tg.Bot.Handle("/settings", tg.settingsHandler)
 	tg.Bot.Handle("/feedback", tg.feedbackHandler)
 	tg.Bot.Handle("/admin", tg.adminCommand)
 	tg.Bot.Handle("/reply", tg.adminReply)
+	tg.Bot.Handle("/provider", tg.adminProvider)
 	tg.Bot.Handle("/broadcast", tg.broadcastHandler)
 
 	// Handler for fake notification requests
@@ -275,6 +284,8 @@ func (tg *Bot) channelProcessor(ctx tb.Context) error {
 		_ = tg.nextHandler(ctx)
 	case Schedule:
 		_ = tg.scheduleHandler(ctx)
+	case Calendar:
+		_ = tg.calendarHandler(ctx)
 	case Statistics:
 		_ = tg.statsHandler(ctx)
 	case Settings:
@@ -589,3 +600,10 @@ func (tg *Bot) loadChatType(user *users.User) {
 		log.Debug().Msgf("Failed to load chat type for user=%s - continuing without type", user.Id)
 	}
 }
+
+// Re-schedules the digest jobs in the background, e.g. after a chat's digest settings change
+func (tg *Bot) digestSettingsChanged() {
+	if tg.RescheduleDigests != nil {
+		go tg.RescheduleDigests()
+	}
+}
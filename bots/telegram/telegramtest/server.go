@@ -0,0 +1,202 @@
+// Package telegramtest provides a fake Telegram Bot API server, so that the bot
+// can be run and tested without a token or network access.
+package telegramtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Username of the fake bot
+const BotUsername = "launchbot_test"
+
+// A single recorded Bot API call
+type Call struct {
+	Method    string            // Bot API method, e.g. sendMessage
+	Params    map[string]string // Request parameters
+	MessageId int               // Id of the message sent, for sendMessage and sendDocument calls
+	Files     map[string]string // Contents of uploaded files by field, e.g. for sendDocument calls
+}
+
+// Returns the chat the call targeted
+func (call *Call) ChatId() int64 {
+	id, _ := strconv.ParseInt(call.Params["chat_id"], 10, 64)
+	return id
+}
+
+// Server is a fake Bot API server that records every call it receives, and
+// responds with plausible results
+type Server struct {
+	*httptest.Server
+	calls     []Call
+	messageId int // Id of the last message sent
+	Mutex     sync.Mutex
+}
+
+// Starts a new fake Bot API server. Close it when done.
+func NewServer() *Server {
+	server := &Server{}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+	return server
+}
+
+// Handles a single Bot API request, of the form /bot<token>/<method>
+func (server *Server) handle(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	params := map[string]string{}
+	files := map[string]string{}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		// Files are uploaded as multipart forms, with the other parameters as fields
+		_ = r.ParseMultipartForm(1 << 20)
+
+		for key, values := range r.MultipartForm.Value {
+			params[key] = values[0]
+		}
+
+		for key, headers := range r.MultipartForm.File {
+			if file, err := headers[0].Open(); err == nil {
+				content, _ := io.ReadAll(file)
+				files[key] = string(content)
+				file.Close()
+			}
+		}
+	} else {
+		// Telebot sends parameters in a JSON body, mostly as strings
+		body := map[string]interface{}{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		for key, value := range body {
+			if str, ok := value.(string); ok {
+				params[key] = str
+			} else {
+				params[key] = fmt.Sprint(value)
+			}
+		}
+	}
+
+	server.Mutex.Lock()
+	result := server.result(method, params)
+	call := Call{Method: method, Params: params, Files: files}
+
+	if method == "sendMessage" || method == "sendDocument" {
+		call.MessageId = server.messageId
+	}
+
+	server.calls = append(server.calls, call)
+	server.Mutex.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+}
+
+// Builds the result of a call. Mutex must be held by the caller.
+func (server *Server) result(method string, params map[string]string) interface{} {
+	chatId, _ := strconv.ParseInt(params["chat_id"], 10, 64)
+	chat := map[string]interface{}{"id": chatId, "type": chatType(chatId)}
+
+	switch method {
+	case "getMe":
+		return map[string]interface{}{
+			"id": 1, "is_bot": true, "first_name": "LaunchBot", "username": BotUsername,
+		}
+
+	case "getMyCommands":
+		return []interface{}{}
+
+	case "getChat":
+		return chat
+
+	case "getChatMember":
+		return map[string]interface{}{
+			"status": "administrator", "can_post_messages": true, "can_delete_messages": true,
+			"user": map[string]interface{}{"id": 1, "is_bot": true, "first_name": "LaunchBot"},
+		}
+
+	case "sendMessage":
+		server.messageId++
+		return map[string]interface{}{
+			"message_id": server.messageId, "date": time.Now().Unix(), "chat": chat, "text": params["text"],
+		}
+
+	case "sendDocument":
+		server.messageId++
+		return map[string]interface{}{
+			"message_id": server.messageId, "date": time.Now().Unix(), "chat": chat, "caption": params["caption"],
+			"document": map[string]interface{}{
+				"file_id": fmt.Sprintf("document%d", server.messageId), "file_name": params["file_name"],
+			},
+		}
+
+	case "editMessageText", "editMessageReplyMarkup":
+		messageId, _ := strconv.Atoi(params["message_id"])
+		return map[string]interface{}{
+			"message_id": messageId, "date": time.Now().Unix(), "chat": chat, "text": params["text"],
+		}
+
+	default:
+		// e.g. deleteMessage, deleteMessages, answerCallbackQuery, setMyCommands
+		return true
+	}
+}
+
+// Chat type by id, following Telegram's conventions: negative ids are groups
+func chatType(chatId int64) string {
+	if chatId < 0 {
+		return "supergroup"
+	}
+
+	return "private"
+}
+
+// Returns all recorded calls of a method, or all calls if method is empty
+func (server *Server) Calls(method string) []Call {
+	server.Mutex.Lock()
+	defer server.Mutex.Unlock()
+
+	calls := []Call{}
+
+	for _, call := range server.calls {
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// Clears the recorded calls
+func (server *Server) Reset() {
+	server.Mutex.Lock()
+	defer server.Mutex.Unlock()
+
+	server.calls = []Call{}
+}
+
+// Waits until a call matching the method and the filter has been recorded, returning it.
+// Returns an error if no matching call is recorded before the timeout.
+func (server *Server) WaitFor(method string, timeout time.Duration, filter func(Call) bool) (Call, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		for _, call := range server.Calls(method) {
+			if filter == nil || filter(call) {
+				return call, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return Call{}, fmt.Errorf("no matching %s call within %s", method, timeout)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
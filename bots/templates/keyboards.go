@@ -10,6 +10,7 @@ import (
 	"launchbot/db"
 	"launchbot/users"
 	"launchbot/utils"
+	"sort"
 	"strings"
 
 	"github.com/rs/zerolog/log"
@@ -28,6 +29,11 @@ type SettingsKeyboard struct {
 	Subscription SubscriptionKeyboard
 	Keywords     KeywordsKeyboard
 	Topic        TopicKeyboard
+	Webcast      WebcastKeyboard
+	Mission      MissionFilterKeyboard
+	QuietHours   QuietHoursKeyboard
+	Digest       DigestKeyboard
+	Calendar     CalendarKeyboard
 }
 
 // Extend Settings{} with time-zone settings
@@ -46,11 +52,52 @@ type KeywordsKeyboard struct {
 type TopicKeyboard struct {
 }
 
+// Extend Settings{} with orbit and mission type filter settings
+type MissionFilterKeyboard struct {
+}
+
+// Extend Settings{} with webcast language settings
+type WebcastKeyboard struct {
+}
+
+// Extend Settings{} with quiet hours settings
+type QuietHoursKeyboard struct {
+}
+
+// Extend Settings{} with launch digest settings
+type DigestKeyboard struct {
+}
+
+// Extend Settings{} with calendar feed settings
+type CalendarKeyboard struct {
+}
+
+// Webcast languages chats can choose from, as language codes used by the API
+var WebcastLanguages = []struct {
+	Code string
+	Name string
+}{
+	{"en", "🇬🇧 English"}, {"es", "🇪🇸 Spanish"}, {"fr", "🇫🇷 French"},
+	{"de", "🇩🇪 German"}, {"it", "🇮🇹 Italian"}, {"pt", "🇧🇷 Portuguese"},
+	{"ru", "🇷🇺 Russian"}, {"ja", "🇯🇵 Japanese"}, {"zh", "🇨🇳 Chinese"},
+}
+
+// Webcast sources chats can choose from, with the source's callback data
+var WebcastSources = []struct {
+	Source users.WebcastSource
+	Data   string
+	Name   string
+}{
+	{users.WebcastAnySource, "any", "Any source"},
+	{users.WebcastOfficial, string(users.WebcastOfficial), "Official"},
+	{users.WebcastThirdParty, string(users.WebcastThirdParty), "Third-party"},
+}
+
 // Command templates
 type CommandKeyboard struct {
 }
 
-func (settings *SettingsKeyboard) Main(isGroup bool) (tb.SendOptions, [][]tb.InlineButton) {
+func (settings *SettingsKeyboard) Main(isGroup bool, calendarFeeds bool) (tb.SendOptions, [][]tb.InlineButton) {
 	subscribeBtn := tb.InlineButton{
 		Unique: "settings",
 		Text:   "🚀 Subscribe to launches",
@@ -63,6 +110,12 @@ func (settings *SettingsKeyboard) Main(isGroup bool) (tb.SendOptions, [][]tb.Inl
 		Data:   "keywords/main",
 	}
 
+	missionBtn := tb.InlineButton{
+		Unique: "settings",
+		Text:   "🛰️ Orbit & mission filters",
+		Data:   "mission/main",
+	}
+
 	timesBtn := tb.InlineButton{
 		Unique: "settings",
 		Text:   "⏰ Adjust notifications",
@@ -75,8 +128,25 @@ func (settings *SettingsKeyboard) Main(isGroup bool) (tb.SendOptions, [][]tb.Inl
 		Data:   "tz/main",
 	}
 
+	webcastBtn := tb.InlineButton{
+		Unique: "settings",
+		Text:   "📺 Webcast preferences",
+		Data:   "webcast/main",
+	}
+
 	// Construct the keyboard and send-options
-	kb := [][]tb.InlineButton{{subscribeBtn}, {keywordBtn}, {timesBtn}, {tzBtn}}
+	kb := [][]tb.InlineButton{{subscribeBtn}, {keywordBtn}, {missionBtn}, {timesBtn}, {tzBtn}, {webcastBtn}}
+
+	// Calendar feeds are only available if the HTTP server is enabled
+	if calendarFeeds {
+		calendarBtn := tb.InlineButton{
+			Unique: "settings",
+			Text:   "🗓️ Calendar feed",
+			Data:   "calendar/main",
+		}
+
+		kb = append(kb, []tb.InlineButton{calendarBtn})
+	}
 
 	// If chat is a group, show the group-specific settings
 	if isGroup {
@@ -143,28 +213,56 @@ func (settings *SettingsKeyboard) Group(chat *users.User) (tb.SendOptions, [][]t
 }
 
 func (settings *SettingsKeyboard) Notifications(chat *users.User) (tb.SendOptions, [][]tb.InlineButton) {
-	time24hBtn := tb.InlineButton{
-		Unique: "notificationToggle",
-		Text:   fmt.Sprintf("%s 24-hour", utils.BoolStateIndicator[chat.Enabled24h]),
-		Data:   fmt.Sprintf("time/24h/%s", utils.ToggleBoolStateAsString[chat.Enabled24h]),
+	// Presets, and any custom lead times the chat has enabled
+	leadTimes := chat.EnabledLeadTimes()
+
+	for _, preset := range users.PresetLeadTimes {
+		if !chat.HasLeadTime(preset) {
+			leadTimes = append(leadTimes, preset)
+		}
 	}
 
-	time12hBtn := tb.InlineButton{
-		Unique: "notificationToggle",
-		Text:   fmt.Sprintf("%s 12-hour", utils.BoolStateIndicator[chat.Enabled12h]),
-		Data:   fmt.Sprintf("time/12h/%s", utils.ToggleBoolStateAsString[chat.Enabled12h]),
+	sort.Sort(sort.Reverse(sort.IntSlice(leadTimes)))
+
+	// Lead time buttons, three per row
+	kb := [][]tb.InlineButton{}
+	row := []tb.InlineButton{}
+
+	for _, leadTime := range leadTimes {
+		enabled := chat.HasLeadTime(leadTime)
+
+		row = append(row, tb.InlineButton{
+			Unique: "notificationToggle",
+			Text:   fmt.Sprintf("%s %s", utils.BoolStateIndicator[enabled], users.LeadTimeLabel(leadTime)),
+			Data:   fmt.Sprintf("time/%s/%s", users.LeadTimeType(leadTime), utils.ToggleBoolStateAsString[enabled]),
+		})
+
+		if len(row) == 3 {
+			kb = append(kb, row)
+			row = []tb.InlineButton{}
+		}
 	}
 
-	time1hBtn := tb.InlineButton{
-		Unique: "notificationToggle",
-		Text:   fmt.Sprintf("%s 1-hour", utils.BoolStateIndicator[chat.Enabled1h]),
-		Data:   fmt.Sprintf("time/1h/%s", utils.ToggleBoolStateAsString[chat.Enabled1h]),
+	if len(row) != 0 {
+		kb = append(kb, row)
 	}
 
-	time5minBtn := tb.InlineButton{
-		Unique: "notificationToggle",
-		Text:   fmt.Sprintf("%s 5-minute", utils.BoolStateIndicator[chat.Enabled5min]),
-		Data:   fmt.Sprintf("time/5min/%s", utils.ToggleBoolStateAsString[chat.Enabled5min]),
+	customBtn := tb.InlineButton{
+		Unique: "settings",
+		Text:   "➕ Add a custom time",
+		Data:   "sub/leadtime",
+	}
+
+	quietBtn := tb.InlineButton{
+		Unique: "settings",
+		Text:   "🌙 Quiet hours",
+		Data:   "quiet/main",
+	}
+
+	digestBtn := tb.InlineButton{
+		Unique: "settings",
+		Text:   "📰 Launch digest",
+		Data:   "digest/main",
 	}
 
 	postponeBtn := tb.InlineButton{
@@ -173,6 +271,24 @@ func (settings *SettingsKeyboard) Notifications(chat *users.User) (tb.SendOption
 		Data:   fmt.Sprintf("time/postpone/%s", utils.ToggleBoolStateAsString[chat.EnabledPostpone]),
 	}
 
+	outcomeBtn := tb.InlineButton{
+		Unique: "notificationToggle",
+		Text:   fmt.Sprintf("%s Launch outcomes", utils.BoolStateIndicator[chat.EnabledOutcome]),
+		Data:   fmt.Sprintf("time/outcome/%s", utils.ToggleBoolStateAsString[chat.EnabledOutcome]),
+	}
+
+	webcastBtn := tb.InlineButton{
+		Unique: "notificationToggle",
+		Text:   fmt.Sprintf("%s Webcast is live", utils.BoolStateIndicator[chat.EnabledWebcast]),
+		Data:   fmt.Sprintf("time/webcast/%s", utils.ToggleBoolStateAsString[chat.EnabledWebcast]),
+	}
+
+	holdBtn := tb.InlineButton{
+		Unique: "notificationToggle",
+		Text:   fmt.Sprintf("%s Holds & scrubs", utils.BoolStateIndicator[chat.EnabledHold]),
+		Data:   fmt.Sprintf("time/hold/%s", utils.ToggleBoolStateAsString[chat.EnabledHold]),
+	}
+
 	retBtn := tb.InlineButton{
 		Unique: "settings",
 		Text:   "⬅️ Return",
@@ -180,7 +296,7 @@ func (settings *SettingsKeyboard) Notifications(chat *users.User) (tb.SendOption
 	}
 
 	// Keyboard
-	kb := [][]tb.InlineButton{{time24hBtn, time12hBtn}, {time1hBtn, time5minBtn}, {postponeBtn}, {retBtn}}
+	kb = append(kb, []tb.InlineButton{customBtn}, []tb.InlineButton{quietBtn, digestBtn}, []tb.InlineButton{postponeBtn, outcomeBtn}, []tb.InlineButton{holdBtn, webcastBtn}, []tb.InlineButton{retBtn})
 
 	sendOptions := tb.SendOptions{
 		ParseMode:             "MarkdownV2",
@@ -280,25 +396,44 @@ func (subscription *SubscriptionKeyboard) Main(chat *users.User) (tb.SendOptions
 		Data:   fmt.Sprintf("all/%s", utils.ToggleBoolStateAsString[allEnabled]),
 	}
 
+	crewedOnlyBtn := tb.InlineButton{
+		Unique: "notificationToggle",
+		Text:   fmt.Sprintf("%s Crewed flights only", utils.BoolStateIndicator[chat.CrewedOnly]),
+		Data:   fmt.Sprintf("crewed/%s", utils.ToggleBoolStateAsString[chat.CrewedOnly]),
+	}
+
 	// A dynamically generated keyboard array
-	kb := [][]tb.InlineButton{{toggleAllBtn}}
+	kb := [][]tb.InlineButton{{toggleAllBtn}, {crewedOnlyBtn}}
 	row := []tb.InlineButton{}
 
-	// Generate the keyboard dynamically from available country-codes
-	for i, countryCode := range db.CountryCodes {
+	// Generate the keyboard dynamically from the country-codes of the registered providers
+	countryCodes := db.Providers.CountryCodes()
+
+	for i, countryCode := range countryCodes {
 		row = append(row,
 			tb.InlineButton{
 				Unique: "countryCodeView",
-				Text:   db.CountryCodeToName[countryCode],
+				Text:   db.CountryName(countryCode),
 				Data:   fmt.Sprintf("cc/%s", countryCode),
 			})
 
-		if len(row) == 2 || i == len(db.CountryCodes)-1 {
+		if len(row) == 2 || i == len(countryCodes)-1 {
 			kb = append(kb, row)
 			row = []tb.InlineButton{}
 		}
 	}
 
+	// Launch sites and vehicles are followed independently of providers
+	kb = append(kb, []tb.InlineButton{{
+		Unique: "settings",
+		Text:   "📍 Follow launch sites",
+		Data:   "sub/bysite",
+	}, {
+		Unique: "settings",
+		Text:   "🚀 Follow rockets",
+		Data:   "sub/byvehicle",
+	}})
+
 	// Add the return key
 	kb = append(kb, []tb.InlineButton{{
 		Unique: "settings",
@@ -325,8 +460,11 @@ func (subscription *SubscriptionKeyboard) ByCountryCode(chat *users.User, cc str
 	row := []tb.InlineButton{}
 
 	// Country-code we want to view is at index 1: build the keyboard, and get status for all
-	for i, id := range db.IdByCountryCode[cc] {
+	ids := db.Providers.IdsByCountryCode(cc)
+
+	for i, id := range ids {
 		enabled := chat.GetNotificationStatusById(id)
+		provider, _ := db.Providers.Get(id)
 
 		// If not enabled, set allEnabled to false
 		if !enabled {
@@ -336,11 +474,11 @@ func (subscription *SubscriptionKeyboard) ByCountryCode(chat *users.User, cc str
 		row = append(row,
 			tb.InlineButton{
 				Unique: "notificationToggle",
-				Text:   fmt.Sprintf("%s %s", utils.BoolStateIndicator[enabled], db.LSPShorthands[id].Name),
+				Text:   fmt.Sprintf("%s %s", utils.BoolStateIndicator[enabled], provider.ShortName),
 				Data:   fmt.Sprintf("id/%d/%s", id, map[bool]string{true: "0", false: "1"}[enabled]),
 			})
 
-		if len(row) == 2 || i == len(db.IdByCountryCode[cc])-1 {
+		if len(row) == 2 || i == len(ids)-1 {
 			kb = append(kb, row)
 			row = []tb.InlineButton{}
 		}
@@ -355,6 +493,11 @@ func (subscription *SubscriptionKeyboard) ByCountryCode(chat *users.User, cc str
 
 	ccFlag := utils.CountryCodeFlag(cc)
 
+	if cc == "" {
+		// Providers without a country
+		ccFlag = "🌐"
+	}
+
 	// Insert the toggle-all key at the beginning
 	toggleAllBtn := tb.InlineButton{
 		Unique: "notificationToggle",
@@ -375,6 +518,109 @@ func (subscription *SubscriptionKeyboard) ByCountryCode(chat *users.User, cc str
 	return sendOptions, kb
 }
 
+func (subscription *SubscriptionKeyboard) BySite(chat *users.User, sites []*db.LaunchSite) (tb.SendOptions, [][]tb.InlineButton) {
+	// A dynamically generated keyboard array
+	kb := [][]tb.InlineButton{}
+	row := []tb.InlineButton{}
+
+	for i, site := range sites {
+		enabled := chat.IsSubscribedToLocation(site.Id)
+
+		row = append(row,
+			tb.InlineButton{
+				Unique: "notificationToggle",
+				Text:   fmt.Sprintf("%s %s %s", utils.BoolStateIndicator[enabled], site.ShortName(), utils.CountryCodeFlag(site.CountryCode)),
+				Data:   fmt.Sprintf("site/%d/%s", site.Id, utils.ToggleBoolStateAsString[enabled]),
+			})
+
+		if len(row) == 2 || i == len(sites)-1 {
+			kb = append(kb, row)
+			row = []tb.InlineButton{}
+		}
+	}
+
+	// Add the return key
+	kb = append(kb, []tb.InlineButton{{
+		Unique: "settings",
+		Text:   "⬅️ Return",
+		Data:   "sub/bycountry",
+	}})
+
+	sendOptions := tb.SendOptions{
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+		ReplyMarkup:           &tb.ReplyMarkup{InlineKeyboard: kb},
+		Protected:             true,
+	}
+
+	return sendOptions, kb
+}
+
+func (subscription *SubscriptionKeyboard) ByVehicle(chat *users.User, families []string, vehicles []db.RocketConfiguration) (tb.SendOptions, [][]tb.InlineButton) {
+	// A dynamically generated keyboard array
+	kb := [][]tb.InlineButton{}
+	row := []tb.InlineButton{}
+
+	// Families first, as they include all of their vehicles
+	for i, family := range families {
+		enabled := chat.IsSubscribedToFamily(family)
+
+		row = append(row,
+			tb.InlineButton{
+				Unique: "notificationToggle",
+				Text:   fmt.Sprintf("%s All %s rockets", utils.BoolStateIndicator[enabled], family),
+				Data:   fmt.Sprintf("family/%s/%s", family, utils.ToggleBoolStateAsString[enabled]),
+			})
+
+		if len(row) == 2 || i == len(families)-1 {
+			kb = append(kb, row)
+			row = []tb.InlineButton{}
+		}
+	}
+
+	for i, vehicle := range vehicles {
+		// Only the vehicle itself, as families have their own buttons
+		enabled := chat.IsSubscribedToVehicle(vehicle.Id, "")
+
+		row = append(row,
+			tb.InlineButton{
+				Unique: "notificationToggle",
+				Text:   fmt.Sprintf("%s %s", utils.BoolStateIndicator[enabled], vehicle.Name),
+				Data:   fmt.Sprintf("vehicle/%d/%s", vehicle.Id, utils.ToggleBoolStateAsString[enabled]),
+			})
+
+		if len(row) == 2 || i == len(vehicles)-1 {
+			kb = append(kb, row)
+			row = []tb.InlineButton{}
+		}
+	}
+
+	// Vehicles without upcoming launches are not listed, so allow clearing them all
+	if chat.SubscribedVehicles != "" || chat.SubscribedFamilies != "" {
+		kb = append(kb, []tb.InlineButton{{
+			Unique: "notificationToggle",
+			Text:   "🔕 Unfollow all rockets",
+			Data:   "vehicle/clear",
+		}})
+	}
+
+	// Add the return key
+	kb = append(kb, []tb.InlineButton{{
+		Unique: "settings",
+		Text:   "⬅️ Return",
+		Data:   "sub/bycountry",
+	}})
+
+	sendOptions := tb.SendOptions{
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+		ReplyMarkup:           &tb.ReplyMarkup{InlineKeyboard: kb},
+		Protected:             true,
+	}
+
+	return sendOptions, kb
+}
+
 func (command *CommandKeyboard) Statistics() (tb.SendOptions, [][]tb.InlineButton) {
 	// Construct the keyboard and send-options
 	kb := [][]tb.InlineButton{{
@@ -575,6 +821,12 @@ func (keywords *KeywordsKeyboard) Main(chat *users.User) (tb.SendOptions, [][]tb
 		Data:   "blocked/view",
 	}
 
+	rulesBtn := tb.InlineButton{
+		Unique: "keywords",
+		Text:   "📐 Filter Rules",
+		Data:   "rules/view",
+	}
+
 	helpBtn := tb.InlineButton{
 		Unique: "keywords",
 		Text:   "❔ How It Works",
@@ -587,7 +839,59 @@ func (keywords *KeywordsKeyboard) Main(chat *users.User) (tb.SendOptions, [][]tb
 		Data:   "main",
 	}
 
-	kb := [][]tb.InlineButton{{allowedBtn}, {blockedBtn}, {helpBtn}, {retBtn}}
+	kb := [][]tb.InlineButton{{allowedBtn}, {blockedBtn}, {rulesBtn}, {helpBtn}, {retBtn}}
+
+	sendOptions := tb.SendOptions{
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+		ReplyMarkup:           &tb.ReplyMarkup{InlineKeyboard: kb},
+		Protected:             true,
+	}
+
+	return sendOptions, kb
+}
+
+func (keywords *KeywordsKeyboard) Rules(chat *users.User) (tb.SendOptions, [][]tb.InlineButton) {
+	addBlockBtn := tb.InlineButton{
+		Unique: "keywords",
+		Text:   "➕ Block Rule",
+		Data:   "rules/add/block",
+	}
+
+	addAllowBtn := tb.InlineButton{
+		Unique: "keywords",
+		Text:   "➕ Allow Rule",
+		Data:   "rules/add/allow",
+	}
+
+	helpBtn := tb.InlineButton{
+		Unique: "keywords",
+		Text:   "❔ Rule Syntax",
+		Data:   "rules/help",
+	}
+
+	retBtn := tb.InlineButton{
+		Unique: "keywords",
+		Text:   "⬅️ Back",
+		Data:   "main",
+	}
+
+	kb := [][]tb.InlineButton{{addBlockBtn, addAllowBtn}}
+
+	// Add remove buttons for each rule, by its index
+	for _, action := range []string{"block", "allow"} {
+		icon := map[string]string{"block": "🚫", "allow": "✅"}[action]
+
+		for i, source := range chat.RuleSources(action) {
+			kb = append(kb, []tb.InlineButton{{
+				Unique: "keywords",
+				Text:   fmt.Sprintf("❌ %s %s", icon, source),
+				Data:   fmt.Sprintf("rules/remove/%s/%d", action, i),
+			}})
+		}
+	}
+
+	kb = append(kb, []tb.InlineButton{helpBtn}, []tb.InlineButton{retBtn})
 
 	sendOptions := tb.SendOptions{
 		ParseMode:             "MarkdownV2",
@@ -724,3 +1028,277 @@ func (topic *TopicKeyboard) Main(chat *users.User) (tb.SendOptions, [][]tb.Inlin
 
 	return sendOptions, kb
 }
+
+func (quiet *QuietHoursKeyboard) Main(chat *users.User) (tb.SendOptions, [][]tb.InlineButton) {
+	setBtn := tb.InlineButton{
+		Unique: "settings",
+		Text:   "📝 Set quiet hours",
+		Data:   "quiet/setprompt",
+	}
+
+	kb := [][]tb.InlineButton{{setBtn}}
+
+	if chat.QuietHours != "" {
+		kb[0] = append(kb[0], tb.InlineButton{
+			Unique: "settings",
+			Text:   "❌ Turn off",
+			Data:   "quiet/off",
+		})
+	}
+
+	// One button per policy, with the chat's current policy marked
+	policyLabels := map[users.QuietPolicy]string{
+		users.QuietHold: "Held", users.QuietSummary: "Summarized", users.QuietDrop: "Dropped",
+	}
+
+	row := []tb.InlineButton{}
+
+	for _, policy := range users.QuietPolicies {
+		row = append(row, tb.InlineButton{
+			Unique: "settings",
+			Text:   fmt.Sprintf("%s %s", utils.BoolStateIndicator[chat.QuietHoursPolicy() == policy], policyLabels[policy]),
+			Data:   fmt.Sprintf("quiet/policy/%s", policy),
+		})
+	}
+
+	retBtn := tb.InlineButton{
+		Unique: "settings",
+		Text:   "⬅️ Back to notification times",
+		Data:   "sub/times",
+	}
+
+	kb = append(kb, row, []tb.InlineButton{retBtn})
+
+	sendOptions := tb.SendOptions{
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+		ReplyMarkup:           &tb.ReplyMarkup{InlineKeyboard: kb},
+		Protected:             true,
+	}
+
+	return sendOptions, kb
+}
+
+func (digest *DigestKeyboard) Main(chat *users.User) (tb.SendOptions, [][]tb.InlineButton) {
+	// Frequency buttons, with the chat's current frequency marked
+	frequencies := []struct {
+		frequency users.DigestFrequency
+		label     string
+		data      string
+	}{
+		{users.DigestOff, "Off", "off"}, {users.DigestDaily, "Daily", "daily"}, {users.DigestWeekly, "Weekly", "weekly"},
+	}
+
+	row := []tb.InlineButton{}
+
+	for _, option := range frequencies {
+		row = append(row, tb.InlineButton{
+			Unique: "settings",
+			Text:   fmt.Sprintf("%s %s", utils.BoolStateIndicator[chat.DigestFrequency == option.frequency], option.label),
+			Data:   fmt.Sprintf("digest/freq/%s", option.data),
+		})
+	}
+
+	kb := [][]tb.InlineButton{row}
+
+	if chat.DigestFrequency != users.DigestOff {
+		// Step the delivery hour back and forth
+		kb = append(kb, []tb.InlineButton{
+			{Unique: "settings", Text: "◀️", Data: fmt.Sprintf("digest/hour/%d", chat.DigestHour-1)},
+			{Unique: "settings", Text: fmt.Sprintf("🕗 %02d:00", chat.DigestHour), Data: "digest/main"},
+			{Unique: "settings", Text: "▶️", Data: fmt.Sprintf("digest/hour/%d", chat.DigestHour+1)},
+		})
+	}
+
+	if chat.DigestFrequency == users.DigestWeekly {
+		// Step the delivery weekday back and forth
+		kb = append(kb, []tb.InlineButton{
+			{Unique: "settings", Text: "◀️", Data: fmt.Sprintf("digest/day/%d", int(chat.DigestWeekday)-1)},
+			{Unique: "settings", Text: fmt.Sprintf("📅 %s", chat.DigestWeekday), Data: "digest/main"},
+			{Unique: "settings", Text: "▶️", Data: fmt.Sprintf("digest/day/%d", int(chat.DigestWeekday)+1)},
+		})
+	}
+
+	retBtn := tb.InlineButton{
+		Unique: "settings",
+		Text:   "⬅️ Back to notification times",
+		Data:   "sub/times",
+	}
+
+	kb = append(kb, []tb.InlineButton{retBtn})
+
+	sendOptions := tb.SendOptions{
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+		ReplyMarkup:           &tb.ReplyMarkup{InlineKeyboard: kb},
+		Protected:             true,
+	}
+
+	return sendOptions, kb
+}
+
+func (calendar *CalendarKeyboard) Main(chat *users.User) (tb.SendOptions, [][]tb.InlineButton) {
+	kb := [][]tb.InlineButton{}
+
+	if chat.CalendarToken == "" {
+		kb = append(kb, []tb.InlineButton{{Unique: "settings", Text: "🔗 Create a feed link", Data: "calendar/generate"}})
+	} else {
+		kb = append(kb,
+			[]tb.InlineButton{{Unique: "settings", Text: "🔄 Replace the link", Data: "calendar/generate"}},
+			[]tb.InlineButton{{Unique: "settings", Text: "🗑️ Revoke the link", Data: "calendar/revoke"}},
+		)
+	}
+
+	retBtn := tb.InlineButton{
+		Unique: "settings",
+		Text:   "⬅️ Back to settings",
+		Data:   "main",
+	}
+
+	kb = append(kb, []tb.InlineButton{retBtn})
+
+	sendOptions := tb.SendOptions{
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+		ReplyMarkup:           &tb.ReplyMarkup{InlineKeyboard: kb},
+		Protected:             true,
+	}
+
+	return sendOptions, kb
+}
+
+func (webcast *WebcastKeyboard) Main(chat *users.User) (tb.SendOptions, [][]tb.InlineButton) {
+	kb := [][]tb.InlineButton{}
+	row := []tb.InlineButton{}
+
+	// The preferred source, one of which is always selected
+	for _, source := range WebcastSources {
+		row = append(row, tb.InlineButton{
+			Unique: "settings",
+			Text:   fmt.Sprintf("%s %s", utils.BoolStateIndicator[chat.WebcastSource == source.Source], source.Name),
+			Data:   fmt.Sprintf("webcast/source/%s", source.Data),
+		})
+	}
+
+	kb = append(kb, row)
+	row = []tb.InlineButton{}
+
+	// Two languages per row, with the chat's current state
+	for _, language := range WebcastLanguages {
+		enabled := chat.HasWebcastLanguage(language.Code)
+
+		row = append(row, tb.InlineButton{
+			Unique: "settings",
+			Text:   fmt.Sprintf("%s %s", utils.BoolStateIndicator[enabled], language.Name),
+			Data:   fmt.Sprintf("webcast/toggle/%s/%s", language.Code, utils.ToggleBoolStateAsString[enabled]),
+		})
+
+		if len(row) == 2 {
+			kb = append(kb, row)
+			row = []tb.InlineButton{}
+		}
+	}
+
+	if len(row) != 0 {
+		kb = append(kb, row)
+	}
+
+	retBtn := tb.InlineButton{
+		Unique: "settings",
+		Text:   "⬅️ Back to settings",
+		Data:   "main",
+	}
+
+	kb = append(kb, []tb.InlineButton{retBtn})
+
+	sendOptions := tb.SendOptions{
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+		ReplyMarkup:           &tb.ReplyMarkup{InlineKeyboard: kb},
+		Protected:             true,
+	}
+
+	return sendOptions, kb
+}
+
+// Indicators for the states of orbit and mission type filters
+var missionFilterIndicator = map[users.FilterState]string{
+	users.FilterNone: "⚪", users.FilterInclude: "✅", users.FilterExclude: "🚫",
+}
+
+func (mission *MissionFilterKeyboard) Main(chat *users.User) (tb.SendOptions, [][]tb.InlineButton) {
+	orbitBtn := tb.InlineButton{
+		Unique: "settings",
+		Text:   "🌍 Orbits",
+		Data:   "mission/orbit",
+	}
+
+	typeBtn := tb.InlineButton{
+		Unique: "settings",
+		Text:   "🎯 Mission types",
+		Data:   "mission/type",
+	}
+
+	retBtn := tb.InlineButton{
+		Unique: "settings",
+		Text:   "⬅️ Back to settings",
+		Data:   "main",
+	}
+
+	kb := [][]tb.InlineButton{{orbitBtn, typeBtn}}
+
+	if chat.HasMissionFilters() {
+		kb = append(kb, []tb.InlineButton{{
+			Unique: "notificationToggle",
+			Text:   "🗑️ Clear all filters",
+			Data:   "mf/clear",
+		}})
+	}
+
+	kb = append(kb, []tb.InlineButton{retBtn})
+
+	sendOptions := tb.SendOptions{
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+		ReplyMarkup:           &tb.ReplyMarkup{InlineKeyboard: kb},
+		Protected:             true,
+	}
+
+	return sendOptions, kb
+}
+
+// Keyboard for the orbit ("orbit") or mission type ("type") filters. Tapping a value cycles its state.
+func (mission *MissionFilterKeyboard) Values(chat *users.User, kind string, values []string) (tb.SendOptions, [][]tb.InlineButton) {
+	kb := [][]tb.InlineButton{}
+	row := []tb.InlineButton{}
+
+	for i, value := range values {
+		state := chat.MissionFilterState(kind, value)
+
+		row = append(row, tb.InlineButton{
+			Unique: "notificationToggle",
+			Text:   fmt.Sprintf("%s %s", missionFilterIndicator[state], value),
+			Data:   fmt.Sprintf("mf/%s/%s", kind, value),
+		})
+
+		if len(row) == 2 || i == len(values)-1 {
+			kb = append(kb, row)
+			row = []tb.InlineButton{}
+		}
+	}
+
+	kb = append(kb, []tb.InlineButton{{
+		Unique: "settings",
+		Text:   "⬅️ Return",
+		Data:   "mission/main",
+	}})
+
+	sendOptions := tb.SendOptions{
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+		ReplyMarkup:           &tb.ReplyMarkup{InlineKeyboard: kb},
+		Protected:             true,
+	}
+
+	return sendOptions, kb
+}
@@ -2,6 +2,7 @@ package templates
 
 import (
 	"fmt"
+	"launchbot/db"
 	"launchbot/users"
 	"launchbot/utils"
 	"strings"
@@ -20,12 +21,22 @@ type SettingsMessage struct {
 	Subscription SubscriptionMessage
 	Keywords     KeywordsMessage
 	Topic        TopicMessage
+	Webcast      WebcastMessage
+	Mission      MissionFilterMessage
+	QuietHours   QuietHoursMessage
+	Digest       DigestMessage
+	Calendar     CalendarMessage
 }
 
 type TimeZoneMessage struct{}
 type SubscriptionMessage struct{}
 type KeywordsMessage struct{}
 type TopicMessage struct{}
+type WebcastMessage struct{}
+type MissionFilterMessage struct{}
+type QuietHoursMessage struct{}
+type DigestMessage struct{}
+type CalendarMessage struct{}
 type CommandMessage struct{}
 type ServiceMessage struct{}
 
@@ -35,12 +46,18 @@ func (messages *Messages) Migrated() string {
 }
 
 // Settings.Main
-func (settings *SettingsMessage) Main(isGroup bool) string {
+func (settings *SettingsMessage) Main(isGroup bool, calendarFeeds bool) string {
 	base := "*LaunchBot* | *User settings*\n" +
 		"🚀 *Launch subscription settings* allow you to choose what launches you receive notifications for, like SpaceX's or NASA's.\n\n" +
 		"🔍 *Keyword filters* let you allow and block launch notifications with arbitrary keywords.\n\n" +
-		"⏰ *Notification settings* allow you to choose when you receive notifications.\n\n" +
-		"🌍 *Time zone settings* let you set your time zone, so all dates and times are in your local time, instead of UTC+0."
+		"🛰️ *Orbit & mission filters* let you only receive, or never receive, launches to some orbits or with some mission types.\n\n" +
+		"⏰ *Notification settings* allow you to choose when you receive notifications, and to set quiet hours.\n\n" +
+		"🌍 *Time zone settings* let you set your time zone, so all dates and times are in your local time, instead of UTC+0.\n\n" +
+		"📺 *Webcast preferences* let you choose which language's webcast the notifications link to, and whether it is the official one."
+
+	if calendarFeeds {
+		base += "\n\n🗓️ *Calendar feed* gives you a personal link your calendar app can subscribe to, so your launches stay up to date in your calendar."
+	}
 
 	if isGroup {
 		return base + "\n\n👷 *Group settings* let admins change some group-specific settings, such as allowing all users to send commands."
@@ -59,9 +76,29 @@ func (settings *SettingsMessage) Group() string {
 // Settings.Notifications
 func (settings *SettingsMessage) Notifications() string {
 	return "⏰ *LaunchBot* | *Notification time settings*\n" +
-		"Notifications are delivered 24 hours, 12 hours, 60 minutes, and 5 minutes before a launch.\n\n" +
+		"Choose how long before a launch you want to be notified. Pick any of the presets, or add your own time, " +
+		"like 3 days, 2 hours or 30 minutes.\n\n" +
 		"By default, you will receive a notification 24 hours before, and 5 minutes before a launch. You can adjust this behavior here.\n\n" +
-		"You can also toggle postpone notifications, which are sent when a launch has its launch time moved (if a notification has already been sent)."
+		"You can also toggle postpone notifications, which are sent when a launch has its launch time moved (if a notification has already been sent).\n\n" +
+		fmt.Sprintf("Launch outcome notifications tell you whether a launch succeeded, and are sent after lift-off if your "+
+			"last notification is at most %d minutes before launch.\n\n", users.FinalLeadTime) +
+		"Hold and scrub notifications are sent when a launch you have been notified of is put on hold or scrubbed, together with the reason if one is known.\n\n" +
+		"Webcast notifications are sent once when a launch's live stream begins. These are disabled by default."
+}
+
+// Settings.LeadTimePrompt
+func (settings *SettingsMessage) LeadTimePrompt() string {
+	return "⏰ *Add a Notification Time*\n\n" +
+		"Send me the notification time you want to add, as how long before a launch you want to be notified.\n\n" +
+		"*Examples:*\n" +
+		"`3d`, `2h`, `1h 30min` or `15min`\n\n" +
+		fmt.Sprintf("💡 You can have up to %d notification times, at most %d days before a launch.\n\n", users.MaxLeadTimes, users.MaxLeadTime/1440) +
+		"Type /cancel if you change your mind."
+}
+
+// Settings.LeadTimeInvalid
+func (settings *SettingsMessage) LeadTimeInvalid(err error) string {
+	return fmt.Sprintf("⚠️ *That time didn't work:* %s\n\n", err.Error()) + settings.LeadTimePrompt()
 }
 
 // Settings.TimeZone.Main
@@ -111,7 +148,26 @@ func (subscription *SubscriptionMessage) ByCountryCode() string {
 	// TODO add user's time zone
 	return "🚀 *LaunchBot* | *Subscription settings*\n" +
 		"You can search for specific launch-providers with the country flags, or simply enable notifications for all launch providers.\n\n" +
-		"As an example, SpaceX can be found under the 🇺🇸-flag, and ISRO can be found under 🇮🇳-flag. You can also choose to enable all notifications."
+		"As an example, SpaceX can be found under the 🇺🇸-flag, and ISRO can be found under 🇮🇳-flag. You can also choose to enable all notifications.\n\n" +
+		"If you only follow human spaceflight, you can limit notifications to crewed flights.\n\n" +
+		"📍 You can also follow launch sites, like Cape Canaveral or Kourou, to be notified of all launches from them.\n\n" +
+		"🚀 Or follow specific rockets, like Falcon Heavy or Electron, without following everything their provider launches."
+}
+
+// Subscription.ByVehicle
+func (subscription *SubscriptionMessage) ByVehicle() string {
+	return "🚀 *LaunchBot* | *Rocket subscriptions*\n" +
+		"Follow a rocket to receive notifications for all of its launches, or a whole rocket family, like all Falcon rockets.\n\n" +
+		"Only rockets with upcoming launches are listed. Rockets are followed in addition to your launch provider " +
+		"subscriptions. Muted launches and blocked keywords still apply, and allowed keywords always include a launch."
+}
+
+// Subscription.BySite
+func (subscription *SubscriptionMessage) BySite() string {
+	return "📍 *LaunchBot* | *Launch site subscriptions*\n" +
+		"Follow a launch site to receive notifications for every launch from it, no matter who the launch provider is.\n\n" +
+		"Launch sites are followed in addition to your launch provider subscriptions. Muted launches and blocked keywords " +
+		"still apply, and allowed keywords always include a launch."
 }
 
 // Command.Start
@@ -146,10 +202,83 @@ func (command *CommandMessage) Feedback(received bool) string {
 		"*Thank you for using LaunchBot! <3*"
 }
 
+// Command.Calendar, the caption of a calendar export with launchCount launches
+func (command *CommandMessage) Calendar(launchCount int, botUsername string) string {
+	if launchCount == 0 {
+		return "🗓️ *LaunchBot* | *Launch calendar*\n" +
+			"None of the upcoming launches are ones you have subscribed to, so there is nothing to export yet. " +
+			fmt.Sprintf("You can subscribe to launches in /settings@%s.", botUsername)
+	}
+
+	return fmt.Sprintf("🗓️ *LaunchBot* | *Launch calendar*\n"+
+		"Here are the %d upcoming launches you have subscribed to, as a calendar file. Open the file to import the launches into your calendar app.\n\n"+
+		"_Launches keep their identity across exports: to update launches that have moved, simply run /calendar@%s again and import the new file._",
+		launchCount, botUsername)
+}
+
 func (service *ServiceMessage) InteractionNotAllowed() string {
 	return "🙃 Whoops, you must be an admin of this group to do that!"
 }
 
+// Service.QuietHoursHeld, prepended to a held notification's text (already escaped)
+func (service *ServiceMessage) QuietHoursHeld() string {
+	return "🌙 _Held back during your quiet hours_\n\n"
+}
+
+// Service.QuietHoursSummary
+func (service *ServiceMessage) QuietHoursSummary(chat *users.User, notifications []*db.DeferredNotification, nets map[string]int64) string {
+	if chat.Time.Location == nil {
+		chat.SetTimeZone()
+	}
+
+	text := fmt.Sprintf("🌙 *LaunchBot* | *Quiet hours summary*\n"+
+		"%d notification%s came up during your quiet hours:\n", len(notifications), map[bool]string{true: "", false: "s"}[len(notifications) == 1])
+
+	for _, notification := range notifications {
+		if notification.LaunchId == "" {
+			// Not about a single launch, e.g. a digest
+			text += fmt.Sprintf("\n• %s", quietNotificationLabel(notification.NotificationType))
+			continue
+		}
+
+		name := notification.LaunchName
+		if name == "" {
+			name = "Unknown launch"
+		}
+
+		text += fmt.Sprintf("\n• *%s*: %s", name, quietNotificationLabel(notification.NotificationType))
+
+		if net, ok := nets[notification.LaunchId]; ok {
+			text += fmt.Sprintf("\n   🕙 %s, %s", utils.DateInUserLocation(net, chat.Time.Location),
+				utils.TimeInUserLocation(net, chat.Time.Location, chat.Time.UtcOffset))
+		}
+	}
+
+	return text + "\n\nUse /next to see the latest on these launches."
+}
+
+// Returns a human-readable label for a notification type, e.g. "24-hour notification"
+func quietNotificationLabel(notificationType string) string {
+	if leadTime, ok := users.LeadTimeOfType(notificationType); ok {
+		return users.LeadTimeLabel(leadTime) + " notification"
+	}
+
+	switch notificationType {
+	case "postpone":
+		return "launch postponed"
+	case "outcome":
+		return "launch outcome"
+	case "webcast":
+		return "webcast went live"
+	case "hold":
+		return "launch on hold or scrubbed"
+	case "digest":
+		return "launch digest"
+	}
+
+	return notificationType + " notification"
+}
+
 // Keywords.Main
 func (keywords *KeywordsMessage) Main(chat *users.User) string {
 	return "🔍 *LaunchBot* | *Keyword Filtering*\n\n" +
@@ -288,6 +417,134 @@ func (keywords *KeywordsMessage) Cleared(keywordType string) string {
 	return fmt.Sprintf("✅ All %s keywords have been cleared.", keywordType)
 }
 
+// Keywords.Rules
+func (keywords *KeywordsMessage) Rules(chat *users.User) string {
+	base := "📐 *LaunchBot* | *Filter Rules*\n\n" +
+		"Rules combine conditions on a launch's provider, vehicle, mission, pad and orbit. " +
+		"Block rules hide matching launches, while allow rules notify you of them even if you don't follow the provider.\n\n"
+
+	blocked, allowed := chat.RuleSources("block"), chat.RuleSources("allow")
+
+	if len(blocked) == 0 && len(allowed) == 0 {
+		return base + "No filter rules yet! 🎯\n\n" +
+			"*Examples:*\n" +
+			"• `provider:SpaceX AND NOT mission:Starlink`\n" +
+			"• `vehicle:\"Falcon Heavy\" OR vehicle:/electron|neutron/`\n" +
+			"• `(pad:Kourou OR pad:Wenchang) orbit:GTO`"
+	}
+
+	text := base
+
+	for _, action := range []string{"block", "allow"} {
+		sources := chat.RuleSources(action)
+
+		if len(sources) == 0 {
+			continue
+		}
+
+		text += map[string]string{"block": "🚫 *Block rules*\n", "allow": "✅ *Allow rules*\n"}[action]
+
+		for i, source := range sources {
+			text += fmt.Sprintf("%d. `%s`\n", i+1, ruleCodeSpan(source))
+		}
+
+		text += "\n"
+	}
+
+	return text + "Tap a rule below to remove it:"
+}
+
+// Escapes a rule so it can be shown inside a code span
+func ruleCodeSpan(source string) string {
+	source = strings.ReplaceAll(source, "\\", "\\\\")
+	return strings.ReplaceAll(source, "`", "'")
+}
+
+// Keywords.RulesHelp
+func (keywords *KeywordsMessage) RulesHelp() string {
+	return "❔ *LaunchBot* | *How Filter Rules Work*\n\n" +
+		"🔎 *Fields*\n" +
+		"`provider:`, `vehicle:`, `mission:`, `pad:` and `orbit:` limit a term to one field. " +
+		"Terms without a field match the launch, vehicle and mission names.\n\n" +
+		"🧩 *Combining terms*\n" +
+		"• `AND`, `OR` and `NOT` combine terms, and must be written in upper case\n" +
+		"• Terms next to each other are joined with `AND`\n" +
+		"• Use parentheses to group terms: `(pad:Kourou OR pad:Wenchang)`\n\n" +
+		"✍️ *Terms*\n" +
+		"• Terms match anywhere in a field, and case doesn't matter\n" +
+		"• Quote phrases with spaces: `vehicle:\"Long March\"`\n" +
+		"• Wrap a regular expression in slashes: `mission:/^starlink (group|4)/`\n\n" +
+		"⚖️ *Precedence*\n" +
+		"Muted launches and blocked keywords always win, then block rules, then allowed keywords and allow rules, " +
+		"and finally your subscriptions.\n\n" +
+		fmt.Sprintf("💡 Rules can be up to %d characters long, and you can have %d of each kind", users.MaxRuleLength, users.MaxRulesPerAction)
+}
+
+// Keywords.RuleAddPrompt
+func (keywords *KeywordsMessage) RuleAddPrompt(action string) string {
+	return fmt.Sprintf("📐 *Add a %s Rule*\n\n"+
+		"Send me the filter rule you want to %s launches with.\n\n"+
+		"*Example:*\n"+
+		"`provider:SpaceX AND NOT mission:Starlink`\n\n"+
+		"💡 Fields: `provider`, `vehicle`, `mission`, `pad` and `orbit`\n\n"+
+		"Type /cancel if you change your mind.",
+		strings.Title(action), action)
+}
+
+// Keywords.RuleInvalid
+func (keywords *KeywordsMessage) RuleInvalid(err error, action string) string {
+	return fmt.Sprintf("⚠️ *That rule didn't work:* %s\n\n",
+		strings.NewReplacer("*", "\\*", "`", "\\`").Replace(err.Error())) + keywords.RuleAddPrompt(action)
+}
+
+// QuietHours.Main
+func (quiet *QuietHoursMessage) Main(chat *users.User) string {
+	hours := "Off"
+	if chat.QuietHours != "" {
+		hours = strings.Replace(chat.QuietHours, "-", " – ", 1)
+	}
+
+	return fmt.Sprintf("🌙 *LaunchBot* | *Quiet hours*\n"+
+		"No notifications are delivered during your quiet hours. Quiet hours follow your time zone, which is %s.\n\n"+
+		"*Quiet hours:* %s\n\n"+
+		"*During quiet hours, notifications are...*\n"+
+		"⏸️ *Held:* delivered as-is once quiet hours end\n"+
+		"📋 *Summarized:* listed in one message once quiet hours end\n"+
+		"🔕 *Dropped:* never delivered", chat.SavedTimeZoneInfo(), hours)
+}
+
+// QuietHours.SetPrompt
+func (quiet *QuietHoursMessage) SetPrompt() string {
+	return "🌙 *Set Quiet Hours*\n\n" +
+		"Send me your quiet hours, as the time they start and end in your time zone.\n\n" +
+		"*Examples:*\n" +
+		"`22-7` or `23:30-06:00`\n\n" +
+		"Type /cancel if you change your mind."
+}
+
+// QuietHours.Invalid
+func (quiet *QuietHoursMessage) Invalid(err error) string {
+	return fmt.Sprintf("⚠️ *Those hours didn't work:* %s\n\n", err.Error()) + quiet.SetPrompt()
+}
+
+// Digest.Main
+func (digest *DigestMessage) Main(chat *users.User) string {
+	schedule := "Off"
+
+	switch chat.DigestFrequency {
+	case users.DigestDaily:
+		schedule = fmt.Sprintf("Daily at %02d:00", chat.DigestHour)
+	case users.DigestWeekly:
+		schedule = fmt.Sprintf("%ss at %02d:00", chat.DigestWeekday, chat.DigestHour)
+	}
+
+	return fmt.Sprintf("📰 *LaunchBot* | *Launch digests*\n"+
+		"A digest is a single message listing the launches you follow that are coming up in the next day, "+
+		"or the next week, delivered at a time you choose. Times follow your time zone, which is %s.\n\n"+
+		"Digests are sent in addition to launch notifications. To only receive digests, turn your notification times off.\n\n"+
+		"*Your digest:* %s", chat.SavedTimeZoneInfo(), schedule)
+}
+
 // Topic.Main
 func (topic *TopicMessage) Main(topicId int64) string {
 	status := "Not configured (using general topic)"
@@ -312,3 +569,77 @@ func (topic *TopicMessage) SetPrompt() string {
 		"• The topic ID number\n\n" +
 		"_Send 0 to use the general topic._"
 }
+
+// Webcast.Main
+func (webcast *WebcastMessage) Main(chat *users.User) string {
+	preferred := "none, the launch's main webcast is used"
+
+	if chat.WebcastLanguages != "" {
+		preferred = strings.Join(chat.PreferredWebcastLanguages(), " ➙ ")
+	}
+
+	source := map[users.WebcastSource]string{
+		users.WebcastAnySource: "any", users.WebcastOfficial: "official webcasts",
+		users.WebcastThirdParty: "third-party streams",
+	}[chat.WebcastSource]
+
+	return "📺 *LaunchBot* | *Webcast preferences*\n\n" +
+		"Choose the languages you'd like to watch launches in. When a launch has a webcast in one of them, " +
+		"the notifications link to it instead of the launch's main webcast.\n\n" +
+		"Languages are preferred in the order you enable them in.\n\n" +
+		"You can also prefer the launch provider's official webcast, or streams by others, such as commentary streams.\n\n" +
+		fmt.Sprintf("*Your preference:* %s\n", strings.ToUpper(preferred)) +
+		fmt.Sprintf("*Source:* %s", source)
+}
+
+// Calendar.Main
+func (calendar *CalendarMessage) Main(chat *users.User, feedURL string) string {
+	text := "🗓️ *LaunchBot* | *Calendar feed*\n" +
+		"Your calendar feed is a personal link your calendar app can subscribe to. It lists the launches you follow, " +
+		"with your subscriptions, keyword filters and muted launches applied, and your calendar app keeps it up to date as launches move.\n\n"
+
+	if chat.CalendarToken == "" {
+		return text + "You don't have a calendar feed yet: create a link below, then add it to your calendar app as a subscription."
+	}
+
+	return text + fmt.Sprintf("*Your feed:* `%s%s.ics`\n\n", feedURL, chat.CalendarToken) +
+		"_Anyone with the link can see the launches you follow. If the link leaks, replace it with a new one, or revoke it._"
+}
+
+// Mission.Main
+func (mission *MissionFilterMessage) Main(chat *users.User) string {
+	// Summarize the current filters
+	summary := func(included string, excluded string) string {
+		parts := []string{}
+
+		if included != "" {
+			parts = append(parts, fmt.Sprintf("only %s", strings.ReplaceAll(included, ",", ", ")))
+		}
+
+		if excluded != "" {
+			parts = append(parts, fmt.Sprintf("never %s", strings.ReplaceAll(excluded, ",", ", ")))
+		}
+
+		if len(parts) == 0 {
+			return "all"
+		}
+
+		return strings.Join(parts, "; ")
+	}
+
+	return "🛰️ *LaunchBot* | *Orbit & mission filters*\n\n" +
+		"Filter the launches you're notified of by their orbit and mission type. These filters apply on top of your subscriptions.\n\n" +
+		"✅ *Included* values are the only ones you're notified of\n" +
+		"🚫 *Excluded* values are never notified\n\n" +
+		fmt.Sprintf("*Orbits:* %s\n", summary(chat.OrbitsIncluded, chat.OrbitsExcluded)) +
+		fmt.Sprintf("*Mission types:* %s", summary(chat.MissionTypesIncluded, chat.MissionTypesExcluded))
+}
+
+// Mission.Values
+func (mission *MissionFilterMessage) Values(kind string) string {
+	name := map[string]string{"orbit": "Orbits", "type": "Mission types"}[kind]
+
+	return fmt.Sprintf("🛰️ *LaunchBot* | *%s*\n\n", name) +
+		"Tap a value to cycle between ⚪ not filtered, ✅ included and 🚫 excluded.\n\n" +
+		"If you include any values, launches with an unknown value are not notified either."
+}
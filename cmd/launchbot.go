@@ -8,6 +8,7 @@ import (
 	"launchbot/logging"
 	"launchbot/sendables"
 	"launchbot/utils"
+	"launchbot/web"
 	"os"
 	"os/signal"
 	"syscall"
@@ -84,6 +85,9 @@ func main() {
 		verboseSpamLog bool
 		configPath     string
 		dataPath       string
+		fixturePath    string
+		recordDir      string
+		replayDir      string
 	)
 
 	// Command line arguments
@@ -94,6 +98,9 @@ func main() {
 	flag.BoolVar(&verboseSpamLog, "verbose-spam-log", false, "Specify to enable verbose spam and permission logging ")
 	flag.StringVar(&configPath, "config", "", "Path to config file (defaults to $LAUNCHBOT_CONFIG or ./data/config.json)")
 	flag.StringVar(&dataPath, "data", "", "Path to data directory (defaults to $LAUNCHBOT_DATA_DIR or ./data)")
+	flag.StringVar(&fixturePath, "fixtures", "", "Path to a directory of LL2 JSON fixtures, used instead of the LL2 API")
+	flag.StringVar(&recordDir, "record-dir", "", "Path to a directory raw LL2 responses are recorded to")
+	flag.StringVar(&replayDir, "replay-dir", "", "Path to a directory of recorded LL2 responses to replay against a copy of the database, then exit")
 
 	flag.Parse()
 
@@ -133,6 +140,26 @@ func main() {
 		Version:        fmt.Sprintf("%s (%s)", Version, GitSHA[0:7]),
 		Github:         "github.com/499602D2/tg-launchbot",
 		UseDevEndpoint: useDevEndpoint,
+		RecordDir:      recordDir,
+	}
+
+	if replayDir != "" {
+		// Replay recorded responses offline, against a copy of the database: no bots are started, and nothing is sent
+		tempDir, err := session.InitializeOffline(configPath, dataPath)
+
+		if err != nil {
+			log.Fatal().Err(err).Msg("Initializing offline session failed")
+		}
+
+		session.Cache.Populate()
+		err = api.Replay(session, replayDir)
+		os.RemoveAll(tempDir)
+
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Replaying responses from %s failed", replayDir)
+		}
+
+		return
 	}
 
 	// Signal handler (ctrl+c, etc.)
@@ -144,6 +171,20 @@ func main() {
 	// Assign remaining CLI flags
 	session.Spam.VerboseLog = verboseSpamLog
 
+	if fixturePath != "" {
+		// Read launch updates from JSON fixtures instead of the LL2 API
+		fileSource, err := api.NewFileSource(fixturePath)
+
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Loading fixtures from %s failed", fixturePath)
+		}
+
+		api.SetLaunchSource(fileSource)
+	}
+
+	// Track API requests against the hourly rate-limit
+	api.InitializeBudget(session)
+
 	if !noUpdates {
 		// Create a new task scheduler, assign to session
 		session.Scheduler = gocron.NewScheduler(time.UTC)
@@ -154,10 +195,13 @@ func main() {
 		// Populate the cache
 		session.Cache.Populate()
 
+		// Schedule the hourly digest jobs of each time zone
+		api.InitializeDigests(session)
+
 		if updateNow {
 			// Run API update manually and enable auto-scheduler
 			log.Info().Msg("--Update-now specified, running API update")
-			go api.Updater(session, true)
+			go api.UpdateNow(session)
 		} else {
 			// Start scheduler normally, but use the startup flag
 			go api.Scheduler(session, true, nil, false)
@@ -174,6 +218,13 @@ func main() {
 		log.Fatal().Err(err).Msg("Starting statistics gocron job failed")
 	}
 
+	// Deliver notifications held back during quiet hours, once quiet hours end
+	_, err = scheduler.Every(1).Minute().Do(session.Telegram.DeliverQuietHourNotifications)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Starting quiet hours gocron job failed")
+	}
+
 	// Run scheduled jobs async
 	scheduler.StartAsync()
 
@@ -185,6 +236,9 @@ func main() {
 
 	log.Info().Msgf("Telegram bot started (@%s)", session.Telegram.Username)
 
+	// Serve the chats' calendar feeds and the REST API over HTTP, if enabled in the config
+	web.NewServer(session).Start()
+
 	if session.Telegram.Owner != 0 {
 		// If owner is configured, notify of startup
 		startSendable := sendables.TextOnlySendable(
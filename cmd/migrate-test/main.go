@@ -302,8 +302,7 @@ func runFunctionalityTests(db *gorm.DB) {
 	// Test 2: User notification query
 	totalTests++
 	var notificationUsers []users.User
-	err = db.Where("enabled24h = ? OR enabled12h = ? OR enabled1h = ? OR enabled5min = ?", 
-		true, true, true, true).Limit(5).Find(&notificationUsers).Error
+	err = db.Where("lead_times != ?", "").Limit(5).Find(&notificationUsers).Error
 	if err == nil {
 		fmt.Printf("✓ User notification query (found %d users)\n", len(notificationUsers))
 		testsPassed++
@@ -11,6 +11,7 @@ import (
 	"launchbot/bots/telegram"
 	"launchbot/db"
 	"launchbot/users"
+	"launchbot/utils"
 	"os"
 	"path/filepath"
 	"strings"
@@ -32,11 +33,14 @@ type Session struct {
 	Scheduler         *gocron.Scheduler                   // Gocron scheduler
 	Tasks             []*gocron.Job                       // List of Gocron jobs
 	NotificationTasks map[time.Time]*gocron.Job           // Map a time to a scheduled Gocron job
+	DigestJobs        map[string]*gocron.Job              // Map a time zone to its hourly digest job
 	Scheduled         []string                            // A list of launch IDs that have a notification scheduled
 	Version           string                              // Version number
 	Started           time.Time                           // Unix timestamp of startup time
 	UseDevEndpoint    bool                                // Configure to use LL2's development endpoint
+	RecordDir         string                              // Directory raw LL2 responses are recorded to
 	Github            string                              // Github link
+	Clock             utils.Clock                         // Source of the current time for timing decisions
 	Mutex             sync.Mutex                          // Avoid concurrent writes
 }
 
@@ -47,10 +51,47 @@ type Config struct {
 	Owner              int64      // Telegram owner id
 	BroadcastTokenPool int        // Broadcast rate-limit, msg/sec (<= 30)
 	BroadcastBurstPool int        // Broadcast bursting limit, msg/sec
+	ApiHorizon         Horizon    // How far ahead launches are fetched from the API
+	ApiRateLimit       int        // API requests allowed per hour
+	TelegramApiURL     string     `json:",omitempty"` // Self-hosted Telegram Bot API server (optional)
+	HttpServer         HttpServer // Embedded HTTP server for calendar feeds (disabled by default)
 	Mutex              sync.Mutex // Mutex to avoid concurrent writes
 	ConfigPath         string     `json:"-"` // Path to the config file (not saved in JSON)
 }
 
+// Horizon limits how far ahead launches are fetched from LL2. Every 100 launches
+// cost one API request per update, so keep this low to stay within rate-limits.
+// Without a launch limit, the days-horizon bounds the fetch, which is further
+// capped to a few requests per update.
+type Horizon struct {
+	Days     int // Fetch launches up to this many days ahead (0 = no limit)
+	Launches int // Fetch at most this many launches (0 = no limit, requires Days)
+}
+
+// HttpServer configures the embedded HTTP server, serving the chats' calendar feeds and the REST API
+type HttpServer struct {
+	Enabled bool     // Start the HTTP server
+	Address string   // Address to listen on, e.g. ":8080"
+	BaseURL string   // Public URL the server is reachable at, e.g. "https://launchbot.example.com"
+	ApiKeys []string // Keys accepted by the read-only REST API (no keys = API disabled)
+}
+
+// Returns the base URL of calendar feeds, or an empty string if the server is disabled
+func (server *HttpServer) CalendarFeedURL() string {
+	if !server.Enabled {
+		return ""
+	}
+
+	baseURL := server.BaseURL
+
+	if baseURL == "" {
+		// Without a public URL, fall back to the listening address
+		baseURL = "http://localhost" + server.Address
+	}
+
+	return strings.TrimSuffix(baseURL, "/") + "/calendar/"
+}
+
 // ApiTokens contains the API tokens used by the bot(s)
 type ApiTokens struct {
 	Telegram string
@@ -69,6 +110,7 @@ func (session *Session) Initialize() {
 		Launches:  []*db.Launch{},
 		LaunchMap: make(map[string]*db.Launch),
 		Users:     &users.UserCache{},
+		Clock:     session.clock(),
 	}
 
 	// Open database (TODO remove owner tag)
@@ -83,10 +125,13 @@ func (session *Session) Initialize() {
 
 	// Initialize the Telegram bot
 	session.Telegram = &telegram.Bot{
-		Owner: session.Config.Owner,
-		Spam:  session.Spam,
-		Cache: session.Cache,
-		Db:    session.Db,
+		Owner:  session.Config.Owner,
+		Spam:   session.Spam,
+		Cache:  session.Cache,
+		Db:     session.Db,
+		ApiURL: session.Config.TelegramApiURL,
+
+		CalendarFeedURL: session.Config.HttpServer.CalendarFeedURL(),
 	}
 
 	// Init stats
@@ -98,6 +143,26 @@ func (session *Session) Initialize() {
 	session.Telegram.Initialize(session.Config.Token.Telegram)
 }
 
+// Returns the session's clock, defaulting to the system clock if none is set
+func (session *Session) clock() utils.Clock {
+	if session.Clock == nil {
+		session.Clock = utils.RealClock{}
+	}
+
+	return session.Clock
+}
+
+// Returns the current time, according to the session's clock
+func (session *Session) Now() time.Time {
+	return session.clock().Now()
+}
+
+// Converts a time on the session's clock to the wall-clock time the scheduler runs on.
+// With the system clock, the two are the same.
+func (session *Session) SchedulerTime(clockTime time.Time) time.Time {
+	return time.Now().Add(clockTime.Sub(session.Now()))
+}
+
 // SaveConfig dumps the config to disk
 func SaveConfig(config *Config) {
 	SaveConfigToPath(config, config.ConfigPath)
@@ -143,23 +208,9 @@ func SaveConfigToPath(config *Config, configPath string) {
 
 // InitializeWithPaths initializes the session with custom config and data paths
 func (session *Session) InitializeWithPaths(configPath, dataPath string) {
-	// Load config from specified paths
+	// Load config, open the cache and the database
 	session.Config = LoadConfigFromPath(configPath, dataPath)
-
-	// Init notification task map
-	session.NotificationTasks = make(map[time.Time]*gocron.Job)
-
-	// Initialize cache
-	session.Cache = &db.Cache{
-		Launches:  []*db.Launch{},
-		LaunchMap: make(map[string]*db.Launch),
-		Users:     &users.UserCache{},
-	}
-
-	// Open database (TODO remove owner tag)
-	session.Db = &db.Database{Owner: session.Config.Owner, Cache: session.Cache}
-	session.Db.Open(session.Config.DbFolder)
-	session.Cache.Database = session.Db
+	session.openDatabase(session.Config.DbFolder)
 
 	// Create and initialize the anti-spam system
 	session.Spam = &bots.Spam{}
@@ -168,10 +219,13 @@ func (session *Session) InitializeWithPaths(configPath, dataPath string) {
 
 	// Initialize the Telegram bot
 	session.Telegram = &telegram.Bot{
-		Owner: session.Config.Owner,
-		Spam:  session.Spam,
-		Cache: session.Cache,
-		Db:    session.Db,
+		Owner:  session.Config.Owner,
+		Spam:   session.Spam,
+		Cache:  session.Cache,
+		Db:     session.Db,
+		ApiURL: session.Config.TelegramApiURL,
+
+		CalendarFeedURL: session.Config.HttpServer.CalendarFeedURL(),
 	}
 
 	// Init stats
@@ -183,6 +237,58 @@ func (session *Session) InitializeWithPaths(configPath, dataPath string) {
 	session.Telegram.Initialize(session.Config.Token.Telegram)
 }
 
+// InitializeOffline loads the config, and opens the cache and a copy of the database,
+// without starting any bots. Used e.g. when replaying recorded API responses: the
+// copy lives in a temporary directory, returned so the caller can remove it.
+func (session *Session) InitializeOffline(configPath, dataPath string) (string, error) {
+	// Load config from specified paths
+	session.Config = LoadConfigFromPath(configPath, dataPath)
+
+	// Copy the database, and any write-ahead log, so the live data is never modified
+	tempDir, err := os.MkdirTemp("", "launchbot-offline-")
+
+	if err != nil {
+		return "", err
+	}
+
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		src := filepath.Join(session.Config.DbFolder, "launchbot.db"+suffix)
+
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+
+		if err := copyFile(src, filepath.Join(tempDir, "launchbot.db"+suffix)); err != nil {
+			os.RemoveAll(tempDir)
+			return "", fmt.Errorf("copying the database failed: %w", err)
+		}
+	}
+
+	log.Info().Msgf("Using a copy of the database at %s", tempDir)
+	session.openDatabase(tempDir)
+
+	return tempDir, nil
+}
+
+// Initializes the cache, and opens the database in dbFolder
+func (session *Session) openDatabase(dbFolder string) {
+	// Init notification task map
+	session.NotificationTasks = make(map[time.Time]*gocron.Job)
+
+	// Initialize cache
+	session.Cache = &db.Cache{
+		Launches:  []*db.Launch{},
+		LaunchMap: make(map[string]*db.Launch),
+		Users:     &users.UserCache{},
+		Clock:     session.clock(),
+	}
+
+	// Open database (TODO remove owner tag)
+	session.Db = &db.Database{Owner: session.Config.Owner, Cache: session.Cache}
+	session.Db.Open(dbFolder)
+	session.Cache.Database = session.Db
+}
+
 // LoadConfig loads the config and returns a pointer to it
 func LoadConfig() *Config {
 	return LoadConfigFromPath("", "")
@@ -275,6 +381,8 @@ func LoadConfigFromPath(configPath, dataPath string) *Config {
 			Token:              ApiTokens{Telegram: botToken},
 			BroadcastTokenPool: 20,
 			BroadcastBurstPool: 5,
+			ApiHorizon:         Horizon{Launches: 100},
+			ApiRateLimit:       15,
 			DbFolder:           dataPath,
 			ConfigPath:         configf,
 		}
@@ -308,6 +416,24 @@ func LoadConfigFromPath(configPath, dataPath string) *Config {
 		config.BroadcastBurstPool = 5
 	}
 
+	if config.ApiRateLimit <= 0 {
+		// LL2's free tier allows 15 requests per hour
+		config.ApiRateLimit = 15
+	}
+
+	if config.ApiHorizon.Launches < 0 {
+		log.Warn().Msgf("Invalid API horizon (%d launches): using no launch limit", config.ApiHorizon.Launches)
+		config.ApiHorizon.Launches = 0
+	}
+
+	if config.ApiHorizon.Launches == 0 && config.ApiHorizon.Days <= 0 {
+		// Nothing bounds the horizon: default to fetching the next 100 launches, costing one request per update
+		config.ApiHorizon.Launches = 100
+	} else if config.ApiHorizon.Launches > 300 {
+		log.Warn().Msgf("Very large API horizon (%d launches): every update will use %d API requests",
+			config.ApiHorizon.Launches, (config.ApiHorizon.Launches+99)/100)
+	}
+
 	// Store the config path and ensure absolute DbFolder path
 	config.ConfigPath = configf
 	if config.DbFolder == "" || config.DbFolder == "data" {
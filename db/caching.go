@@ -3,7 +3,9 @@ package db
 import (
 	"errors"
 	"launchbot/users"
+	"launchbot/utils"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,9 +19,19 @@ type Cache struct {
 	Updated   time.Time          // Time the cache was last updated
 	Users     *users.UserCache   // Cached users
 	Database  *Database          // Database associated with this cache
+	Clock     utils.Clock        // Source of the current time (defaults to the system clock)
 	Mutex     sync.Mutex
 }
 
+// Returns the current time, according to the cache's clock
+func (cache *Cache) Now() time.Time {
+	if cache == nil || cache.Clock == nil {
+		return time.Now()
+	}
+
+	return cache.Clock.Now()
+}
+
 // Updates cache with a list of fresh launches
 func (cache *Cache) UpdateWithNew(launches []*Launch) {
 	cache.Mutex.Lock()
@@ -57,9 +69,10 @@ func (cache *Cache) UpdateWithNew(launches []*Launch) {
 			// Copy notification states if old launch exists
 			launch.NotificationState = oldLaunch.NotificationState
 			launch.SentNotificationIds = oldLaunch.SentNotificationIds
+			launch.FinalNotifiedChats = oldLaunch.FinalNotifiedChats
 		} else {
-			// If states don't exist, initialize from struct's values
-			launch.NotificationState.UpdateMap(launch)
+			// If states don't exist, initialize them as unsent
+			launch.NotificationState.Init()
 		}
 
 		// Save new launch
@@ -67,7 +80,7 @@ func (cache *Cache) UpdateWithNew(launches []*Launch) {
 		cache.LaunchMap[launch.Id] = launch
 	}
 
-	cache.Updated = time.Now()
+	cache.Updated = cache.Now()
 }
 
 // Populates the cache from database
@@ -78,7 +91,7 @@ func (cache *Cache) Populate() {
 	var launches []*Launch
 
 	// Find all launches that have not launched
-	result := cache.Database.Conn.Model(&Launch{}).Where("launched = ? AND net_unix > ?", 0, time.Now().Unix()).Find(&launches)
+	result := cache.Database.Conn.Model(&Launch{}).Where("launched = ? AND net_unix > ?", 0, cache.Now().Unix()).Find(&launches)
 
 	switch result.Error {
 	case nil:
@@ -87,6 +100,9 @@ func (cache *Cache) Populate() {
 		log.Error().Err(result.Error).Msg("Encountered error while populating launch cache")
 	}
 
+	// Load the launcher stages, URLs and notification states, stored in their own tables
+	cache.Database.LoadLaunchRelations(launches)
+
 	// Assign to cache
 	cache.Launches = launches
 
@@ -98,8 +114,8 @@ func (cache *Cache) Populate() {
 		// Assign to map
 		cache.LaunchMap[launch.Id] = launch
 
-		// Init the notification states
-		launch.NotificationState.UpdateMap(launch)
+		// Init the notification states, in case loading them failed
+		launch.NotificationState.Init()
 	}
 
 	// Finally, sort the cache by NET
@@ -134,6 +150,9 @@ func (cache *Cache) FindNextNotification() *Notification {
 	is otherwise done in an inadvantegous way. */
 	allowedNetSlip := time.Duration(-5) * time.Minute
 
+	// Current time, according to the cache's clock
+	now := cache.Now()
+
 	for _, launch := range cache.Launches {
 		// If launch time is TBD or in the past, don't notify
 		if (launch.Status.Abbrev == "Go") || (launch.Status.Abbrev == "TBC") || (launch.Status.Abbrev == "Hold") {
@@ -147,7 +166,7 @@ func (cache *Cache) FindNextNotification() *Notification {
 			}
 
 			// Verify the launch-time is not in the past by more than the allowed slip window
-			if allowedNetSlip.Seconds() > time.Until(time.Unix(next.SendTime, 0)).Seconds() {
+			if allowedNetSlip.Seconds() > time.Unix(next.SendTime, 0).Sub(now).Seconds() {
 				log.Warn().Msgf("[cache.findNext()] Launch %s is more than 5 minutes into the past",
 					next.LaunchName)
 
@@ -178,7 +197,7 @@ func (cache *Cache) FindNextNotification() *Notification {
 	// If time is non-zero, there's at least one non-TBD launch
 	if earliestTime != 0 {
 		// Calculate time until notification(s)
-		toNext := durafmt.Parse(time.Until(time.Unix(earliestTime, 0))).LimitFirstN(2)
+		toNext := durafmt.Parse(time.Unix(earliestTime, 0).Sub(now)).LimitFirstN(2)
 
 		log.Info().Msgf("Next notification send time %s from now, %d launch(es)",
 			toNext, len(notificationTimes[earliestTime]))
@@ -197,57 +216,35 @@ func (cache *Cache) FindNextNotification() *Notification {
 	// Select the list of launches for the earliest timestamp
 	notificationList := notificationTimes[earliestTime]
 
-	// If more then one, prioritize them
-	if len(notificationList) > 1 {
-		// Add more weight to the latest notifications
-		timeWeights := map[string]int{
-			"24h": 1, "12h": 2,
-			"1h": 3, "5min": 4,
-		}
-
-		// Keep track of largest encountered key (timeWeight)
-		maxTimeWeight := 0
+	/* Group the launches by lead time, most urgent first. The most urgent notification
+	is the primary one, e.g. a 5-minute notification over a 24-hour one. */
+	sort.SliceStable(notificationList, func(i, j int) bool {
+		return notificationList[i].LeadTime < notificationList[j].LeadTime
+	})
 
-		// Map the weights to a single NotificationTime type
-		weighedNotifs := make(map[int]Notification)
+	firstNotif := notificationList[0]
+	firstNotif.Count = len(notificationList)
 
-		// Loop over the launches we found at this timestamp
-		for _, notifTime := range notificationList {
-			// Add to the weighed map
-			weighedNotifs[timeWeights[notifTime.Type]] = notifTime
+	for _, notifTime := range notificationList {
+		firstNotif.IDs = append(firstNotif.IDs, notifTime.LaunchId)
 
-			// If weight is greater than the largest encountered, update
-			if timeWeights[notifTime.Type] > maxTimeWeight {
-				maxTimeWeight = timeWeights[notifTime.Type]
-			}
-		}
-
-		// Assign highest-value key found as the primary notification
-		firstNotif := weighedNotifs[maxTimeWeight]
-		firstNotif.Count = len(notificationList)
-		firstNotif.IDs = append(firstNotif.IDs, firstNotif.LaunchId)
-
-		// Add other launches to the list
-		for _, notifTime := range notificationList {
-			if notifTime.LaunchId != firstNotif.LaunchId {
-				firstNotif.IDs = append(firstNotif.IDs, notifTime.LaunchId)
-			}
+		if last := len(firstNotif.Groups) - 1; last >= 0 && firstNotif.Groups[last].LeadTime == notifTime.LeadTime {
+			firstNotif.Groups[last].IDs = append(firstNotif.Groups[last].IDs, notifTime.LaunchId)
+		} else {
+			firstNotif.Groups = append(firstNotif.Groups, NotificationGroup{LeadTime: notifTime.LeadTime, IDs: []string{notifTime.LaunchId}})
 		}
+	}
 
-		log.Debug().Msgf("Total of %d launches in the notification list after parsing:",
-			len(firstNotif.IDs))
+	if len(notificationList) > 1 {
+		log.Debug().Msgf("Total of %d launches in %d lead time group(s) after parsing:",
+			len(firstNotif.IDs), len(firstNotif.Groups))
 
-		for i, id := range firstNotif.IDs {
-			log.Debug().Msgf("[%d] %s", i+1, id)
+		for i, group := range firstNotif.Groups {
+			log.Debug().Msgf("[%d] %s: %s", i+1, users.LeadTimeType(group.LeadTime), strings.Join(group.IDs, ", "))
 		}
-
-		return &firstNotif
 	}
 
-	// Otherwise, we only have one notification: return it
-	onlyNotif := notificationList[0]
-	onlyNotif.IDs = append(onlyNotif.IDs, onlyNotif.LaunchId)
-	return &onlyNotif
+	return &firstNotif
 }
 
 // Computes the time interval until next scheduled API update, also returning
@@ -260,7 +257,7 @@ func (cache *Cache) NextScheduledUpdateIn() (time.Duration, *Notification) {
 	This is not the same thing as the launch's NET, as the notifications
 	are configured to be pre-sent with enough time to allow them to be received
 	in time by each recipient. */
-	timeUntil := time.Until(time.Unix(notification.SendTime, 0))
+	timeUntil := time.Unix(notification.SendTime, 0).Sub(cache.Now())
 
 	// The time interval to wait until next API update
 	var autoUpdateIn time.Duration
@@ -269,27 +266,33 @@ func (cache *Cache) NextScheduledUpdateIn() (time.Duration, *Notification) {
 	the time until said notification. Do note, that this is only a regular,
 	scheduled check. A final check will be performed just before a notification
 	is sent, independent of these scheduled checks. */
-	switch notification.Type {
-	case "24h":
-		// 24-hour window (?h ... 24h)
+	switch leadTime := notification.LeadTime; {
+	case leadTime >= 1440:
+		// Day-or-more window (?h ... 24h)
 		if timeUntil.Hours() >= 6 {
 			autoUpdateIn = time.Hour * 6
 		} else {
 			autoUpdateIn = time.Hour * 3
 		}
-	case "12h":
-		// 12-hour window (24h ... 12h)
+	case leadTime >= 360:
+		// Window of several hours (24h ... 12h)
 		autoUpdateIn = time.Hour * 3
-	case "1h":
-		// 1-hour window (12h ... 1h)
+	case leadTime > users.FinalLeadTime:
+		// Hour-ish window (12h ... 1h)
 		if timeUntil.Hours() >= 4 {
 			autoUpdateIn = time.Hour * 2
 		} else {
 			autoUpdateIn = time.Hour
 		}
-	case "5min":
-		// 5-min window (1h ... 5 min), less than 55 minutes
+	case leadTime > 0:
+		// Final window (1h ... 5 min), less than 55 minutes
 		autoUpdateIn = time.Minute * 15
+
+		// Webcasts usually go live in this window: poll more often to catch the moment
+		if launch, ok := cache.LaunchMap[notification.LaunchId]; ok && !launch.WebcastIsLive {
+			log.Debug().Msgf("Webcast not live yet, modifying auto-update time to 5 minutes")
+			autoUpdateIn = time.Minute * 5
+		}
 	default:
 		// Default case, needed for debugging without a working database
 		log.Error().Msgf("Next notification's type fell through: %#v", notification)
@@ -318,17 +321,24 @@ func (cache *Cache) FindLaunchById(id string) (*Launch, error) {
 		return launch, nil
 	}
 
-	// Launch not found in cache: check the disk, avoid SQL injection
+	// Launch not found in cache: check the disk
+	return cache.Database.LoadLaunch(id)
+}
+
+// Loads a launch, with its launcher stages and URLs, from the database by a launch ID
+func (db *Database) LoadLaunch(id string) (*Launch, error) {
+	// Avoid SQL injection
 	// https://gorm.io/docs/query.html#Retrieving-objects-with-primary-key
 	thisLaunch := Launch{}
-	result := cache.Database.Conn.Model(&thisLaunch).First(&thisLaunch, "id = ?", id)
+	result := db.Conn.Model(&thisLaunch).First(&thisLaunch, "id = ?", id)
 
 	if result.Error != nil {
-		log.Error().Err(result.Error).Msgf("Error searching for launch in the database with id=%s", id)
+		log.Debug().Err(result.Error).Msgf("Launch with id=%s not found in the database", id)
 		err := errors.New("Launch not found")
 		return nil, err
 	}
 
-	// Launch was found: return it
+	// Launch was found: load its launcher stages and URLs, and return it
+	db.LoadLaunchRelations([]*Launch{&thisLaunch})
 	return &thisLaunch, nil
 }
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"fmt"
+	"launchbot/users"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+)
+
+/*
+Launches are exported as iCalendar (RFC 5545) events. Each event's UID is derived
+from the launch's LL2 id, so that re-importing an export, or refreshing a feed,
+updates the events of launches that slipped instead of duplicating them. The event's
+SEQUENCE is the count of NET changes detected in the launch, so clients replace the
+event they have with the moved one.
+*/
+
+// Format of date-times in iCalendar, in UTC
+const calendarTimeFormat = "20060102T150405Z"
+
+// Lines longer than this many octets are folded
+const calendarLineLength = 75
+
+// Finds the chat a calendar feed token belongs to, returning false if no chat has the token
+func (db *Database) FindUserByCalendarToken(token string) (*users.User, bool) {
+	if token == "" {
+		return nil, false
+	}
+
+	user := users.User{}
+	result := db.Conn.Select("id", "platform").Where("calendar_token = ?", token).Limit(1).Find(&user)
+
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("Loading chat by calendar token failed")
+		return nil, false
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, false
+	}
+
+	// Use the cached chat, whose token must still match
+	chat := db.Cache.FindUser(user.Id, user.Platform)
+
+	return chat, chat.CalendarToken == token
+}
+
+// Builds an iCalendar file of the launches, with the calendar named after name. Sequences
+// maps launch ids to the count of NET changes in each launch.
+func LaunchCalendar(launches []*Launch, name string, now time.Time, sequences map[string]int) []byte {
+	var builder strings.Builder
+
+	writeCalendarLine(&builder, "BEGIN:VCALENDAR")
+	writeCalendarLine(&builder, "VERSION:2.0")
+	writeCalendarLine(&builder, "PRODID:-//LaunchBot//Launch calendar//EN")
+	writeCalendarLine(&builder, "CALSCALE:GREGORIAN")
+	writeCalendarLine(&builder, "METHOD:PUBLISH")
+	writeCalendarLine(&builder, "X-WR-CALNAME:"+escapeCalendarText(name))
+
+	for _, launch := range launches {
+		launch.writeCalendarEvent(&builder, now, sequences[launch.Id])
+	}
+
+	writeCalendarLine(&builder, "END:VCALENDAR")
+
+	return []byte(builder.String())
+}
+
+// Returns the launch's stable iCalendar UID
+func (launch *Launch) CalendarUID() string {
+	return fmt.Sprintf("%s@launchbot", launch.Id)
+}
+
+// Returns the start and end of the launch's window, falling back to the NET if the window is unknown
+func (launch *Launch) windowTimes() (time.Time, time.Time) {
+	net := time.Unix(launch.NETUnix, 0).UTC()
+
+	start, err := time.Parse(time.RFC3339, launch.WindowStart)
+
+	if err != nil {
+		start = net
+	}
+
+	end, err := time.Parse(time.RFC3339, launch.WindowEnd)
+
+	if err != nil || end.Before(start) {
+		end = start
+	}
+
+	return start.UTC(), end.UTC()
+}
+
+// Writes the launch as an iCalendar event
+func (launch *Launch) writeCalendarEvent(builder *strings.Builder, now time.Time, sequence int) {
+	start, end := launch.windowTimes()
+	net := time.Unix(launch.NETUnix, 0).UTC()
+
+	// Description: NET, window, status, mission information and the webcast
+	description := fmt.Sprintf("NET: %s\n", net.Format("2006-01-02 15:04 MST"))
+
+	if !end.Equal(start) {
+		description += fmt.Sprintf("Window: %s – %s\n", start.Format("2006-01-02 15:04"), end.Format("15:04 MST"))
+	}
+
+	description += fmt.Sprintf("Status: %s\n", launch.Status.Name)
+	description += fmt.Sprintf("Provider: %s\nVehicle: %s\n", launch.LaunchProvider.Name, launch.Rocket.Config.FullName)
+
+	if launch.Mission.Name != "" {
+		description += fmt.Sprintf("Mission: %s\n", launch.Mission.Name)
+	}
+
+	if launch.Mission.Orbit.Name != "" {
+		description += fmt.Sprintf("Orbit: %s\n", launch.Mission.Orbit.Name)
+	}
+
+	if strings.TrimSpace(launch.Mission.Description) != "" {
+		description += fmt.Sprintf("\n%s\n", strings.TrimSpace(launch.Mission.Description))
+	}
+
+	if launch.WebcastLink != "" {
+		description += fmt.Sprintf("\nWebcast: %s\n", launch.WebcastLink)
+	}
+
+	// A launch that is go is confirmed, anything else may still move
+	status := "TENTATIVE"
+
+	if launch.Status.Abbrev == "Go" {
+		status = "CONFIRMED"
+	}
+
+	writeCalendarLine(builder, "BEGIN:VEVENT")
+	writeCalendarLine(builder, "UID:"+launch.CalendarUID())
+	writeCalendarLine(builder, "DTSTAMP:"+now.UTC().Format(calendarTimeFormat))
+	writeCalendarLine(builder, fmt.Sprintf("SEQUENCE:%d", sequence))
+	writeCalendarLine(builder, "DTSTART:"+start.Format(calendarTimeFormat))
+
+	// An instantaneous window ends when it starts: DTEND is left out
+	if !end.Equal(start) {
+		writeCalendarLine(builder, "DTEND:"+end.Format(calendarTimeFormat))
+	}
+
+	if updated, err := time.Parse(time.RFC3339, launch.LastUpdated); err == nil {
+		writeCalendarLine(builder, "LAST-MODIFIED:"+updated.UTC().Format(calendarTimeFormat))
+	}
+
+	writeCalendarLine(builder, "SUMMARY:"+escapeCalendarText("🚀 "+launch.Name))
+	writeCalendarLine(builder, "LOCATION:"+escapeCalendarText(
+		fmt.Sprintf("%s, %s", launch.LaunchPad.Name, launch.LaunchPad.Location.Name)))
+	writeCalendarLine(builder, "DESCRIPTION:"+escapeCalendarText(strings.TrimSpace(description)))
+	writeCalendarLine(builder, "STATUS:"+status)
+
+	if launch.WebcastLink != "" {
+		writeCalendarLine(builder, "URL:"+launch.WebcastLink)
+	}
+
+	writeCalendarLine(builder, "END:VEVENT")
+}
+
+// Escapes text for an iCalendar property value
+func escapeCalendarText(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`,
+	).Replace(text)
+}
+
+// Writes a content line, folding it into lines of at most 75 octets without splitting characters
+func writeCalendarLine(builder *strings.Builder, line string) {
+	limit := calendarLineLength
+
+	for len(line) > limit {
+		cut := limit
+
+		// Back up to the start of a character
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		builder.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+
+		// Continuation lines start with a space, which counts towards their length
+		limit = calendarLineLength - 1
+	}
+
+	builder.WriteString(line + "\r\n")
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"launchbot/users"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that launches are exported as events with stable UIDs, and that long lines are folded
+func TestLaunchCalendar(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	windowed := &Launch{
+		Id: "windowed", Name: "Falcon 9 Block 5 | Starlink Group 4-1", NETUnix: 1664971200,
+		WindowStart: "2022-10-05T12:00:00Z", WindowEnd: "2022-10-05T14:00:00Z",
+		Status:      LaunchStatus{Name: "Go for Launch", Abbrev: "Go"},
+		WebcastLink: "https://example.com/webcast",
+		Mission: Mission{
+			Name:        "Starlink Group 4-1",
+			Description: strings.Repeat("A batch of satellites, for broadband; ", 5),
+		},
+	}
+
+	instantaneous := &Launch{
+		Id: "instant", Name: "Electron | Test", NETUnix: 1665057600,
+		Status: LaunchStatus{Name: "To Be Determined", Abbrev: "TBD"},
+	}
+
+	calendar := string(LaunchCalendar([]*Launch{windowed, instantaneous}, "Launches", now, map[string]int{"windowed": 2}))
+
+	for _, expected := range []string{
+		"BEGIN:VCALENDAR\r\n", "UID:windowed@launchbot\r\n", "UID:instant@launchbot\r\n",
+		"DTSTART:20221005T120000Z\r\n", "DTEND:20221005T140000Z\r\n", "DTSTAMP:20221001T120000Z\r\n",
+		"URL:https://example.com/webcast\r\n", "STATUS:CONFIRMED\r\n", "STATUS:TENTATIVE\r\n",
+		"DTSTART:20221006T120000Z\r\n", "SEQUENCE:2\r\n", "SEQUENCE:0\r\n", "END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(calendar, expected) {
+			t.Errorf("expected the calendar to contain %q", expected)
+		}
+	}
+
+	// The instantaneous launch has no end, and the windowed launch one
+	if count := strings.Count(calendar, "DTEND:"); count != 1 {
+		t.Errorf("expected exactly one DTEND, got %d", count)
+	}
+
+	for _, line := range strings.Split(calendar, "\r\n") {
+		if len(line) > calendarLineLength {
+			t.Errorf("line longer than %d octets: %q", calendarLineLength, line)
+		}
+	}
+
+	// Unfolded, the description keeps its text, with special characters escaped
+	unfolded := strings.ReplaceAll(calendar, "\r\n ", "")
+
+	if !strings.Contains(unfolded, `A batch of satellites\, for broadband\;`) {
+		t.Errorf("expected an escaped description, got %s", unfolded)
+	}
+}
+
+// Tests that the subscribed launches include launches only an allowed keyword includes,
+// and exclude muted launches
+func TestLaunchesUserHasSubscribedTo(t *testing.T) {
+	starlink := &Launch{Id: "starlink", Name: "Falcon 9 Block 5 | Starlink Group 4-1", LaunchProvider: LaunchProvider{Id: 121}}
+	other := &Launch{Id: "other", Name: "Electron | Test", LaunchProvider: LaunchProvider{Id: 147}}
+	cache := &Cache{Launches: []*Launch{starlink, other}}
+
+	// The chat has subscribed to no providers, but allows Starlink launches
+	user := &users.User{Id: "1", Platform: "tg", AllowedKeywords: "starlink"}
+	followed := cache.LaunchesUserHasSubscribedTo(user)
+
+	if len(followed) != 1 || followed[0] != starlink {
+		t.Errorf("expected only the allowed launch to be followed, got %d launch(es)", len(followed))
+	}
+
+	// The chat has subscribed to all launches, but muted one
+	user = &users.User{Id: "2", Platform: "tg", SubscribedAll: true, MutedLaunches: "other"}
+	followed = cache.LaunchesUserHasSubscribedTo(user)
+
+	if len(followed) != 1 || followed[0] != starlink {
+		t.Errorf("expected the muted launch to be left out, got %d launch(es)", len(followed))
+	}
+}
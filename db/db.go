@@ -27,6 +27,14 @@ type Database struct {
 	Subscribers       int64
 	MonthlyActiveUsers int64
 	Mutex             sync.Mutex
+
+	leadTimes      []int // Lead times any chat has enabled, nil if not loaded
+	leadTimesMutex sync.Mutex
+}
+
+// Returns the current time, according to the clock of the database's cache
+func (db *Database) Now() time.Time {
+	return db.Cache.Now()
 }
 
 func (db *Database) SetSize() {
@@ -109,14 +117,47 @@ func (db *Database) Open(dbFolder string) bool {
 	launches := Launch{}
 	users := users.User{}
 	stats := stats.Statistics{}
+	events := ChangeEvent{}
+	launchers := Launcher{}
+	urls := ContentURL{}
+	providers := Provider{}
+	sites := LaunchSite{}
+	sends := NotificationSend{}
+	deferred := DeferredNotification{}
+
+	// Databases from before lead times store the four fixed notification times in columns
+	migrateLeadTimes := db.Conn.Migrator().HasColumn(&users, "enabled24h") && !db.Conn.Migrator().HasColumn(&users, "LeadTimes")
+	migrateSends := db.Conn.Migrator().HasColumn(&launches, "sent24h") && !db.Conn.Migrator().HasTable(&sends)
+
+	// Databases from before the launchers-table store the core and two side boosters in columns
+	migrateLaunchers := db.Conn.Migrator().HasColumn(&launches, "rocket_launcher_count")
 
 	// Run auto-migration: creates tables that don't exist and adds missing cols
-	err = db.Conn.AutoMigrate(&launches, &users, &stats)
+	err = db.Conn.AutoMigrate(&launches, &users, &stats, &events, &launchers, &urls, &providers, &sites, &sends, &deferred)
 
 	if err != nil {
 		log.Fatal().Err(err).Msg("Running auto-migration failed")
 	}
 
+	if migrateLeadTimes || migrateSends {
+		if err = db.migrateFixedNotificationTimes(migrateLeadTimes, migrateSends); err != nil {
+			log.Fatal().Err(err).Msg("Migrating notification times failed")
+		}
+	}
+
+	if migrateLaunchers {
+		if err = db.migrateEmbeddedLaunchers(); err != nil {
+			log.Fatal().Err(err).Msg("Migrating launcher stages failed")
+		}
+	}
+
+	// Load the provider registry, used for subscriptions
+	err = Providers.Load(db)
+
+	if err != nil {
+		log.Fatal().Err(err).Msg("Loading launch providers failed")
+	}
+
 	// Migration code removed - filter modes no longer exist
 
 	// Set size
@@ -130,7 +171,7 @@ func (db *Database) Open(dbFolder string) bool {
 // Update database with updated launch data
 func (db *Database) Update(launches []*Launch, apiUpdate bool, useCacheNotifStates bool) error {
 	// Keep track of update time
-	updated := time.Now()
+	updated := db.Now()
 
 	for _, launch := range launches {
 		// Set time of API update, if this is one
@@ -152,13 +193,14 @@ func (db *Database) Update(launches []*Launch, apiUpdate bool, useCacheNotifStat
 				if err != nil {
 					// If launch does not exist on the disk, initialize the states (probably a new launch)
 					log.Error().Err(err).Msgf("Unable to find launch=%s from disk when searching for notif states", launch.Slug)
-					launch.NotificationState.UpdateMap(launch)
+					launch.NotificationState.Init()
 					continue
 				}
 
 				// If states exist, use the on-disk states
 				launch.NotificationState = dbLaunch.NotificationState
 				launch.SentNotificationIds = dbLaunch.SentNotificationIds
+				launch.FinalNotifiedChats = dbLaunch.FinalNotifiedChats
 
 				log.Debug().Msgf("Successfully utilized on-db launch's notification states on update (id=%s)", launch.Slug)
 			}
@@ -176,6 +218,30 @@ func (db *Database) Update(launches []*Launch, apiUpdate bool, useCacheNotifStat
 		return result.Error
 	}
 
+	// Launcher stages, URLs and launch sites only change with API updates
+	if apiUpdate {
+		if err := db.SaveLaunchers(launches); err != nil {
+			log.Error().Err(err).Msg("Saving launcher stages failed")
+			return err
+		}
+
+		if err := db.SaveContentURLs(launches); err != nil {
+			log.Error().Err(err).Msg("Saving content URLs failed")
+			return err
+		}
+
+		if err := db.SaveLaunchSites(launches); err != nil {
+			log.Error().Err(err).Msg("Saving launch sites failed")
+			return err
+		}
+	}
+
+	// Notification send states change outside API updates, e.g. when a notification is sent
+	if err := db.SaveNotificationStates(launches); err != nil {
+		log.Error().Err(err).Msg("Saving notification send states failed")
+		return err
+	}
+
 	// Store LastUpdated value in the database struct
 	db.LastUpdated = updated
 
@@ -187,15 +253,28 @@ func (db *Database) Update(launches []*Launch, apiUpdate bool, useCacheNotifStat
 
 // Cleans launches from the DB that have slipped away from the request range.
 // This could be the result of the NET moving to the right, or the launch being
-// deleted.
-func (db *Database) CleanSlippedLaunches() error {
+// deleted. Launches with a stored NET at or beyond the horizon were not part of the
+// update, and are left alone. A zero horizon means the update covered all launches.
+// Expired change events are pruned at the same time.
+func (db *Database) CleanSlippedLaunches(horizon int64) error {
 	// Dummy launch from grom
-	nowUnix := time.Now().Unix()
+	nowUnix := db.Now().Unix()
 
 	// Find all launches that have launched=0, and weren't updated in the last update
-	result := db.Conn.Unscoped().Where(
+	query := db.Conn.Unscoped().Where(
 		"launched = ? AND updated_at < ? AND net_unix > ?", 0, db.LastUpdated, nowUnix,
-	).Delete(&Launch{})
+	)
+
+	if horizon != 0 {
+		// Only clean launches that should have been included in the update
+		query = query.Where("net_unix < ?", horizon)
+	}
+
+	result := query.Delete(&Launch{})
+
+	if result.Error != nil {
+		return result.Error
+	}
 
 	if result.RowsAffected == 0 {
 		log.Info().Msg("Database clean: nothing to do")
@@ -203,7 +282,8 @@ func (db *Database) CleanSlippedLaunches() error {
 		log.Info().Msgf("Deleted %d launch(es) that have slipped out of range", result.RowsAffected)
 	}
 
-	return nil
+	// Drop the history of deleted launches, and of launches that launched long ago
+	return db.PruneChangeEvents(db.Now())
 }
 
 // Check if DB needs to be updated immediately
@@ -221,13 +301,15 @@ func (db *Database) RequiresImmediateUpdate(untilNextUpdate time.Duration) (bool
 	db.LastUpdated = dest.ApiUpdate
 
 	// If database is outdated, update now
-	if time.Since(db.LastUpdated) > untilNextUpdate {
+	sinceUpdate := db.Now().Sub(db.LastUpdated)
+
+	if sinceUpdate > untilNextUpdate {
 		log.Info().Msg("Database outdated: updating now...")
-		return true, time.Since(db.LastUpdated)
+		return true, sinceUpdate
 	}
 
-	log.Info().Msgf("%s since last API update, not updating", durafmt.Parse(time.Since(db.LastUpdated)).LimitFirstN(2))
-	return false, time.Since(db.LastUpdated)
+	log.Info().Msgf("%s since last API update, not updating", durafmt.Parse(sinceUpdate).LimitFirstN(2))
+	return false, sinceUpdate
 }
 
 func (db *Database) LoadStatisticsFromDisk(platform string) *stats.Statistics {
@@ -286,9 +368,9 @@ func (db *Database) GetSubscriberCount() int64 {
 	// Select all chats with any notifications enabled, AND at least one notification time enabled
 	var count int64
 	db.Conn.Model(&users.User{}).Where(
-		"(subscribed_all = ? OR subscribed_to != ?) AND NOT "+
-			"(enabled24h = ? AND enabled12h = ? AND enabled1h = ? AND enabled5min = ?)",
-		1, "", 0, 0, 0, 0).Count(&count)
+		"(subscribed_all = ? OR subscribed_to != ? OR subscribed_locations != ? OR subscribed_vehicles != ? OR subscribed_families != ?) AND NOT "+
+			"lead_times = ?",
+		1, "", "", "", "", "").Count(&count)
 	return count
 }
 
@@ -308,9 +390,9 @@ func (db *Database) GetAllActiveSubscribers(platform string, privateOnly bool) [
 	
 	// Build the query
 	query := db.Conn.Model(&users.User{}).Where(
-		"platform = ? AND (subscribed_all = ? OR subscribed_to != ?) AND NOT "+
-			"(enabled24h = ? AND enabled12h = ? AND enabled1h = ? AND enabled5min = ?)",
-		platform, 1, "", 0, 0, 0, 0)
+		"platform = ? AND (subscribed_all = ? OR subscribed_to != ? OR subscribed_locations != ? OR subscribed_vehicles != ? OR subscribed_families != ?) AND NOT "+
+			"lead_times = ?",
+		platform, 1, "", "", "", "", "")
 	
 	// Add private chat filter if requested
 	if privateOnly {
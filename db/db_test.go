@@ -112,3 +112,44 @@ func TestChatMethods(t *testing.T) {
 
 	log.Info().Msg("Done!")
 }
+
+func TestCleanSlippedLaunchesHorizon(t *testing.T) {
+	db := Database{}
+	db.Cache = &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}}
+
+	if !db.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	now := time.Now()
+
+	// Two stale launches: one inside the horizon, one beyond it
+	stale := []*Launch{
+		{Id: "inside", NETUnix: now.Add(24 * time.Hour).Unix()},
+		{Id: "beyond", NETUnix: now.Add(30 * 24 * time.Hour).Unix()},
+	}
+
+	if err := db.Update(stale, true, false); err != nil {
+		t.Fatalf("failed to insert launches: %v", err)
+	}
+
+	// A later update that does not include either launch
+	time.Sleep(10 * time.Millisecond)
+
+	if err := db.Update([]*Launch{{Id: "fresh", NETUnix: now.Add(time.Hour).Unix()}}, true, false); err != nil {
+		t.Fatalf("failed to insert launches: %v", err)
+	}
+
+	horizon := now.Add(7 * 24 * time.Hour).Unix()
+
+	if err := db.CleanSlippedLaunches(horizon); err != nil {
+		t.Fatalf("cleaning launches failed: %v", err)
+	}
+
+	var remaining []string
+	db.Conn.Model(&Launch{}).Order("id").Pluck("id", &remaining)
+
+	if fmt.Sprint(remaining) != "[beyond fresh]" {
+		t.Errorf("expected launches [beyond fresh] to remain, got %v", remaining)
+	}
+}
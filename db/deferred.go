@@ -0,0 +1,68 @@
+package db
+
+import (
+	"launchbot/users"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// A notification held back during a chat's quiet hours, delivered when they end
+type DeferredNotification struct {
+	Id               uint   `gorm:"primaryKey"`
+	ChatId           string `gorm:"index:deferred_chat"`
+	Platform         string `gorm:"index:deferred_chat"`
+	LaunchId         string
+	LaunchName       string
+	LaunchNET        int64     // NET of the launch when the notification was held
+	NotificationType string    // Type of the held notification, e.g. "24h" or "postpone"
+	Text             string    // Message text, before the chat's time is set
+	RefTime          int64     // Reference time of the text's user time, e.g. the launch's NET
+	Keyboard         string    // Inline keyboard of the message as JSON
+	DeliverAt        time.Time `gorm:"index"` // When the chat's quiet hours end
+	CreatedAt        time.Time
+}
+
+// Saves notifications held back during chats' quiet hours
+func (db *Database) DeferNotifications(deferred []*DeferredNotification) error {
+	result := db.Conn.Create(&deferred)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	log.Debug().Msgf("Saved %d held notification(s) to disk", result.RowsAffected)
+	return nil
+}
+
+// Loads the held notifications due for delivery, grouped by chat and oldest first
+func (db *Database) DueDeferredNotifications(now time.Time) ([]*DeferredNotification, error) {
+	deferred := []*DeferredNotification{}
+	result := db.Conn.Where("deliver_at <= ?", now).Order("chat_id, platform, created_at, id").Find(&deferred)
+
+	return deferred, result.Error
+}
+
+// Removes delivered, or otherwise handled, held notifications
+func (db *Database) RemoveDeferredNotifications(deferred []*DeferredNotification) error {
+	if len(deferred) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(deferred))
+
+	for _, notification := range deferred {
+		ids = append(ids, notification.Id)
+	}
+
+	return db.Conn.Delete(&DeferredNotification{}, ids).Error
+}
+
+// Removes all held notifications of a chat, e.g. when the chat is removed
+func (db *Database) removeChatDeferredNotifications(user *users.User) {
+	result := db.Conn.Where("chat_id = ? AND platform = ?", user.Id, user.Platform).Delete(&DeferredNotification{})
+
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msgf("Removing held notifications of chat=%s failed", user.Id)
+	}
+}
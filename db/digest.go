@@ -0,0 +1,90 @@
+package db
+
+import (
+	"fmt"
+	"launchbot/users"
+	"launchbot/utils"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Returns the time zones of the chats that have digests enabled. Chats without a
+// time zone are in the "" (UTC) time zone.
+func (db *Database) DigestTimeZones(platform string) []string {
+	zones := []string{}
+
+	result := db.Conn.Model(&users.User{}).Distinct("locale").
+		Where("platform = ? AND digest_frequency != ?", platform, users.DigestOff).
+		Order("locale").Pluck("locale", &zones)
+
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("Loading digest time zones failed")
+	}
+
+	return zones
+}
+
+// Returns the chats in a time zone that have digests enabled
+func (db *Database) DigestChats(locale string, platform string) []*users.User {
+	chats := []*users.User{}
+
+	result := db.Conn.Where("platform = ? AND locale = ? AND digest_frequency != ?",
+		platform, locale, users.DigestOff).Find(&chats)
+
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msgf("Loading digest chats in time zone=%s failed", locale)
+		return chats
+	}
+
+	// Use the cached chats, so cached state stays consistent
+	for i, chat := range chats {
+		chats[i] = db.Cache.FindUser(chat.Id, chat.Platform)
+	}
+
+	return chats
+}
+
+// Returns the launches in the chat's digest period, from the launches it has subscribed to
+func (cache *Cache) DigestLaunches(user *users.User, now time.Time) []*Launch {
+	until := now.Add(user.DigestPeriod()).Unix()
+	launches := []*Launch{}
+
+	for _, launch := range cache.LaunchesUserHasSubscribedTo(user) {
+		if launch.NETUnix >= now.Unix() && launch.NETUnix <= until {
+			launches = append(launches, launch)
+		}
+	}
+
+	return launches
+}
+
+// Creates a digest message of the launches coming up in the chat's digest period
+func (cache *Cache) DigestMessage(user *users.User, now time.Time, botUsername string) string {
+	if user.Time == (users.UserTime{}) {
+		user.SetTimeZone()
+	}
+
+	period := map[users.DigestFrequency]string{users.DigestDaily: "day", users.DigestWeekly: "week"}[user.DigestFrequency]
+	launches := cache.DigestLaunches(user, now)
+
+	message := fmt.Sprintf("📰 *Your %s launch digest*\n", user.DigestFrequency)
+
+	if len(launches) == 0 {
+		message += fmt.Sprintf("No launches you follow are coming up in the next %s. "+
+			"For all upcoming launches, use /schedule@%s.", period, botUsername)
+
+		return utils.PrepareInputForMarkdown(message, "text")
+	}
+
+	message += fmt.Sprintf("_%d %s you follow %s coming up in the next %s. Dates are relative to %s. ",
+		len(launches), map[bool]string{true: "launch", false: "launches"}[len(launches) == 1],
+		map[bool]string{true: "is", false: "are"}[len(launches) == 1], period, user.Time.UtcOffset) +
+		fmt.Sprintf("For detailed flight information, use /next@%s._\n\n", botUsername)
+
+	// List every launch of the period, one date per day
+	message += scheduleRows(user, scheduleByDate(user, launches, 8, now), true, now)
+	message += "🟢🟡🔴 *Launch-time accuracy*"
+
+	return utils.PrepareInputForMarkdown(message, "italictext")
+}
@@ -0,0 +1,221 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// A kind of change detected in a launch between two API updates
+type ChangeType string
+
+const (
+	StatusChange      ChangeType = "status"       // Status changed, e.g. Go -> Hold
+	NetChange         ChangeType = "net"          // NET moved
+	WindowChange      ChangeType = "window"       // Launch window start or end moved
+	PadChange         ChangeType = "pad"          // Launch pad changed
+	ProbabilityChange ChangeType = "probability"  // Probability of launch changed
+	WebcastAdded      ChangeType = "webcast"      // A webcast link became available
+	WebcastLive       ChangeType = "webcast_live" // The webcast went live
+	MissionNameChange ChangeType = "mission"      // Mission was renamed
+)
+
+// Change events of launched launches are kept for this long after the launch
+const changeEventRetention = 30 * 24 * time.Hour
+
+// A change detected in a launch, persisted in the database
+type ChangeEvent struct {
+	Id         uint       `gorm:"primaryKey"`
+	LaunchId   string     `gorm:"index"`
+	Type       ChangeType `gorm:"index"`
+	Old        string     // Value before the change
+	New        string     // Value after the change
+	DetectedAt time.Time  // Time of the update the change was detected in
+	Launch     *Launch    `gorm:"-:all"` // The fresh launch, available when the event was just detected
+}
+
+func (event *ChangeEvent) String() string {
+	return fmt.Sprintf("%s: %s -> %s (launch=%s)", event.Type, event.Old, event.New, event.LaunchId)
+}
+
+/*
+Compares a launch against its previous version, returning an event for each
+detected change. Values that are empty in the fresh launch are not considered
+changes, as they are typically missing data, instead of a real change.
+*/
+func DiffLaunches(old *Launch, fresh *Launch, now time.Time) []*ChangeEvent {
+	events := []*ChangeEvent{}
+
+	// Adds an event if the values differ
+	compare := func(changeType ChangeType, oldValue string, newValue string) {
+		if oldValue == newValue || newValue == "" {
+			return
+		}
+
+		events = append(events, &ChangeEvent{
+			LaunchId: fresh.Id, Type: changeType, Old: oldValue, New: newValue,
+			DetectedAt: now, Launch: fresh,
+		})
+	}
+
+	compare(StatusChange, old.Status.Abbrev, fresh.Status.Abbrev)
+	compare(NetChange, old.NET, fresh.NET)
+	compare(PadChange, old.LaunchPad.Name, fresh.LaunchPad.Name)
+	compare(MissionNameChange, old.Mission.Name, fresh.Mission.Name)
+
+	// Both ends of the window are tracked as a single change
+	if fresh.WindowStart != "" && (old.WindowStart != fresh.WindowStart || old.WindowEnd != fresh.WindowEnd) {
+		compare(WindowChange,
+			fmt.Sprintf("%s/%s", old.WindowStart, old.WindowEnd),
+			fmt.Sprintf("%s/%s", fresh.WindowStart, fresh.WindowEnd))
+	}
+
+	if old.Probability != fresh.Probability {
+		compare(ProbabilityChange, fmt.Sprint(old.Probability), fmt.Sprint(fresh.Probability))
+	}
+
+	// Only the first webcast link is interesting: later changes are usually re-prioritizations
+	if old.WebcastLink == "" {
+		compare(WebcastAdded, "", fresh.WebcastLink)
+	}
+
+	// Only a webcast going live is interesting, not it going offline
+	if fresh.WebcastIsLive {
+		compare(WebcastLive, fmt.Sprint(old.WebcastIsLive), fmt.Sprint(fresh.WebcastIsLive))
+	}
+
+	return events
+}
+
+// Returns true if the event is a launch going on hold
+func (event *ChangeEvent) IsHold() bool {
+	return event.Type == StatusChange && event.New == "Hold"
+}
+
+// Returns true if the event is a scrub, i.e. a launch that was go or holding
+// falling back to an unconfirmed launch time
+func (event *ChangeEvent) IsScrub() bool {
+	return event.Type == StatusChange && (event.Old == "Go" || event.Old == "Hold") &&
+		(event.New == "TBD" || event.New == "TBC")
+}
+
+// Returns the events of an update, filtered by type. No types returns all events.
+func (update *LaunchUpdate) EventsOfType(types ...ChangeType) []*ChangeEvent {
+	if len(types) == 0 {
+		return update.Events
+	}
+
+	events := []*ChangeEvent{}
+
+	for _, event := range update.Events {
+		for _, changeType := range types {
+			if event.Type == changeType {
+				events = append(events, event)
+				break
+			}
+		}
+	}
+
+	return events
+}
+
+// Returns the launches whose webcast went live in the update, and that have not been notified
+// of it yet, so a webcast going offline and back online is not notified again. The notification
+// states are only available once the update has been applied to the cache.
+func (update *LaunchUpdate) LiveWebcasts() []*Launch {
+	launches := []*Launch{}
+
+	for _, event := range update.EventsOfType(WebcastLive) {
+		if event.Launch.Launched || event.Launch.NotificationState.SentWebcast {
+			continue
+		}
+
+		launches = append(launches, event.Launch)
+	}
+
+	return launches
+}
+
+// Saves change events into the database
+func (db *Database) SaveChangeEvents(events []*ChangeEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	result := db.Conn.Create(&events)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	log.Debug().Msgf("Saved %d change event(s) to disk", result.RowsAffected)
+	return nil
+}
+
+// Loads the change events of a launch, oldest first
+func (db *Database) ChangeEvents(launchId string) ([]*ChangeEvent, error) {
+	events := []*ChangeEvent{}
+	result := db.Conn.Where("launch_id = ?", launchId).Order("detected_at, id").Find(&events)
+
+	return events, result.Error
+}
+
+// Loads all change events detected after a point in time, oldest first
+func (db *Database) ChangeEventsSince(since time.Time) ([]*ChangeEvent, error) {
+	events := []*ChangeEvent{}
+	result := db.Conn.Where("detected_at > ?", since).Order("detected_at, id").Find(&events)
+
+	return events, result.Error
+}
+
+// Counts the NET changes detected in each of the launches, mapped by launch id
+func (db *Database) NetChangeCounts(launches []*Launch) map[string]int {
+	ids := make([]string, 0, len(launches))
+
+	for _, launch := range launches {
+		ids = append(ids, launch.Id)
+	}
+
+	rows := []struct {
+		LaunchId string
+		Count    int
+	}{}
+
+	result := db.Conn.Model(&ChangeEvent{}).Select("launch_id, COUNT(*) AS count").
+		Where("type = ? AND launch_id IN ?", NetChange, ids).Group("launch_id").Scan(&rows)
+
+	counts := make(map[string]int, len(rows))
+
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("Counting NET changes failed")
+		return counts
+	}
+
+	for _, row := range rows {
+		counts[row.LaunchId] = row.Count
+	}
+
+	return counts
+}
+
+// Deletes the change events of launches that launched before the retention period,
+// and of launches that no longer exist, e.g. because they slipped out of range
+func (db *Database) PruneChangeEvents(now time.Time) error {
+	cutoff := now.Add(-changeEventRetention).Unix()
+
+	launched := db.Conn.Model(&Launch{}).Select("id").Where("launched = ? AND net_unix < ?", 1, cutoff)
+	existing := db.Conn.Model(&Launch{}).Select("id")
+
+	result := db.Conn.Where("launch_id IN (?) OR launch_id NOT IN (?)", launched, existing).Delete(&ChangeEvent{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected != 0 {
+		log.Info().Msgf("Deleted %d expired change event(s)", result.RowsAffected)
+	}
+
+	return nil
+}
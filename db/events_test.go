@@ -0,0 +1,139 @@
+package db
+
+import (
+	"fmt"
+	"launchbot/users"
+	"testing"
+	"time"
+)
+
+// Tests that each kind of change is detected, and that events survive a round-trip to disk
+func TestChangeEvents(t *testing.T) {
+	now := time.Now()
+
+	old := &Launch{
+		Id: "diff", Status: LaunchStatus{Abbrev: "Go"}, NET: "2022-10-05T12:00:00Z",
+		WindowStart: "2022-10-05T12:00:00Z", WindowEnd: "2022-10-05T14:00:00Z",
+		Probability: 80, LaunchPad: LaunchPad{Name: "SLC-40"}, Mission: Mission{Name: "Starlink"},
+	}
+
+	// An identical launch produces no events
+	same := *old
+	if events := DiffLaunches(old, &same, now); len(events) != 0 {
+		t.Errorf("expected no events for an unchanged launch, got %v", events)
+	}
+
+	fresh := *old
+	fresh.Status.Abbrev = "Hold"
+	fresh.WindowEnd = "2022-10-05T13:00:00Z"
+	fresh.Probability = 60
+	fresh.LaunchPad.Name = "LC-39A"
+	fresh.Mission.Name = "Starlink Group 4-1"
+	fresh.WebcastLink = "https://example.com/webcast"
+	fresh.WebcastIsLive = true
+
+	events := DiffLaunches(old, &fresh, now)
+
+	expected := map[ChangeType]string{
+		StatusChange: "Hold", WindowChange: "2022-10-05T12:00:00Z/2022-10-05T13:00:00Z",
+		ProbabilityChange: "60", PadChange: "LC-39A", MissionNameChange: "Starlink Group 4-1",
+		WebcastAdded: "https://example.com/webcast", WebcastLive: "true",
+	}
+
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %v", len(expected), len(events), events)
+	}
+
+	for _, event := range events {
+		if expected[event.Type] != event.New || event.Launch != &fresh {
+			t.Errorf("unexpected event %s", event)
+		}
+	}
+
+	// Missing data is not a change
+	missing := *old
+	missing.Status.Abbrev = ""
+	missing.WindowStart, missing.WindowEnd = "", ""
+
+	if events := DiffLaunches(old, &missing, now); len(events) != 0 {
+		t.Errorf("expected no events for missing data, got %v", events)
+	}
+
+	// Events are persisted, and can be loaded by launch
+	db := Database{}
+	db.Cache = &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}}
+
+	if !db.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	if err := db.SaveChangeEvents(events); err != nil {
+		t.Fatalf("saving events failed: %v", err)
+	}
+
+	loaded, err := db.ChangeEvents("diff")
+
+	if err != nil || len(loaded) != len(events) {
+		t.Fatalf("expected %d events from disk, got %d (err=%v)", len(events), len(loaded), err)
+	}
+
+	update := LaunchUpdate{Events: events}
+
+	if holds := update.EventsOfType(StatusChange, PadChange); len(holds) != 2 {
+		t.Errorf("expected 2 events when filtering by type, got %d", len(holds))
+	}
+
+	// Webcasts are notified of once
+	if webcasts := update.LiveWebcasts(); len(webcasts) != 1 || webcasts[0] != &fresh {
+		t.Errorf("expected the launch's webcast to be live, got %d launch(es)", len(webcasts))
+	}
+
+	fresh.NotificationState.SentWebcast = true
+
+	if webcasts := update.LiveWebcasts(); len(webcasts) != 0 {
+		t.Errorf("expected no live webcasts after notifying, got %d launch(es)", len(webcasts))
+	}
+}
+
+// Tests that the history of long-launched and deleted launches is pruned
+func TestPruneChangeEvents(t *testing.T) {
+	db := Database{}
+	db.Cache = &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}}
+
+	if !db.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	now := time.Now()
+
+	launches := []*Launch{
+		{Id: "expired", Launched: true, NETUnix: now.Add(-40 * 24 * time.Hour).Unix()},
+		{Id: "recent", Launched: true, NETUnix: now.Add(-5 * 24 * time.Hour).Unix()},
+		{Id: "upcoming", NETUnix: now.Add(24 * time.Hour).Unix()},
+	}
+
+	if err := db.Update(launches, true, false); err != nil {
+		t.Fatalf("failed to insert launches: %v", err)
+	}
+
+	events := []*ChangeEvent{}
+
+	for _, id := range []string{"expired", "recent", "upcoming", "deleted"} {
+		events = append(events, &ChangeEvent{LaunchId: id, Type: NetChange, DetectedAt: now})
+	}
+
+	if err := db.SaveChangeEvents(events); err != nil {
+		t.Fatalf("saving events failed: %v", err)
+	}
+
+	if err := db.PruneChangeEvents(now); err != nil {
+		t.Fatalf("pruning events failed: %v", err)
+	}
+
+	var remaining []string
+	db.Conn.Model(&ChangeEvent{}).Order("launch_id").Pluck("launch_id", &remaining)
+
+	if fmt.Sprint(remaining) != "[recent upcoming]" {
+		t.Errorf("expected events of [recent upcoming] to remain, got %v", remaining)
+	}
+}
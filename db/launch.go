@@ -6,6 +6,7 @@ import (
 	"launchbot/users"
 	"launchbot/utils"
 	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -22,7 +23,12 @@ import (
 
 type LaunchUpdate struct {
 	Launches  []*Launch            `json:"results"`
+	Next      string               `json:"next"` // URL of the next page of results, if any
+	Horizon   int64                // Unix-time the update covers launches up to (0 = no limit)
+	Requests  int                  // Count of API requests used for this update
 	Postponed map[*Launch]Postpone // Map of postponed launches
+	Outcomes  []*Launch            // Launches that reached an outcome since the last update
+	Events    []*ChangeEvent       // Changes detected in launches since the last update
 	Mutex     sync.Mutex           // A mutex for concurrently parsing launches
 }
 
@@ -51,15 +57,18 @@ type Launch struct {
 	WebcastLink string    // Highest-priority link from VidURLs
 	ApiUpdate   time.Time // Time of last API update
 
-	// Status of notification sends (boolean + non-embedded map)
+	// Status of notification sends (lead time states are stored in the notification_sends-table)
 	NotificationState NotificationState `gorm:"embedded"`
 
 	// Track IDs of previously sent notifications (comma-separated string of message IDs)
 	SentNotificationIds string
 
-	// Information not dumped into the database (-> manual parse on a cache init from db)
-	InfoURL []ContentURL `json:"infoURLs" gorm:"-:all"` // Not embedded into db: parsed manually
-	VidURL  []ContentURL `json:"vidURLs" gorm:"-:all"`  // Not embedded into db: parsed manually
+	// Track chats that received a final notification (comma-separated string of chat IDs)
+	FinalNotifiedChats string
+
+	// Information stored in the content_urls-table (-> loaded manually on a cache init from db)
+	InfoURL []ContentURL `json:"infoURLs" gorm:"-:all"`
+	VidURL  []ContentURL `json:"vidURLs" gorm:"-:all"`
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -68,14 +77,11 @@ type Launch struct {
 
 // Maps the send times to send states.
 type NotificationState struct {
-	Sent24h  bool
-	Sent12h  bool
-	Sent1h   bool
-	Sent5min bool
+	// Webcast-live notification is sent once per launch, and is never reset
+	SentWebcast bool
 
-	// Maps send-times to boolean states.
-	// Keys are equal to the NotificationState boolean fields.
-	Map map[string]bool `gorm:"-:all"`
+	// Maps lead times (minutes before NET) to send states. Nil until loaded.
+	Sent map[int]bool `gorm:"-:all"`
 }
 
 type LaunchStatus struct {
@@ -100,24 +106,20 @@ type Rocket struct {
 	Config RocketConfiguration `json:"configuration" gorm:"embedded;embeddedPrefix:config_"`
 
 	// Unparsed and parsed launcher info: JSON is unpacked into the "unparsed" field,
-	// while the info embedded in the DB gets stored in the "Launchers" field
-	UnparsedLauncherInfo []FirstStage `json:"launcher_stage" gorm:"-:all"`       // JSON, not in DB
-	Launchers            Launchers    `gorm:"embedded;embeddedPrefix:launcher_"` // Gorm, in DB
+	// while the parsed stages are stored in their own table, keyed by launch ID
+	UnparsedLauncherInfo []FirstStage `json:"launcher_stage" gorm:"-:all"` // JSON, not in DB
+	Stages               []Launcher   `json:"-" gorm:"-:all"`              // Launchers-table, loaded manually
 
 	SpacecraftStage SpacecraftStage `json:"spacecraft_stage" gorm:"embedded;embeddedPrefix:spacecraft_"`
 }
 
-// Contains multiple launchers packed into one (e.g. Falcon 9 vs. Falcon Heavy).
-// A launcher is e.g. a Falcon 9 first stage.
-type Launchers struct {
-	Count    int
-	Core     Launcher `gorm:"embedded;embeddedPrefix:core_"`
-	Booster1 Launcher `gorm:"embedded;embeddedPrefix:booster1_"`
-	Booster2 Launcher `gorm:"embedded;embeddedPrefix:booster2_"`
-}
-
-// A parsed launcher info struct
+// A parsed launcher info struct, e.g. a Falcon 9 first stage or a side booster.
+// A launch vehicle may have any number of these, stored in their own table.
 type Launcher struct {
+	Id              uint   `gorm:"primaryKey"`
+	LaunchId        string `gorm:"index"`
+	Position        int    // Order of the stage in the launch vehicle's launcher info
+	Role            string // Role of the stage, e.g. "Core" or "Booster"
 	Serial          string
 	Reused          bool
 	FlightNumber    int
@@ -134,6 +136,7 @@ type RocketConfiguration struct {
 	Id       int    `json:"id"`
 	Name     string `json:"name"`
 	FullName string `json:"full_name"`
+	Family   string `json:"family"`
 	Variant  string `json:"variant"`
 
 	TotalLaunchCount int `json:"total_launch_count"`
@@ -181,12 +184,51 @@ type LandingType struct {
 	Description string `json:"description"`
 }
 
-// TODO add crew for launch/onboard/landing (additional parsing required)
 type SpacecraftStage struct {
 	Id          int        `json:"id"`
 	Destination string     `json:"destination"`
 	Spacecraft  Spacecraft `json:"spacecraft" gorm:"embedded;embeddedPrefix:spacecraft_"`
 	Landing     Landing    `json:"landing" gorm:"embedded;embeddedPrefix:landing_"`
+
+	// Unparsed crew assignments from the API: parsed into the "Crew" field
+	LaunchCrew  []CrewAssignment `json:"launch_crew" gorm:"-:all"`  // JSON, not in DB
+	OnboardCrew []CrewAssignment `json:"onboard_crew" gorm:"-:all"` // JSON, not in DB
+	LandingCrew []CrewAssignment `json:"landing_crew" gorm:"-:all"` // JSON, not in DB
+	Crew        []CrewMember     `json:"-" gorm:"serializer:json"`  // Gorm, in DB
+}
+
+// Unparsed crew assignment straight from the API
+type CrewAssignment struct {
+	Role      CrewRole  `json:"role"`
+	Astronaut Astronaut `json:"astronaut"`
+}
+
+type CrewRole struct {
+	Role     string `json:"role"`     // e.g. "Commander"
+	Priority int    `json:"priority"` // Lower is more senior
+}
+
+type Astronaut struct {
+	Id           int    `json:"id"`
+	Name         string `json:"name"`
+	Nationality  string `json:"nationality"` // e.g. "American"
+	FlightsCount int    `json:"flights_count"`
+	Agency       Agency `json:"agency"`
+}
+
+type Agency struct {
+	Name   string `json:"name"`
+	Abbrev string `json:"abbrev"`
+}
+
+// A parsed crew member, flying on the spacecraft
+type CrewMember struct {
+	Name        string
+	Role        string
+	Agency      string
+	Nationality string
+	Flights     int
+	Stage       string // Crew the member is part of: "launch", "onboard", or "landing"
 }
 
 type Spacecraft struct {
@@ -226,16 +268,33 @@ type LaunchPad struct {
 }
 
 type PadLocation struct {
+	Id               int    `json:"id"`
 	Name             string `json:"name"`
 	CountryCode      string `json:"country_code"`
 	TotalLaunchCount int    `json:"total_launch_count"`
 }
 
 type ContentURL struct {
-	Priority    int    `json:"priority"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Url         string `json:"url"`
+	Id          uint            `json:"-" gorm:"primaryKey"`
+	LaunchId    string          `json:"-" gorm:"index"`
+	Kind        string          `json:"-"` // "info" or "video"
+	Priority    int             `json:"priority"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Url         string          `json:"url"`
+	Publisher   string          `json:"publisher"` // e.g. "SpaceX" or "Everyday Astronaut"
+	Language    ContentLanguage `json:"language" gorm:"embedded;embeddedPrefix:language_"`
+	Type        ContentType     `json:"type" gorm:"embedded;embeddedPrefix:type_"`
+}
+
+type ContentLanguage struct {
+	Name string `json:"name"` // e.g. "English"
+	Code string `json:"code"` // e.g. "en"
+}
+
+type ContentType struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"` // e.g. "Official Webcast"
 }
 
 // Map isReused to an emoji indicating re-use status
@@ -266,7 +325,7 @@ func (launch *Launch) BoosterInformation() string {
 	)
 
 	var boosterNamePrefix string
-	core := &launch.Rocket.Launchers.Core
+	core := launch.Rocket.CoreStage()
 
 	if launch.LaunchProvider.Name == "SpaceX" && !strings.Contains(core.Serial, "Unknown F9") {
 		// For SpaceX launches, add a booster prefix (e.g. B1060.1)
@@ -330,7 +389,7 @@ func (launch *Launch) BoosterInformation() string {
 	)
 
 	// Add booster information if there are any, using sideBoosterInformation()
-	if launch.Rocket.Launchers.Booster1 != (Launcher{}) && launch.Rocket.Launchers.Booster2 != (Launcher{}) {
+	if len(launch.Rocket.Boosters()) != 0 {
 		boosterText += "*Boosters* " + utils.Monospaced(launch.sideBoosterInformation()) + "\n"
 	}
 
@@ -380,7 +439,7 @@ func (launch *Launch) BoosterInformation() string {
 			utils.Monospaced(launch.Rocket.SpacecraftStage.Spacecraft.Serial),
 			utils.Monospaced(starshipReuseString),
 
-			utils.Monospaced(strings.Replace(launch.Rocket.Launchers.Core.Serial, "Booster ", "B", 1)),
+			utils.Monospaced(strings.Replace(core.Serial, "Booster ", "B", 1)),
 			utils.Monospaced(superHeavyFlightCountString),
 			utils.Monospaced(superHeavyReuseString),
 		)
@@ -427,20 +486,112 @@ func (booster *Launcher) boosterRecoveryString() string {
 
 // Returns a single-liner of side-booster information for a launch
 func (launch *Launch) sideBoosterInformation() string {
-	// *Boosters* B1010 (reuseStr), B1020 (reuseStr)
-	var boosterText1, boosterText2 string
+	// *Boosters* B1010 (reuseStr), B1020 (reuseStr), ...
+	boosterTexts := []string{}
+
+	for _, booster := range launch.Rocket.Boosters() {
+		boosterTexts = append(boosterTexts, booster.boosterNameString(launch.LaunchProvider.Name))
+	}
+
+	return strings.Join(boosterTexts, ", ")
+}
 
-	if launch.Rocket.Launchers.Booster1 != (Launcher{}) {
-		boosterText1 = launch.Rocket.Launchers.Booster1.boosterNameString(launch.LaunchProvider.Name)
+// Returns true if the stage is the core stage of the launch vehicle
+func (booster *Launcher) IsCore() bool {
+	return strings.EqualFold(booster.Role, "core")
+}
+
+// Returns the core stage of the launch vehicle, or an empty launcher if there is none
+func (rocket *Rocket) CoreStage() *Launcher {
+	for i := range rocket.Stages {
+		if rocket.Stages[i].IsCore() {
+			return &rocket.Stages[i]
+		}
 	}
 
-	if launch.Rocket.Launchers.Booster2 != (Launcher{}) {
-		boosterText2 = launch.Rocket.Launchers.Booster2.boosterNameString(launch.LaunchProvider.Name)
+	return &Launcher{}
+}
+
+// Returns all stages of the launch vehicle that are not the core, e.g. side boosters
+func (rocket *Rocket) Boosters() []*Launcher {
+	boosters := []*Launcher{}
+
+	for i := range rocket.Stages {
+		if !rocket.Stages[i].IsCore() {
+			boosters = append(boosters, &rocket.Stages[i])
+		}
 	}
 
-	return fmt.Sprintf(
-		"%s, %s", boosterText1, boosterText2,
-	)
+	return boosters
+}
+
+// Returns true if the launch carries a crew to space
+func (launch *Launch) IsCrewed() bool {
+	for _, member := range launch.Rocket.SpacecraftStage.Crew {
+		if member.Stage == "launch" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns a single-liner for a crew member, e.g. "Commander `Reid Wiseman (NASA, American, 3 flights)`"
+func (member *CrewMember) crewMemberString() string {
+	details := []string{}
+
+	for _, detail := range []string{member.Agency, member.Nationality} {
+		if detail != "" {
+			details = append(details, detail)
+		}
+	}
+
+	if member.Flights == 1 {
+		details = append(details, "1 flight")
+	} else if member.Flights > 1 {
+		details = append(details, fmt.Sprintf("%d flights", member.Flights))
+	}
+
+	name := member.Name
+	if len(details) != 0 {
+		name += fmt.Sprintf(" (%s)", strings.Join(details, ", "))
+	}
+
+	role := member.Role
+	if role == "" {
+		role = "Crew"
+	}
+
+	return fmt.Sprintf("*%s* %s\n", role, utils.Monospaced(name))
+}
+
+// Returns the crew information for the expanded view, grouped by crew
+func (launch *Launch) CrewInformation() string {
+	headers := map[string]string{
+		"launch": "👩‍🚀 *Crew*\n", "onboard": "🛰️ *Onboard crew*\n", "landing": "🪂 *Landing crew*\n",
+	}
+
+	var crewText, currentStage string
+
+	// Members are ordered by crew, so a header is added whenever the crew changes
+	for _, member := range launch.Rocket.SpacecraftStage.Crew {
+		if member.Stage != currentStage {
+			if currentStage != "" {
+				crewText += "\n"
+			}
+
+			crewText += headers[member.Stage]
+			currentStage = member.Stage
+		}
+
+		crewText += member.crewMemberString()
+	}
+
+	if crewText != "" {
+		crewText += "\n"
+	}
+
+	return crewText
 }
 
 // Returns a launch information string
@@ -456,8 +607,8 @@ func (launch *Launch) DescriptionText() string {
 	return description
 }
 
-// Generates the message content used by both notifications and /next
-func (launch *Launch) MessageBodyText(expanded bool, isNotification bool) string {
+// Generates the message content used by both notifications and /next, at the time now
+func (launch *Launch) MessageBodyText(expanded bool, isNotification bool, now time.Time) string {
 	var (
 		flag              string
 		location          string
@@ -483,7 +634,7 @@ func (launch *Launch) MessageBodyText(expanded bool, isNotification bool) string
 	}
 
 	var timeUntil string
-	untilLaunch := time.Until(time.Unix(launch.NETUnix, 0))
+	untilLaunch := time.Unix(launch.NETUnix, 0).Sub(now)
 
 	if !isNotification {
 		// If not a notification, add the "Launch time" section with date and time
@@ -531,13 +682,27 @@ func (launch *Launch) MessageBodyText(expanded bool, isNotification bool) string
 		missionOrbit = "Unknown orbit"
 	}
 
+	// Crewed flights show the size of the crew, or the full crew when expanded
+	var crewLine string
+
 	if expanded {
 		// Add re-use information, if it exists
-		if launch.Rocket.Launchers.Count != 0 {
+		if len(launch.Rocket.Stages) != 0 {
 			description = launch.BoosterInformation()
 		}
 
+		description += launch.CrewInformation()
 		description += launch.DescriptionText()
+	} else if launch.IsCrewed() {
+		crewCount := 0
+
+		for _, member := range launch.Rocket.SpacecraftStage.Crew {
+			if member.Stage == "launch" {
+				crewCount++
+			}
+		}
+
+		crewLine = fmt.Sprintf("*Crew* %s\n", utils.Monospaced(fmt.Sprintf("%d astronaut(s)", crewCount)))
 	}
 
 	text := fmt.Sprintf(
@@ -549,7 +714,8 @@ func (launch *Launch) MessageBodyText(expanded bool, isNotification bool) string
 
 			"🌍 *Mission information*\n"+
 			"*Type* %s\n"+
-			"*Orbit* %s\n\n"+
+			"*Orbit* %s\n"+
+			"%s\n"+
 
 			"%s",
 
@@ -559,6 +725,7 @@ func (launch *Launch) MessageBodyText(expanded bool, isNotification bool) string
 		launchTimeSection,
 
 		utils.Monospaced(missionType), utils.Monospaced(missionOrbit),
+		crewLine,
 
 		description,
 	)
@@ -566,22 +733,22 @@ func (launch *Launch) MessageBodyText(expanded bool, isNotification bool) string
 	return text
 }
 
-// Produces a launch notification message
-func (launch *Launch) NotificationMessage(notifType string, expanded bool, botUsername string) string {
+// Produces a launch notification message, at the time now
+func (launch *Launch) NotificationMessage(notifType string, expanded bool, botUsername string, now time.Time) string {
 	// Map notification type to a header
-	header, ok := map[string]string{
-		"24h": "T-24 hours", "12h": "T-12 hours",
-		"1h": "T-60 minutes", "5min": "T-5 minutes",
-	}[notifType]
+	var header string
+	leadTime, ok := users.LeadTimeOfType(notifType)
 
-	if !ok {
+	if ok {
+		header = "T-" + users.LeadTimeDescription(leadTime)
+	} else {
 		log.Warn().Msgf("%s not found when mapping notif.Type to header in NotificationMessage (%s)",
 			notifType, launch.Slug)
 	}
 
-	// If notification type is 5-minute, use real launch time for clarity
-	if notifType == "5min" && !expanded {
-		untilNet := time.Until(time.Unix(launch.NETUnix, 0))
+	// If this is a final notification, use real launch time for clarity
+	if ok && leadTime <= users.FinalLeadTime && !expanded {
+		untilNet := time.Unix(launch.NETUnix, 0).Sub(now)
 
 		// If we're seconds away, use seconds
 		if untilNet.Minutes() < 1.0 {
@@ -605,9 +772,9 @@ func (launch *Launch) NotificationMessage(notifType string, expanded bool, botUs
 	// Load a name for the launch
 	name := launch.HeaderName()
 
-	// Only add the webcast link for 1-hour and 5-minute notifications
+	// Only add the webcast link for notifications sent an hour or less before launch
 	var webcastLink string
-	if notifType == "1h" || notifType == "5min" {
+	if ok && leadTime <= 60 {
 		if launch.WebcastLink != "" {
 
 			// Prepare link for markdown
@@ -624,8 +791,13 @@ func (launch *Launch) NotificationMessage(notifType string, expanded bool, botUs
 		webcastLink = ""
 	}
 
+	// The expanded view lists every available stream
+	if expanded && len(launch.VidURL) != 0 {
+		webcastLink = launch.StreamList()
+	}
+
 	// Load message body
-	messageBody := launch.MessageBodyText(expanded, true)
+	messageBody := launch.MessageBodyText(expanded, true, now)
 
 	text := fmt.Sprintf(
 		"🚀 *%s*: *%s*\n"+
@@ -653,6 +825,68 @@ func (launch *Launch) NotificationMessage(notifType string, expanded bool, botUs
 	return text
 }
 
+// Returns a list of all the launch's streams ordered by priority, with their titles and
+// languages. The list is already prepared for Telegram's MarkdownV2 parser.
+func (launch *Launch) StreamList() string {
+	streams := make([]ContentURL, len(launch.VidURL))
+	copy(streams, launch.VidURL)
+
+	sort.SliceStable(streams, func(i, j int) bool {
+		return streams[i].Priority < streams[j].Priority
+	})
+
+	streamText := "📺 *Live streams*\n"
+
+	for _, stream := range streams {
+		title := stream.Title
+
+		if strings.TrimSpace(title) == "" {
+			title = "Watch launch live!"
+		}
+
+		// E.g. "🔴 SpaceX Webcast (English)"
+		line := fmt.Sprintf("🔴 [%s](%s)",
+			utils.PrepareInputForMarkdown(title, "text"), utils.PrepareInputForMarkdown(stream.Url, "link"))
+
+		if stream.Language.Name != "" {
+			line += utils.PrepareInputForMarkdown(fmt.Sprintf(" (%s)", stream.Language.Name), "text")
+		}
+
+		streamText += line + "\n"
+	}
+
+	return streamText
+}
+
+// Returns the launch's streams, so recipients can be sent their preferred one
+func (launch *Launch) Webcasts() []sendables.Webcast {
+	webcasts := make([]sendables.Webcast, 0, len(launch.VidURL))
+
+	for _, stream := range launch.VidURL {
+		webcasts = append(webcasts, sendables.Webcast{
+			Url: stream.Url, Language: stream.Language.Code, Priority: stream.Priority,
+			Official: stream.isOfficial(&launch.LaunchProvider),
+		})
+	}
+
+	return webcasts
+}
+
+// Returns true if the stream is the launch provider's own webcast, instead of a third-party stream.
+// The stream's type is used if it is known, and otherwise its publisher.
+func (stream *ContentURL) isOfficial(provider *LaunchProvider) bool {
+	streamType := strings.ToLower(stream.Type.Name)
+
+	if strings.Contains(streamType, "unofficial") {
+		return false
+	} else if strings.Contains(streamType, "official") {
+		return true
+	}
+
+	return stream.Publisher != "" &&
+		(strings.EqualFold(stream.Publisher, provider.Name) || strings.EqualFold(stream.Publisher, provider.Abbrev))
+}
+
 func (launch *Launch) TelegramNotificationKeyboard(notificationType string) [][]tb.InlineButton {
 	// Construct the keeb
 	kb := [][]tb.InlineButton{}
@@ -666,7 +900,8 @@ func (launch *Launch) TelegramNotificationKeyboard(notificationType string) [][]
 
 	kb = append(kb, []tb.InlineButton{muteBtn})
 
-	if launch.Mission.Description != "" {
+	// Expanded view shows the full description, and every available stream
+	if launch.Mission.Description != "" || len(launch.VidURL) > 1 {
 		expandBtn := tb.InlineButton{
 			Unique: "expand",
 			Text:   "ℹ️ Expand description",
@@ -681,6 +916,27 @@ func (launch *Launch) TelegramNotificationKeyboard(notificationType string) [][]
 
 // Creates a schedule message from the launch cache
 func (cache *Cache) ScheduleMessage(user *users.User, showMissions bool, botUsername string) string {
+	// List of launch-lists, one list per launch date
+	now := cache.Now()
+	schedule := scheduleByDate(user, cache.Launches, 5, now)
+
+	// User message
+	message := "📅 *5-day flight schedule*\n" +
+		fmt.Sprintf("_Dates are relative to %s. ", user.Time.UtcOffset) +
+		fmt.Sprintf("For detailed flight information, use /next@%s._\n\n", botUsername)
+
+	// Add the dates and their launches
+	message += scheduleRows(user, schedule, showMissions, now)
+
+	// Add the footer
+	message += "🟢🟡🔴 *Launch-time accuracy*"
+
+	// Escape the message, return it
+	return utils.PrepareInputForMarkdown(message, "italictext")
+}
+
+// Groups launches by their launch date in the user's time zone, up to maxDates dates
+func scheduleByDate(user *users.User, launches []*Launch, maxDates int, now time.Time) [][]*Launch {
 	// List of launch-lists, one list per launch date
 	schedule := [][]*Launch{}
 
@@ -689,9 +945,9 @@ func (cache *Cache) ScheduleMessage(user *users.User, showMissions bool, botUser
 	dateToIndex := make(map[string]int)
 
 	// Loop over all launches and build a launchDate:listOfLaunches map
-	for _, launch := range cache.Launches {
+	for _, launch := range launches {
 		// Ignore bad launches (only really with LL2's development endpoint)
-		delta := time.Until(time.Unix(launch.NETUnix, 0))
+		delta := time.Unix(launch.NETUnix, 0).Sub(now)
 
 		if delta.Seconds() < 0 && launch.Status.Abbrev != "In Flight" {
 			continue
@@ -714,8 +970,8 @@ func (cache *Cache) ScheduleMessage(user *users.User, showMissions bool, botUser
 			// If this date has already been added, use the existing index
 			schedule[idx] = append(schedule[idx], launch)
 		} else {
-			// Date does not exist: add, unless we already have five dates, in which case break the loop
-			if len(schedule) == 5 {
+			// Date does not exist: add, unless we already have enough dates, in which case break the loop
+			if len(schedule) == maxDates {
 				break
 			}
 
@@ -727,10 +983,12 @@ func (cache *Cache) ScheduleMessage(user *users.User, showMissions bool, botUser
 		}
 	}
 
-	// User message
-	message := "📅 *5-day flight schedule*\n" +
-		fmt.Sprintf("_Dates are relative to %s. ", user.Time.UtcOffset) +
-		fmt.Sprintf("For detailed flight information, use /next@%s._\n\n", botUsername)
+	return schedule
+}
+
+// Formats launches grouped by date into schedule rows, with a header for each date
+func scheduleRows(user *users.User, schedule [][]*Launch, showMissions bool, now time.Time) string {
+	message := ""
 
 	// Loop over the created map and create the message
 	for listIterCount, launchList := range schedule {
@@ -738,7 +996,7 @@ func (cache *Cache) ScheduleMessage(user *users.User, showMissions bool, botUser
 		userLaunchTime := time.Unix(launchList[0].NETUnix, 0).In(user.Time.Location)
 
 		// Time until launch date, relative to user's time zone
-		userNow := time.Now().In(user.Time.Location)
+		userNow := now.In(user.Time.Location)
 		userEta := userLaunchTime.Sub(userNow)
 
 		// Get a friendly ETA string (e.g. "tomorrow", "in 2 days")
@@ -790,46 +1048,135 @@ func (cache *Cache) ScheduleMessage(user *users.User, showMissions bool, botUser
 		}
 	}
 
-	// Add the footer
-	message += "🟢🟡🔴 *Launch-time accuracy*"
+	return message
+}
 
-	// Escape the message, return it
-	return utils.PrepareInputForMarkdown(message, "italictext")
+// Returns the launch information used for deciding if a chat should receive the launch
+func (launch *Launch) FilterInfo() users.LaunchInfo {
+	return users.LaunchInfo{
+		Id:           launch.Id,
+		ProviderId:   launch.LaunchProvider.Id,
+		ProviderName: launch.LaunchProvider.Name,
+		LocationId:   launch.LaunchPad.Location.Id,
+		LocationName: launch.LaunchPad.Location.Name,
+		PadName:      launch.LaunchPad.Name,
+		VehicleId:    launch.Rocket.Config.Id,
+		Family:       launch.Rocket.Config.Family,
+		Name:         launch.Name,
+		VehicleName:  launch.Rocket.Config.Name,
+		MissionName:  launch.Mission.Name,
+		MissionType:  launch.Mission.Type,
+		Orbit:        launch.Mission.Orbit.Abbrev,
+		OrbitName:    launch.Mission.Orbit.Name,
+	}
 }
 
-// Returns all currently cached launches that the user has subscribed to
-func (cache *Cache) LaunchesUserHasSubscribedTo(user *users.User) []*Launch {
-	if user.SubscribedAll && user.UnsubscribedFrom == "" {
-		// User has subscribed to all launches, and unsubscribed from none
-		return cache.Launches
+// Returns the vehicles of the cached launches, ordered by name
+func (cache *Cache) Vehicles() []RocketConfiguration {
+	seen := make(map[int]bool)
+	vehicles := []RocketConfiguration{}
+
+	for _, launch := range cache.Launches {
+		config := launch.Rocket.Config
+
+		if config.Id == 0 || seen[config.Id] {
+			continue
+		}
+
+		seen[config.Id] = true
+		vehicles = append(vehicles, config)
 	}
 
-	if user.SubscribedTo == "" && user.UnsubscribedFrom == "" {
-		// User has subscribed to nothing
-		return []*Launch{}
+	sort.Slice(vehicles, func(i, j int) bool {
+		return vehicles[i].Name < vehicles[j].Name
+	})
+
+	return vehicles
+}
+
+// Orbits and mission types listed for filtering, even without upcoming launches with them
+var (
+	CommonOrbits       = []string{"LEO", "SSO", "PO", "MEO", "GTO", "GEO", "HEO", "Sub"}
+	CommonMissionTypes = []string{
+		"Communications", "Earth Science", "Human Exploration", "Navigation", "Resupply",
+		"Robotic Exploration", "Test Flight", "Tourism",
 	}
+)
 
-	// Load user states
-	userNotifStates := user.GetNotificationStateMap()
+// Returns the orbits or mission types chats can filter by: the common ones, the ones of the
+// cached launches, and the ones the chat already filters by. Kind is "orbit" or "type".
+func (cache *Cache) MissionFilterValues(kind string, user *users.User) []string {
+	values := []string{}
+	seen := make(map[string]bool)
+
+	add := func(value string) {
+		// Filters are stored as comma-separated lists
+		if value != "" && !strings.Contains(value, ",") && !seen[strings.ToLower(value)] {
+			seen[strings.ToLower(value)] = true
+			values = append(values, value)
+		}
+	}
 
-	// Track what launches user has subscribed to
-	subscribedTo := []*Launch{}
+	common := CommonOrbits
 
-	// If user has all enabled notifications, verify this ID is not in disabled IDs
-	var (
-		ok        bool
-		isEnabled bool
-	)
+	if kind == "type" {
+		common = CommonMissionTypes
+	}
+
+	for _, value := range common {
+		add(value)
+	}
+
+	// Values of upcoming launches are sorted after the common ones
+	cached := []string{}
 
 	for _, launch := range cache.Launches {
-		// Load launch from notification states
-		isEnabled, ok = userNotifStates[launch.LaunchProvider.Id]
+		if kind == "orbit" {
+			cached = append(cached, launch.Mission.Orbit.Abbrev)
+		} else {
+			cached = append(cached, launch.Mission.Type)
+		}
+	}
 
-		if user.SubscribedAll && !ok {
-			// If user has subscribed to all, and launch is not found, then it has not been disabled
-			subscribedTo = append(subscribedTo, launch)
-		} else if !user.SubscribedAll && isEnabled {
-			// If user has not subscribed to all launches and state is enabled, add
+	cached = append(cached, user.MissionFilterValues(kind)...)
+	sort.Strings(cached)
+
+	for _, value := range cached {
+		add(value)
+	}
+
+	return values
+}
+
+// Returns the rocket families of the cached launches, ordered by name
+func (cache *Cache) RocketFamilies() []string {
+	seen := make(map[string]bool)
+	families := []string{}
+
+	for _, launch := range cache.Launches {
+		family := launch.Rocket.Config.Family
+
+		// Family names are used in callback data, where slashes are separators
+		if family == "" || strings.Contains(family, "/") || seen[family] {
+			continue
+		}
+
+		seen[family] = true
+		families = append(families, family)
+	}
+
+	sort.Strings(families)
+	return families
+}
+
+// Returns all currently cached launches that the user has subscribed to and that pass its
+// filters, including launches only its allowed keywords or allow rules include
+func (cache *Cache) LaunchesUserHasSubscribedTo(user *users.User) []*Launch {
+	// Track what launches user has subscribed to
+	subscribedTo := []*Launch{}
+
+	for _, launch := range cache.Launches {
+		if launch.PassesChatFilters(user) {
 			subscribedTo = append(subscribedTo, launch)
 		}
 	}
@@ -845,7 +1192,7 @@ func (cache *Cache) LaunchUserHasSubscribedToAtIndex(user *users.User, index int
 		return cache.Launches[index], len(cache.Launches), true
 	}
 
-	if user.SubscribedTo == "" && user.UnsubscribedFrom == "" {
+	if user.SubscribedTo == "" && user.UnsubscribedFrom == "" && !user.HasSiteOrVehicleSubscriptions() {
 		// User has subscribed to nothing, and unsubscribed from nothing
 		return cache.Launches[index], len(cache.Launches), false
 	}
@@ -883,7 +1230,7 @@ func (cache *Cache) NextLaunchMessage(user *users.User, index int) (string, int)
 	launch, userSubLaunchCount, subscribedTo := cache.LaunchUserHasSubscribedToAtIndex(user, index)
 
 	// If cache has old launches, refresh it
-	if launch.NETUnix < time.Now().Unix() {
+	if launch.NETUnix < cache.Now().Unix() {
 		cache.Populate()
 		launch = cache.Launches[index]
 	}
@@ -896,7 +1243,7 @@ func (cache *Cache) NextLaunchMessage(user *users.User, index int) (string, int)
 			"%s",
 
 		utils.Monospaced(name),
-		launch.MessageBodyText(true, false),
+		launch.MessageBodyText(true, false, cache.Now()),
 	)
 
 	// Check notification status with keyword filtering support
@@ -954,10 +1301,10 @@ func (cache *Cache) NextLaunchMessage(user *users.User, index int) (string, int)
 	return utils.PrepareInputForMarkdown(text, "text"), userSubLaunchCount
 }
 
-// Constructs the message for a postpone notification
-func (launch *Launch) PostponeNotificationMessage(postponedBy int64) (string, tb.SendOptions) {
+// Constructs the message for a postpone notification, at the time now
+func (launch *Launch) PostponeNotificationMessage(postponedBy int64, now time.Time) (string, tb.SendOptions) {
 	// New T- until launch
-	untilLaunch := time.Until(time.Unix(launch.NETUnix, 0))
+	untilLaunch := time.Unix(launch.NETUnix, 0).Sub(now)
 	log.Debug().Msgf("Generating postpone message, postponedBy=%d", postponedBy)
 
 	// Text for the postpone notification
@@ -996,7 +1343,7 @@ func (launch *Launch) PostponeNotificationMessage(postponedBy int64) (string, tb
 // Builds a complete Sendable for a postpone notification
 func (launch *Launch) PostponeNotificationSendable(db *Database, postpone Postpone, platform string) *sendables.Sendable {
 	// Get text and send-options
-	text, sendOptions := launch.PostponeNotificationMessage(postpone.PostponedBy)
+	text, sendOptions := launch.PostponeNotificationMessage(postpone.PostponedBy, db.Now())
 
 	log.Debug().Msgf("Text generated:\n%s", text)
 
@@ -1005,32 +1352,14 @@ func (launch *Launch) PostponeNotificationSendable(db *Database, postpone Postpo
 	recipients := launch.NotificationRecipients(db, "postpone", platform)
 	filteredRecipients := []*users.User{}
 
-	// Iterate notification states in-order
-	orderedStates := []string{"Sent24h", "Sent12h", "Sent1h", "Sent5min"}
-
-	// Filter all recipients that have received one of the previous notifications
+	// Filter all recipients that have received one of the reset notifications
 	for _, user := range recipients {
-		// Load user's notification states
-		userStates := user.NotificationTimePreferenceMap()
-
-		// Loop over all states in order
-		for _, state := range orderedStates {
-			reset, ok := postpone.ResetStates[state]
+		for notifType, reset := range postpone.ResetStates {
+			leadTime, ok := users.LeadTimeOfType(notifType)
 
-			if !ok {
-				log.Warn().Msgf("State=%s not found in resetStates (%#v)", state, postpone.ResetStates)
-				continue
-			}
-
-			if reset {
-				// If state was reset, check if user was subscribed to this type
-				if userStates[strings.ReplaceAll(state, "Sent", "")] {
-					// log.Debug().Msgf("User=%s has enabled reset_state=%s, adding to recipients", user.Id, state)
-					filteredRecipients = append(filteredRecipients, user)
-					break
-				} else {
-					log.Debug().Msgf("User=%s has disabled reset_state=%s, skipping this state", user.Id, state)
-				}
+			if reset && ok && user.HasLeadTime(leadTime) {
+				filteredRecipients = append(filteredRecipients, user)
+				break
 			}
 		}
 	}
@@ -1054,6 +1383,281 @@ func (launch *Launch) PostponeNotificationSendable(db *Database, postpone Postpo
 	return &sendable
 }
 
+// Returns a header for the outcome notification, based on the launch's status
+func (launch *Launch) OutcomeHeader() string {
+	switch launch.Status.Id {
+	case 3:
+		return "✅ *Launch successful*"
+	case 4:
+		return "💥 *Launch failed*"
+	case 7:
+		return "⚠️ *Launch partially failed*"
+	}
+
+	log.Warn().Msgf("Unexpected status=%d in OutcomeHeader (%s)", launch.Status.Id, launch.Slug)
+	return "🚀 *Launch completed*"
+}
+
+// Returns a single-liner of landing results for a launcher, or an empty string if no landing was attempted
+func (booster *Launcher) landingResultString() string {
+	if booster.Serial == "" || !booster.LandingAttempt {
+		return ""
+	}
+
+	role := booster.Role
+	if role == "" {
+		role = "Stage"
+	}
+
+	// Landing type and location, e.g. "ASDS, OCISLY"
+	landing := booster.LandingType.Abbrev
+
+	if booster.LandingLocation.Abbrev != "" {
+		if landing != "" {
+			landing += ", "
+		}
+
+		landing += booster.LandingLocation.Abbrev
+	}
+
+	if landing != "" {
+		landing = fmt.Sprintf(" (%s)", landing)
+	}
+
+	result := map[bool]string{true: "landed ✅", false: "did not land ❌"}[booster.LandingSuccess]
+
+	return fmt.Sprintf("*%s* %s %s\n", role, utils.Monospaced(booster.Serial+landing), result)
+}
+
+// Constructs the message for a launch outcome notification
+func (launch *Launch) OutcomeNotificationMessage() (string, tb.SendOptions) {
+	// Add the failure reason, if one exists
+	var failReason string
+
+	if launch.Status.Id != 3 && strings.TrimSpace(launch.FailReason) != "" {
+		failReason = fmt.Sprintf("*Reason* %s\n", launch.FailReason)
+	}
+
+	// Add booster landing results, if any landings were attempted
+	var landings string
+
+	for i := range launch.Rocket.Stages {
+		landings += launch.Rocket.Stages[i].landingResultString()
+	}
+
+	if landings != "" {
+		landings = "\n🛬 *Recovery*\n" + landings
+	}
+
+	text := fmt.Sprintf(
+		"%s: *%s*\n"+
+			"*Provider* %s\n"+
+			"*Rocket* %s\n"+
+			"%s"+
+			"%s",
+
+		launch.OutcomeHeader(), utils.Monospaced(launch.HeaderName()),
+		utils.Monospaced(launch.LaunchProvider.ShortName()),
+		utils.Monospaced(launch.Rocket.Config.FullName),
+		failReason, landings,
+	)
+
+	text = utils.PrepareInputForMarkdown(text, "text")
+
+	sendOptions := tb.SendOptions{
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+	}
+
+	return text, sendOptions
+}
+
+// Builds a complete Sendable for a launch outcome notification. The outcome is only
+// sent to chats that received a final notification of the launch.
+func (launch *Launch) OutcomeNotificationSendable(db *Database, platform string) *sendables.Sendable {
+	// Get text and send-options
+	text, sendOptions := launch.OutcomeNotificationMessage()
+
+	// Load recipients, and filter out all chats that did not receive a final notification
+	// of this launch (e.g. no final lead time at the time, muted, filtered or quiet-dropped)
+	recipients := launch.NotificationRecipients(db, "outcome", platform)
+	filteredRecipients := []*users.User{}
+
+	for _, user := range recipients {
+		if launch.ReceivedFinalNotification(user.Id) {
+			filteredRecipients = append(filteredRecipients, user)
+		}
+	}
+
+	log.Debug().Msgf("Filtered outcome recipients: %d ➙ %d", len(recipients), len(filteredRecipients))
+
+	sendable := sendables.Sendable{
+		Type:             sendables.Notification,
+		NotificationType: "outcome",
+		Platform:         platform,
+		LaunchId:         launch.Id,
+		Recipients:       filteredRecipients,
+		Message: &sendables.Message{
+			TextContent: text,
+			AddUserTime: false,
+			RefTime:     launch.NETUnix,
+			SendOptions: sendOptions,
+		},
+	}
+
+	return &sendable
+}
+
+// Constructs the message for a hold or a scrub notification
+func (launch *Launch) HoldNotificationMessage(scrubbed bool) (string, tb.SendOptions) {
+	header := map[bool]string{true: "🛑 *Launch scrubbed*", false: "⏸️ *Launch on hold*"}[scrubbed]
+
+	// Add the hold reason, if one exists
+	var holdReason string
+
+	if strings.TrimSpace(launch.HoldReason) != "" {
+		holdReason = fmt.Sprintf("*Reason* %s\n", launch.HoldReason)
+	}
+
+	text := fmt.Sprintf(
+		"%s: *%s*\n"+
+			"*Provider* %s\n"+
+			"*Rocket* %s\n"+
+			"%s",
+
+		header, utils.Monospaced(launch.HeaderName()),
+		utils.Monospaced(launch.LaunchProvider.ShortName()),
+		utils.Monospaced(launch.Rocket.Config.FullName),
+		holdReason,
+	)
+
+	text = utils.PrepareInputForMarkdown(text, "text")
+
+	muteBtn := tb.InlineButton{
+		Unique: "muteToggle",
+		Text:   "🔇 Mute launch",
+		Data:   fmt.Sprintf("%s/1/%s", launch.Id, "hold"),
+	}
+
+	sendOptions := tb.SendOptions{
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+		ReplyMarkup:           &tb.ReplyMarkup{InlineKeyboard: [][]tb.InlineButton{{muteBtn}}},
+	}
+
+	return text, sendOptions
+}
+
+// Builds a complete Sendable for a hold or a scrub notification. These are only
+// sent to chats that have already received a notification for the launch.
+func (launch *Launch) HoldNotificationSendable(db *Database, scrubbed bool, platform string) *sendables.Sendable {
+	// Get text and send-options
+	text, sendOptions := launch.HoldNotificationMessage(scrubbed)
+
+	// Load recipients, and filter out all chats that have not been notified of this launch
+	recipients := launch.NotificationRecipients(db, "hold", platform)
+	filteredRecipients := []*users.User{}
+
+	if launch.SentNotificationIds != "" {
+		sentIds := launch.LoadSentNotificationIdMap()
+
+		for _, user := range recipients {
+			if _, ok := sentIds[user.Id]; ok {
+				filteredRecipients = append(filteredRecipients, user)
+			}
+		}
+	}
+
+	log.Debug().Msgf("Filtered hold recipients: %d ➙ %d", len(recipients), len(filteredRecipients))
+
+	sendable := sendables.Sendable{
+		Type:             sendables.Notification,
+		NotificationType: "hold",
+		Platform:         platform,
+		LaunchId:         launch.Id,
+		Recipients:       filteredRecipients,
+		Message: &sendables.Message{
+			TextContent: text,
+			AddUserTime: false,
+			RefTime:     launch.NETUnix,
+			SendOptions: sendOptions,
+		},
+	}
+
+	return &sendable
+}
+
+// Constructs the message for a webcast-live notification, at the time now
+func (launch *Launch) WebcastNotificationMessage(now time.Time) (string, tb.SendOptions) {
+	// Time until launch, if the launch is still upcoming
+	var untilLaunch string
+
+	if until := time.Unix(launch.NETUnix, 0).Sub(now); until > 0 {
+		untilLaunch = fmt.Sprintf("Lift-off is scheduled in %s.\n",
+			durafmt.Parse(until).LimitFirstN(2).String())
+	}
+
+	text := fmt.Sprintf(
+		"🔴 *Webcast is live*: *%s*\n"+
+			"*Provider* %s\n"+
+			"*Rocket* %s\n\n"+
+			"%s",
+
+		utils.Monospaced(launch.HeaderName()),
+		utils.Monospaced(launch.LaunchProvider.ShortName()),
+		utils.Monospaced(launch.Rocket.Config.FullName),
+		untilLaunch,
+	)
+
+	text = utils.PrepareInputForMarkdown(text, "text")
+
+	// Mute button is always the first row, so the mute callback can find it
+	kb := [][]tb.InlineButton{{{
+		Unique: "muteToggle",
+		Text:   "🔇 Mute launch",
+		Data:   fmt.Sprintf("%s/1/%s", launch.Id, "webcast"),
+	}}}
+
+	if launch.WebcastLink != "" {
+		kb = append(kb, []tb.InlineButton{{Text: "🔴 Watch live", URL: launch.WebcastLink}})
+	}
+
+	sendOptions := tb.SendOptions{
+		ParseMode:             "MarkdownV2",
+		DisableWebPagePreview: true,
+		ReplyMarkup:           &tb.ReplyMarkup{InlineKeyboard: kb},
+	}
+
+	return text, sendOptions
+}
+
+// Builds a complete Sendable for a webcast-live notification
+func (launch *Launch) WebcastNotificationSendable(db *Database, platform string) *sendables.Sendable {
+	// Get text and send-options
+	text, sendOptions := launch.WebcastNotificationMessage(db.Now())
+
+	// Load recipients: provider and keyword filters are applied by NotificationRecipients
+	recipients := launch.NotificationRecipients(db, "webcast", platform)
+
+	sendable := sendables.Sendable{
+		Type:             sendables.Notification,
+		NotificationType: "webcast",
+		Platform:         platform,
+		LaunchId:         launch.Id,
+		Recipients:       recipients,
+		Message: &sendables.Message{
+			TextContent: text,
+			AddUserTime: false,
+			RefTime:     launch.NETUnix,
+			SendOptions: sendOptions,
+			WebcastLink: launch.WebcastLink,
+			Webcasts:    launch.Webcasts(),
+		},
+	}
+
+	return &sendable
+}
+
 // Generate a launch name, either using the mission name or using a split launch name
 func (launch *Launch) HeaderName() string {
 	// Use the mission name; however, this may be empty
@@ -1072,92 +1676,62 @@ func (launch *Launch) HeaderName() string {
 	return name
 }
 
-// Returns the first unsent notification type for a launch
+// Returns the first unsent notification for a launch, out of the lead times any chat has enabled
 func (launch *Launch) NextNotification(db *Database) Notification {
-	// Not ordered, so we re-declare the list below
-	NotificationSendTimes := map[string]time.Duration{
-		"Sent24h":  time.Duration(24) * time.Hour,
-		"Sent12h":  time.Duration(12) * time.Hour,
-		"Sent1h":   time.Duration(1) * time.Hour,
-		"Sent5min": time.Duration(5) * time.Minute,
-	}
-
-	// An ordered list of send times
-	notificationClasses := []string{
-		"Sent24h", "Sent12h", "Sent1h", "Sent5min",
-	}
-
 	// Configure time to pre-send notifications by
 	preSendBy := time.Duration(1) * time.Minute
 
 	// Minutes the send-time is allowed to slip by
 	allowedSlip := time.Duration(5)*time.Minute + preSendBy
 
-	// Update map before parsing
-	launch.NotificationState.UpdateMap(launch)
-
-	// Loop over the valid notification classes (24h, 12h, 1h, 5min)
-	for _, notifType := range notificationClasses {
-		// If notification of this type has not been sent, check when it should be sent
-		if !launch.NotificationState.Map[notifType] {
-			// How many seconds before NET the notification is sent
-			secBeforeNet, ok := NotificationSendTimes[notifType]
-
-			if !ok {
-				log.Error().Msgf("[launch.NextNotification] Error parsing notificationType for %s: %s",
-					launch.Id, notifType)
-				continue
-			}
+	// Current time, according to the database's clock
+	now := db.Now().Unix()
 
-			// Calculate send-time from NET, and deduct the pre-send time
-			sendTime := launch.NETUnix - int64(secBeforeNet.Seconds()) - int64(preSendBy.Seconds())
-
-			if sendTime-time.Now().Unix() < 0 {
-				// Notification was missed: calculate by how much
-				missedBy := time.Duration(math.Abs(float64(time.Now().Unix()-sendTime))) * time.Second
-
-				if missedBy > allowedSlip {
-					// Check if the NET moved forwards or backwards
-					// TODO
-
-					// If notification was genuinely missed, by more than allowedSlip, mark as sent
-					log.Warn().Msgf("Missed type=%s notification by %.2f minutes, id=%s; marking as sent...",
-						notifType, missedBy.Minutes(), launch.Slug)
+	// Loop over the lead times in use, from the earliest notification to the last one
+	for _, leadTime := range db.LeadTimesInUse() {
+		// If notification of this type has been sent, move on to the next one
+		if launch.NotificationState.IsSent(leadTime) {
+			continue
+		}
 
-					// Launch was missed: log, and set as sent in database
-					launch.NotificationState.Map[notifType] = true
-					launch.NotificationState.UpdateFlags(launch)
+		notifType := users.LeadTimeType(leadTime)
 
-					// Save state in db
-					err := db.Update([]*Launch{launch}, false, false)
-					if err != nil {
-						log.Error().Err(err).Msg("Error saving updated notification states to disk")
-					}
+		// Calculate send-time from NET, and deduct the pre-send time
+		sendTime := launch.NETUnix - int64(leadTime)*60 - int64(preSendBy.Seconds())
 
-					continue
-				} else {
-					// Notification was missed by less than the allowed maximum slip: continue as usual
-					log.Info().Msgf("[launch.NextNotification] [%s] Missed type=%s by under %.1f min (%.2f min): modifying send-time",
-						launch.Slug, notifType, allowedSlip.Minutes(), missedBy.Minutes())
+		if sendTime-now < 0 {
+			// Notification was missed: calculate by how much
+			missedBy := time.Duration(math.Abs(float64(now-sendTime))) * time.Second
 
-					// Modify to send in 10 seconds
-					sendTime = time.Now().Unix() + 10
+			if missedBy > allowedSlip {
+				// If notification was genuinely missed, by more than allowedSlip, mark as sent
+				log.Warn().Msgf("Missed type=%s notification by %.2f minutes, id=%s; marking as sent...",
+					notifType, missedBy.Minutes(), launch.Slug)
 
-					notifType = strings.ReplaceAll(notifType, "Sent", "")
+				// Launch was missed: log, and set as sent in database
+				launch.NotificationState.MarkSent(leadTime)
 
-					return Notification{
-						Type: notifType, SendTime: sendTime, LaunchId: launch.Id,
-						LaunchName: launch.Name, LaunchNET: launch.NETUnix, Count: 1}
+				// Save state in db
+				err := db.Update([]*Launch{launch}, false, false)
+				if err != nil {
+					log.Error().Err(err).Msg("Error saving updated notification states to disk")
 				}
+
+				continue
 			}
 
-			// Sent is false and has not been missed: return type
-			notifType = strings.ReplaceAll(notifType, "Sent", "")
+			// Notification was missed by less than the allowed maximum slip: continue as usual
+			log.Info().Msgf("[launch.NextNotification] [%s] Missed type=%s by under %.1f min (%.2f min): modifying send-time",
+				launch.Slug, notifType, allowedSlip.Minutes(), missedBy.Minutes())
 
-			return Notification{
-				Type: notifType, SendTime: sendTime, LaunchId: launch.Id,
-				LaunchName: launch.Name, LaunchNET: launch.NETUnix, Count: 1,
-			}
+			// Modify to send in 10 seconds
+			sendTime = now + 10
+		}
+
+		// Sent is false and has not been missed: return type
+		return Notification{
+			Type: notifType, LeadTime: leadTime, SendTime: sendTime, LaunchId: launch.Id,
+			LaunchName: launch.Name, LaunchNET: launch.NETUnix, Count: 1,
 		}
 	}
 
@@ -1170,7 +1744,7 @@ func (launch *Launch) NextNotification(db *Database) Notification {
 // Return a list of all provider IDs associated with a country-code
 func AllIdsByCountryCode(cc string) []string {
 	ids := []string{}
-	for _, id := range IdByCountryCode[cc] {
+	for _, id := range Providers.IdsByCountryCode(cc) {
 		ids = append(ids, fmt.Sprint(id))
 	}
 
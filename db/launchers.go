@@ -0,0 +1,121 @@
+package db
+
+import (
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// Replaces the stored launcher stages of the launches with their current stages
+func (db *Database) SaveLaunchers(launches []*Launch) error {
+	ids := make([]string, 0, len(launches))
+	stages := []*Launcher{}
+
+	for _, launch := range launches {
+		ids = append(ids, launch.Id)
+
+		for i := range launch.Rocket.Stages {
+			stage := &launch.Rocket.Stages[i]
+
+			// Stages are re-inserted on each update, so old row IDs are not kept
+			stage.Id = 0
+			stage.LaunchId = launch.Id
+			stage.Position = i
+
+			stages = append(stages, stage)
+		}
+	}
+
+	return db.Conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("launch_id IN ?", ids).Delete(&Launcher{}).Error; err != nil {
+			return err
+		}
+
+		if len(stages) == 0 {
+			return nil
+		}
+
+		return tx.Create(&stages).Error
+	})
+}
+
+// Loads the launcher stages of the launches from the launchers-table
+func (db *Database) LoadLaunchers(launches []*Launch) {
+	if len(launches) == 0 {
+		return
+	}
+
+	// Map launches by ID, so the stages can be assigned to them
+	launchMap := make(map[string]*Launch, len(launches))
+	ids := make([]string, 0, len(launches))
+
+	for _, launch := range launches {
+		launchMap[launch.Id] = launch
+		ids = append(ids, launch.Id)
+	}
+
+	stages := []Launcher{}
+	result := db.Conn.Where("launch_id IN ?", ids).Order("launch_id, position").Find(&stages)
+
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("Loading launcher stages failed")
+		return
+	}
+
+	for _, launch := range launches {
+		launch.Rocket.Stages = []Launcher{}
+	}
+
+	for _, stage := range stages {
+		launch := launchMap[stage.LaunchId]
+		launch.Rocket.Stages = append(launch.Rocket.Stages, stage)
+	}
+}
+
+// Launcher stages of a launch, as embedded in the launches-table by databases from
+// before the launchers-table
+type embeddedLaunchers struct {
+	Id       string
+	Core     Launcher `gorm:"embedded;embeddedPrefix:rocket_launcher_core_"`
+	Booster1 Launcher `gorm:"embedded;embeddedPrefix:rocket_launcher_booster1_"`
+	Booster2 Launcher `gorm:"embedded;embeddedPrefix:rocket_launcher_booster2_"`
+}
+
+// Moves the core and the two side boosters embedded in the launches-table into the
+// launchers-table. Launches that already have stages in the launchers-table are skipped.
+func (db *Database) migrateEmbeddedLaunchers() error {
+	rows := []embeddedLaunchers{}
+
+	err := db.Conn.Raw(
+		"SELECT * FROM launches WHERE rocket_launcher_count > 0 AND id NOT IN (SELECT launch_id FROM launchers)",
+	).Scan(&rows).Error
+
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+
+	stages := []*Launcher{}
+
+	for _, row := range rows {
+		for i, stage := range []Launcher{row.Core, row.Booster1, row.Booster2} {
+			if stage.Serial == "" {
+				continue
+			}
+
+			stage.Id = 0
+			stage.LaunchId = row.Id
+			stage.Position = i
+			stage.Role = map[bool]string{true: "Core", false: "Booster"}[i == 0]
+
+			stages = append(stages, &stage)
+		}
+	}
+
+	if len(stages) != 0 {
+		if err := db.Conn.Create(&stages).Error; err != nil {
+			return err
+		}
+	}
+
+	log.Info().Msgf("Migrated %d launcher stage(s) of %d launch(es) to the launchers-table", len(stages), len(rows))
+	return nil
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"launchbot/users"
+	"strings"
+	"testing"
+)
+
+// Tests that any number of stages is stored, loaded, and rendered
+func TestLauncherStages(t *testing.T) {
+	db := Database{}
+	db.Cache = &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}}
+
+	if !db.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	// A core with four strap-on boosters
+	launch := &Launch{
+		Id: "soyuz", Slug: "soyuz", Name: "Soyuz 2.1a | Progress MS-29",
+		LaunchProvider: LaunchProvider{Id: 63, Name: "Russian Federal Space Agency (ROSCOSMOS)"},
+		Rocket:         Rocket{Stages: []Launcher{{Role: "Core", Serial: "Blok A"}}},
+	}
+
+	for _, serial := range []string{"Blok B", "Blok V", "Blok G", "Blok D"} {
+		launch.Rocket.Stages = append(launch.Rocket.Stages, Launcher{Role: "Booster", Serial: serial})
+	}
+
+	if err := db.Update([]*Launch{launch}, true, false); err != nil {
+		t.Fatalf("saving launch failed: %v", err)
+	}
+
+	loaded, err := db.Cache.FindLaunchById("soyuz")
+
+	if err != nil || len(loaded.Rocket.Stages) != 5 {
+		t.Fatalf("expected 5 stages from disk (err=%v)", err)
+	}
+
+	if loaded.Rocket.CoreStage().Serial != "Blok A" || len(loaded.Rocket.Boosters()) != 4 {
+		t.Errorf("expected a core and 4 boosters, got %#v", loaded.Rocket.Stages)
+	}
+
+	// Stages are kept in their original order
+	if loaded.Rocket.Stages[4].Serial != "Blok D" {
+		t.Errorf("expected stages to keep their order, got %#v", loaded.Rocket.Stages)
+	}
+
+	text := loaded.BoosterInformation()
+
+	for _, serial := range []string{"`Blok` `A`", "`Blok` `B`", "`Blok` `D`"} {
+		if !strings.Contains(text, serial) {
+			t.Errorf("booster information is missing %s:\n%s", serial, text)
+		}
+	}
+
+	// An update replaces the old stages, instead of appending to them
+	launch.Rocket.Stages = launch.Rocket.Stages[:1]
+
+	if err := db.Update([]*Launch{launch}, true, false); err != nil {
+		t.Fatalf("saving launch failed: %v", err)
+	}
+
+	if loaded, _ = db.Cache.FindLaunchById("soyuz"); len(loaded.Rocket.Stages) != 1 {
+		t.Errorf("expected 1 stage after the update, got %d", len(loaded.Rocket.Stages))
+	}
+}
+
+// Tests that the stages embedded in the launches-table by older databases are migrated once
+func TestMigrateEmbeddedLaunchers(t *testing.T) {
+	db := Database{}
+	db.Cache = &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}}
+
+	if !db.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	if err := db.Update([]*Launch{{Id: "falcon-heavy"}, {Id: "electron"}}, true, false); err != nil {
+		t.Fatalf("saving launches failed: %v", err)
+	}
+
+	// Re-create the columns of the embedded core and side boosters
+	for _, column := range []string{
+		"rocket_launcher_count integer", "rocket_launcher_core_serial text",
+		"rocket_launcher_core_landing_attempt numeric", "rocket_launcher_booster1_serial text",
+		"rocket_launcher_booster2_serial text",
+	} {
+		if err := db.Conn.Exec("ALTER TABLE launches ADD COLUMN " + column).Error; err != nil {
+			t.Fatalf("adding column failed: %v", err)
+		}
+	}
+
+	db.Conn.Exec(`UPDATE launches SET rocket_launcher_count = 3, rocket_launcher_core_serial = 'B1068',
+		rocket_launcher_core_landing_attempt = 1, rocket_launcher_booster1_serial = 'B1064',
+		rocket_launcher_booster2_serial = 'B1065' WHERE id = 'falcon-heavy'`)
+
+	// Migrating twice does not duplicate the stages
+	for i := 0; i < 2; i++ {
+		if err := db.migrateEmbeddedLaunchers(); err != nil {
+			t.Fatalf("migrating launchers failed: %v", err)
+		}
+	}
+
+	launches := []*Launch{{Id: "falcon-heavy"}, {Id: "electron"}}
+	db.LoadLaunchers(launches)
+
+	rocket := launches[0].Rocket
+
+	if len(rocket.Stages) != 3 || rocket.CoreStage().Serial != "B1068" || !rocket.CoreStage().LandingAttempt {
+		t.Fatalf("expected a migrated core and 2 boosters, got %#v", rocket.Stages)
+	}
+
+	if boosters := rocket.Boosters(); boosters[0].Serial != "B1064" || boosters[1].Serial != "B1065" {
+		t.Errorf("expected boosters B1064 and B1065, got %#v", boosters)
+	}
+
+	if len(launches[1].Rocket.Stages) != 0 {
+		t.Errorf("expected no stages for a launch without launchers, got %#v", launches[1].Rocket.Stages)
+	}
+}
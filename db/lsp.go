@@ -1,87 +1,349 @@
 package db
 
-// TODO FUTURE save in a database table + cache (V3.1)
-// - when a new LSP ID is encountered in /launch/upcoming endpoint, request its info and insert into LSP table
-// Currently contains all featured launch providers + a couple extra
+import (
+	"errors"
+	"fmt"
+	"launchbot/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// A launch service provider, stored in the providers-table. Providers are added
+// automatically when an unknown provider ID is seen in an API update, while the
+// short name, flag and country code can be edited by the bot's admin.
+type Provider struct {
+	Id          int    `gorm:"primaryKey;autoIncrement:false"`
+	Name        string // Full name, from the API
+	ShortName   string // Name used in messages and keyboards
+	Flag        string
+	CountryCode string `gorm:"index"` // Country the provider is listed under, e.g. "USA" (EU is a faux-country)
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Providers the registry is seeded with: all featured launch providers + a couple extra
 // https://ll.thespacedevs.com/2.2.0/agencies/?featured=true&limit=50
-var LSPShorthands = map[int]LSP{
+var defaultProviders = []Provider{
 	// Agencies
-	17: {Name: "CNSA", Flag: "🇨🇳", Cc: "CHN"},
-	31: {Name: "ISRO", Flag: "🇮🇳", Cc: "IND"},
-	37: {Name: "JAXA", Flag: "🇯🇵", Cc: "JPN"},
-	44: {Name: "NASA", Flag: "🇺🇸", Cc: "USA"},
-	63: {Name: "ROSCOSMOS", Flag: "🇷🇺", Cc: "RUS"},
+	{Id: 17, ShortName: "CNSA", Flag: "🇨🇳", CountryCode: "CHN"},
+	{Id: 31, ShortName: "ISRO", Flag: "🇮🇳", CountryCode: "IND"},
+	{Id: 37, ShortName: "JAXA", Flag: "🇯🇵", CountryCode: "JPN"},
+	{Id: 44, ShortName: "NASA", Flag: "🇺🇸", CountryCode: "USA"},
+	{Id: 63, ShortName: "ROSCOSMOS", Flag: "🇷🇺", CountryCode: "RUS"},
 
 	// Corporations, including state and commercial
-	88:  {Name: "CASC", Flag: "🇨🇳", Cc: "CHN"},
-	96:  {Name: "KhSC", Flag: "🇷🇺", Cc: "RUS"},
-	98:  {Name: "Mitsubishi H.I.", Flag: "🇯🇵", Cc: "JPN"},
-	115: {Name: "Arianespace", Flag: "🇪🇺", Cc: "EU"},
-	121: {Name: "SpaceX", Flag: "🇺🇸", Cc: "USA"},
-	124: {Name: "ULA", Flag: "🇺🇸", Cc: "USA"},
-	141: {Name: "Blue Origin", Flag: "🇺🇸", Cc: "USA"},
-	147: {Name: "Rocket Lab", Flag: "🇺🇸", Cc: "USA"},
-	190: {Name: "Antrix Corp.", Flag: "🇮🇳", Cc: "IND"},
-	193: {Name: "RUS Space Forces", Flag: "🇷🇺", Cc: "RUS"},
-	194: {Name: "ExPace", Flag: "🇨🇳", Cc: "CHN"},
-	199: {Name: "Virgin Orbit", Flag: "🇺🇸", Cc: "USA"},
-	257: {Name: "Northrop Grumman", Flag: "🇺🇸", Cc: "USA"},
-	259: {Name: "LandSpace", Flag: "🇨🇳", Cc: "CHN"},
-	265: {Name: "Firefly", Flag: "🇺🇸", Cc: "USA"},
-	266: {Name: "Relativity", Flag: "🇺🇸", Cc: "USA"},
-	274: {Name: "iSpace", Flag: "🇨🇳", Cc: "CHN"},
-	285: {Name: "Astra", Flag: "🇺🇸", Cc: "USA"},
+	{Id: 88, ShortName: "CASC", Flag: "🇨🇳", CountryCode: "CHN"},
+	{Id: 96, ShortName: "KhSC", Flag: "🇷🇺", CountryCode: "RUS"},
+	{Id: 98, ShortName: "Mitsubishi H.I.", Flag: "🇯🇵", CountryCode: "JPN"},
+	{Id: 115, ShortName: "Arianespace", Flag: "🇪🇺", CountryCode: "EU"},
+	{Id: 121, ShortName: "SpaceX", Flag: "🇺🇸", CountryCode: "USA"},
+	{Id: 124, ShortName: "ULA", Flag: "🇺🇸", CountryCode: "USA"},
+	{Id: 141, ShortName: "Blue Origin", Flag: "🇺🇸", CountryCode: "USA"},
+	{Id: 147, ShortName: "Rocket Lab", Flag: "🇺🇸", CountryCode: "USA"},
+	{Id: 190, ShortName: "Antrix Corp.", Flag: "🇮🇳", CountryCode: "IND"},
+	{Id: 193, ShortName: "RUS Space Forces", Flag: "🇷🇺", CountryCode: "RUS"},
+	{Id: 194, ShortName: "ExPace", Flag: "🇨🇳", CountryCode: "CHN"},
+	{Id: 199, ShortName: "Virgin Orbit", Flag: "🇺🇸", CountryCode: "USA"},
+	{Id: 257, ShortName: "Northrop Grumman", Flag: "🇺🇸", CountryCode: "USA"},
+	{Id: 259, ShortName: "LandSpace", Flag: "🇨🇳", CountryCode: "CHN"},
+	{Id: 265, ShortName: "Firefly", Flag: "🇺🇸", CountryCode: "USA"},
+	{Id: 266, ShortName: "Relativity", Flag: "🇺🇸", CountryCode: "USA"},
+	{Id: 274, ShortName: "iSpace", Flag: "🇨🇳", CountryCode: "CHN"},
+	{Id: 285, ShortName: "Astra", Flag: "🇺🇸", CountryCode: "USA"},
 
 	// Small-scale providers, incl. sub-orbital operators
-	1002: {Name: "Interstellar tech.", Flag: "🇯🇵", Cc: "JPN"},
-	1021: {Name: "Galactic Energy", Flag: "🇨🇳", Cc: "CHN"},
-	1024: {Name: "Virgin Galactic", Flag: "🇺🇸", Cc: "USA"},
-	1029: {Name: "TiSPACE", Flag: "🇹🇼", Cc: "TWN"},
-	1030: {Name: "ABL", Flag: "🇺🇸", Cc: "USA"},
-	1038: {Name: "ELA", Flag: "🇦🇺", Cc: "AUS"},
+	{Id: 1002, ShortName: "Interstellar tech.", Flag: "🇯🇵", CountryCode: "JPN"},
+	{Id: 1021, ShortName: "Galactic Energy", Flag: "🇨🇳", CountryCode: "CHN"},
+	{Id: 1024, ShortName: "Virgin Galactic", Flag: "🇺🇸", CountryCode: "USA"},
+	{Id: 1029, ShortName: "TiSPACE", Flag: "🇹🇼", CountryCode: "TWN"},
+	{Id: 1030, ShortName: "ABL", Flag: "🇺🇸", CountryCode: "USA"},
+	{Id: 1038, ShortName: "ELA", Flag: "🇦🇺", CountryCode: "AUS"},
+}
+
+// Order of the countries in keyboards: countries not listed here are sorted alphabetically after these
+var countryOrder = []string{"USA", "EU", "CHN", "RUS", "IND", "JPN", "TWN", "AUS"}
+
+// Display names of countries, without the flag
+var countryNames = map[string]string{
+	"USA": "USA", "EU": "EU", "CHN": "China", "RUS": "Russia", "IND": "India", "JPN": "Japan",
+	"TWN": "Taiwan", "AUS": "Australia", "NZL": "New Zealand", "FRA": "France", "DEU": "Germany",
+	"GBR": "UK", "KOR": "South Korea", "PRK": "North Korea", "IRN": "Iran", "ISR": "Israel",
+	"BRA": "Brazil", "KAZ": "Kazakhstan", "UKR": "Ukraine", "ITA": "Italy", "ESP": "Spain",
+	"CAN": "Canada", "NOR": "Norway", "SWE": "Sweden",
+}
+
+// A cached registry of launch providers, backed by the providers-table
+type ProviderRegistry struct {
+	providers map[int]*Provider
+	db        *Database
+	mutex     sync.RWMutex
+}
+
+// The provider registry, seeded with the default providers until loaded from the database
+var Providers = NewProviderRegistry()
+
+// Creates a registry that only contains the default providers
+func NewProviderRegistry() *ProviderRegistry {
+	registry := &ProviderRegistry{providers: make(map[int]*Provider)}
+
+	for _, provider := range defaultProviders {
+		provider := provider
+		provider.Name = provider.ShortName
+		registry.providers[provider.Id] = &provider
+	}
+
+	return registry
+}
+
+// Loads the providers from the database, seeding the table with the default providers if it's empty
+func (registry *ProviderRegistry) Load(db *Database) error {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	providers := []*Provider{}
+
+	// Providers without a country used to be stored under a placeholder country code
+	err := db.Conn.Model(&Provider{}).Where("country_code = ?", "UNK").
+		Updates(map[string]interface{}{"country_code": "", "flag": ""}).Error
+
+	if err != nil {
+		return err
+	}
+
+	if err := db.Conn.Find(&providers).Error; err != nil {
+		return err
+	}
+
+	if len(providers) == 0 {
+		log.Info().Msgf("Providers-table is empty: seeding with %d default providers", len(registry.providers))
+
+		for _, provider := range registry.providers {
+			providers = append(providers, provider)
+		}
+
+		if err := db.Conn.Create(&providers).Error; err != nil {
+			return err
+		}
+	}
+
+	registry.db = db
+	registry.providers = make(map[int]*Provider, len(providers))
+
+	for _, provider := range providers {
+		registry.providers[provider.Id] = provider
+	}
+
+	log.Debug().Msgf("Loaded %d launch providers", len(registry.providers))
+	return nil
+}
+
+// Returns a copy of the provider with this ID
+func (registry *ProviderRegistry) Get(id int) (Provider, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	provider, ok := registry.providers[id]
+
+	if !ok {
+		return Provider{}, false
+	}
+
+	return *provider, true
+}
+
+// Returns copies of all providers, ordered by ID
+func (registry *ProviderRegistry) All() []Provider {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	providers := make([]Provider, 0, len(registry.providers))
+
+	for _, provider := range registry.providers {
+		providers = append(providers, *provider)
+	}
+
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Id < providers[j].Id
+	})
+
+	return providers
+}
+
+// Adds a launch's provider to the registry if it's unknown, returning true if it was added
+func (registry *ProviderRegistry) Register(launchProvider *LaunchProvider) bool {
+	if launchProvider.Id == 0 {
+		return false
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if _, ok := registry.providers[launchProvider.Id]; ok {
+		return false
+	}
+
+	// Providers with multiple countries, e.g. "USA,NZL", are listed under the first one.
+	// Providers without a country are listed under "Other".
+	cc := strings.ToUpper(strings.TrimSpace(strings.Split(launchProvider.CountryCode, ",")[0]))
+
+	// Prefer the abbreviation for long names
+	shortName := launchProvider.Name
+
+	if len(shortName) > len("Virgin Orbit") && launchProvider.Abbrev != "" {
+		shortName = launchProvider.Abbrev
+	}
+
+	provider := &Provider{
+		Id: launchProvider.Id, Name: launchProvider.Name, ShortName: shortName,
+		Flag: utils.CountryCodeFlag(cc), CountryCode: cc,
+	}
+
+	if registry.db != nil {
+		if err := registry.db.Conn.Create(provider).Error; err != nil {
+			log.Error().Err(err).Msgf("Saving new provider with id=%d failed", provider.Id)
+			return false
+		}
+	}
+
+	registry.providers[provider.Id] = provider
+
+	log.Info().Msgf("Registered new launch provider %s (id=%d, cc=%s)", provider.Name, provider.Id, provider.CountryCode)
+	return true
 }
 
-// Map country codes to a list of provider IDs under this country code
-var IdByCountryCode = map[string][]int{
-	"USA": {44, 121, 124, 141, 147, 199, 257, 265, 266, 285, 1024, 1030},
-	"EU":  {115},
-	"CHN": {17, 88, 194, 259, 274, 1021},
-	"RUS": {63, 96},
-	"IND": {31, 190},
-	"JPN": {37, 98, 1002},
-	"TWN": {1029},
-	"AUS": {1038},
+// Edits a field of a provider, and saves it. Field is one of "name", "flag" or "cc".
+func (registry *ProviderRegistry) Edit(id int, field string, value string) (Provider, error) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	provider, ok := registry.providers[id]
+
+	if !ok {
+		return Provider{}, fmt.Errorf("no provider with id=%d", id)
+	}
+
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return Provider{}, errors.New("value cannot be empty")
+	}
+
+	// Edit a copy, so a failed save leaves the cached provider untouched
+	edited := *provider
+
+	switch field {
+	case "name":
+		edited.ShortName = value
+	case "flag":
+		edited.Flag = value
+	case "cc":
+		edited.CountryCode = strings.ToUpper(value)
+	default:
+		return Provider{}, fmt.Errorf("unknown field '%s'", field)
+	}
+
+	if registry.db != nil {
+		if err := registry.db.Conn.Save(&edited).Error; err != nil {
+			return Provider{}, err
+		}
+	}
+
+	registry.providers[id] = &edited
+	return edited, nil
 }
 
-// List of available countries (EU is effectively a faux-country)
-var CountryCodes = []string{"USA", "EU", "CHN", "RUS", "IND", "JPN", "TWN", "AUS"}
+// Returns the country codes that have providers, in keyboard order
+func (registry *ProviderRegistry) CountryCodes() []string {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	found := make(map[string]bool)
 
-var CountryCodeToName = map[string]string{
-	"USA": "USA 🇺🇸", "EU": "EU 🇪🇺", "CHN": "China 🇨🇳", "RUS": "Russia 🇷🇺",
-	"IND": "India 🇮🇳", "JPN": "Japan 🇯🇵", "TWN": "Taiwan 🇹🇼", "AUS": "Australia 🇦🇺",
+	for _, provider := range registry.providers {
+		found[provider.CountryCode] = true
+	}
+
+	ccs := []string{}
+
+	// Countries with a fixed position come first
+	for _, cc := range countryOrder {
+		if found[cc] {
+			ccs = append(ccs, cc)
+			delete(found, cc)
+		}
+	}
+
+	rest := make([]string, 0, len(found))
+
+	for cc := range found {
+		if cc != "" {
+			rest = append(rest, cc)
+		}
+	}
+
+	sort.Strings(rest)
+	ccs = append(ccs, rest...)
+
+	// Providers without a country are grouped last, under "Other"
+	if found[""] {
+		ccs = append(ccs, "")
+	}
+
+	return ccs
+}
+
+// Returns the IDs of the providers listed under a country code, ordered by ID
+func (registry *ProviderRegistry) IdsByCountryCode(cc string) []int {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	ids := []int{}
+
+	for id, provider := range registry.providers {
+		if provider.CountryCode == cc {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Ints(ids)
+	return ids
 }
 
-type LSP struct {
-	Name string
-	Flag string
-	Cc   string
+// Returns a display name for a country code, e.g. "China 🇨🇳". An empty country code is
+// the group of providers without a country.
+func CountryName(cc string) string {
+	if cc == "" {
+		return "Other 🌐"
+	}
+
+	name, ok := countryNames[cc]
+
+	if !ok {
+		name = cc
+	}
+
+	if flag := utils.CountryCodeFlag(cc); flag != "" {
+		return fmt.Sprintf("%s %s", name, flag)
+	}
+
+	return name
 }
 
 // Extend the LaunchProvider type to get a short name, if one exists
 func (provider *LaunchProvider) ShortName() string {
 	// Check if a short name exists
-	_, ok := LSPShorthands[provider.Id]
+	registered, ok := Providers.Get(provider.Id)
 
-	if ok {
-		return LSPShorthands[provider.Id].Name
+	if ok && registered.ShortName != "" {
+		return registered.ShortName
 	}
 
-	// Log long names we encounter
+	// Use the abbreviation for long names
 	if len(provider.Name) > len("Virgin Orbit") {
-		// TODO only warn once (keep track of warned LSP IDs)
-		// log.Warn().Msgf("Provider name '%s' not found in LSPShorthands, id=%d (not warning again)",
-		// 	provider.Name, provider.Id)
-
 		return provider.Abbrev
 	}
 
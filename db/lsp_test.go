@@ -0,0 +1,93 @@
+package db
+
+import (
+	"launchbot/users"
+	"testing"
+)
+
+// Tests that unknown providers are registered and persisted, and that edits survive a restart
+func TestProviderRegistry(t *testing.T) {
+	db := Database{}
+	db.Cache = &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}}
+
+	if !db.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	registry := NewProviderRegistry()
+
+	if err := registry.Load(&db); err != nil {
+		t.Fatalf("loading providers failed: %v", err)
+	}
+
+	if provider, ok := registry.Get(121); !ok || provider.ShortName != "SpaceX" || provider.CountryCode != "USA" {
+		t.Fatalf("default provider not seeded: %+v", provider)
+	}
+
+	// Known providers are not registered again
+	if registry.Register(&LaunchProvider{Id: 121, Name: "Space Exploration Technologies Corp."}) {
+		t.Error("known provider was registered again")
+	}
+
+	// Unknown providers are added under their first country
+	added := registry.Register(&LaunchProvider{
+		Id: 9001, Name: "Korea Aerospace Research Institute", Abbrev: "KARI", CountryCode: "KOR,USA",
+	})
+
+	if !added {
+		t.Fatal("unknown provider was not registered")
+	}
+
+	provider, _ := registry.Get(9001)
+
+	if provider.ShortName != "KARI" || provider.CountryCode != "KOR" || provider.Flag != "🇰🇷" {
+		t.Errorf("unexpected registered provider: %+v", provider)
+	}
+
+	// Providers without a country are grouped under "Other", after all countries
+	registry.Register(&LaunchProvider{Id: 9002, Name: "Stateless Launch Co"})
+
+	ccs := registry.CountryCodes()
+
+	if ccs[0] != "USA" || ccs[len(ccs)-2] != "KOR" || ccs[len(ccs)-1] != "" {
+		t.Errorf("unexpected country order: %v", ccs)
+	}
+
+	if ids := registry.IdsByCountryCode(""); len(ids) != 1 || ids[0] != 9002 || CountryName("") != "Other 🌐" {
+		t.Errorf("unexpected providers without a country: %v", ids)
+	}
+
+	if ids := registry.IdsByCountryCode("KOR"); len(ids) != 1 || ids[0] != 9001 {
+		t.Errorf("unexpected providers for KOR: %v", ids)
+	}
+
+	if _, err := registry.Edit(9001, "name", "KARI (Nuri)"); err != nil {
+		t.Fatalf("editing provider failed: %v", err)
+	}
+
+	if _, err := registry.Edit(9001, "color", "blue"); err == nil {
+		t.Error("editing an unknown field did not fail")
+	}
+
+	// Providers stored under the old placeholder country code are moved to "Other"
+	db.Conn.Model(&Provider{}).Where("id = ?", 9002).Update("country_code", "UNK")
+
+	// Simulate a restart by loading a fresh registry from disk
+	reloaded := NewProviderRegistry()
+
+	if err := reloaded.Load(&db); err != nil {
+		t.Fatalf("reloading providers failed: %v", err)
+	}
+
+	if provider, ok := reloaded.Get(9001); !ok || provider.ShortName != "KARI (Nuri)" {
+		t.Errorf("edited provider not persisted: %+v", provider)
+	}
+
+	if provider, _ := reloaded.Get(9002); provider.CountryCode != "" {
+		t.Errorf("expected no country code, got %s", provider.CountryCode)
+	}
+
+	if len(reloaded.All()) != len(defaultProviders)+2 {
+		t.Errorf("expected %d providers, got %d", len(defaultProviders)+2, len(reloaded.All()))
+	}
+}
@@ -43,7 +43,7 @@ type stat struct {
 	LastApiUpdate int64
 }
 
-// Extended map, as most names are not found in LSPShorthands
+// Extended map, as most names are not found in the provider registry
 var extendedLSPNameMap = map[string]int{
 	"Rocket Lab Ltd":                                     147,
 	"Antrix Corporation":                                 190,
@@ -163,10 +163,9 @@ func MigrateStatistics(oldDb *gorm.DB, newDb *db.Database) {
 
 // Maps a v2 LSP name to an LSP ID
 func MapProviderNameToId(name string) int {
-	// ID is integer, LSP is an LSP{}
-	for lspID, lsp := range db.LSPShorthands {
-		if lsp.Name == name {
-			return lspID
+	for _, provider := range db.Providers.All() {
+		if provider.ShortName == name {
+			return provider.Id
 		}
 	}
 
@@ -272,10 +271,15 @@ func MigrateUsers(oldDb *gorm.DB, newDb *db.Database) {
 		binaryStates := strings.Split(user.NotifyTimePref, ",")
 
 		// 24h -> 12h -> 1h -> 5 min (1,1,1,1)
-		newUser.Enabled24h = (binaryStates[0] == "1")
-		newUser.Enabled12h = (binaryStates[1] == "1")
-		newUser.Enabled1h = (binaryStates[2] == "1")
-		newUser.Enabled5min = (binaryStates[3] == "1")
+		leadTimes := []string{}
+
+		for i, leadTime := range []string{"1440", "720", "60", "5"} {
+			if binaryStates[i] == "1" {
+				leadTimes = append(leadTimes, leadTime)
+			}
+		}
+
+		newUser.LeadTimes = strings.Join(leadTimes, ",")
 
 		// Log any fields we will not save
 		if user.TimeZone != "" {
@@ -8,6 +8,8 @@ import (
 
 	"github.com/hako/durafmt"
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Postpone struct {
@@ -16,7 +18,8 @@ type Postpone struct {
 }
 
 type Notification struct {
-	Type       string   // In (24h, 12h, 1h, 5min, postpone)
+	Type       string   // In (24h, 12h, 1h, 5min, postpone, outcome, webcast, hold), or any other lead time's type
+	LeadTime   int      // Minutes before NET the notification is sent at, for lead time notifications
 	SendTime   int64    // Unix-time of the notification
 	AllSent    bool     // All notifications sent already?
 	LaunchId   string   // Launch ID associated
@@ -25,35 +28,49 @@ type Notification struct {
 	IsHolding  bool     // Is the launch holding?
 	Count      int      // If more than one, list their count
 	IDs        []string // If more than one, include their IDs here
+
+	// Launches sharing the send-time, grouped by lead time (most urgent first)
+	Groups []NotificationGroup
 }
 
-// Updates the notification-state map from the boolean values
-func (state *NotificationState) UpdateMap(launch *Launch) {
-	state.Map = map[string]bool{
-		"Sent24h":  state.Sent24h,
-		"Sent12h":  state.Sent12h,
-		"Sent1h":   state.Sent1h,
-		"Sent5min": state.Sent5min,
-	}
+// Launches that have a notification with the same lead time due at the same time
+type NotificationGroup struct {
+	LeadTime int
+	IDs      []string
+}
 
-	launch.NotificationState = *state
+// A sent lead time notification, stored in the notification_sends-table
+type NotificationSend struct {
+	LaunchId string `gorm:"primaryKey"`
+	LeadTime int    `gorm:"primaryKey;autoIncrement:false"` // Minutes before NET
+	SentAt   time.Time
 }
 
-// Updates the notification-state booleans from the map
-func (state *NotificationState) UpdateFlags(launch *Launch) {
-	// Update booleans
-	state.Sent24h = state.Map["Sent24h"]
-	state.Sent12h = state.Map["Sent12h"]
-	state.Sent1h = state.Map["Sent1h"]
-	state.Sent5min = state.Map["Sent5min"]
+// Returns true if the notification at this lead time has been sent
+func (state *NotificationState) IsSent(leadTime int) bool {
+	return state.Sent[leadTime]
+}
+
+// Flags the notification at this lead time as sent
+func (state *NotificationState) MarkSent(leadTime int) {
+	if state.Sent == nil {
+		state.Sent = make(map[int]bool)
+	}
+
+	state.Sent[leadTime] = true
+}
 
-	launch.NotificationState = *state
+// Initializes the sent-states of a launch that has none loaded, e.g. a new launch
+func (state *NotificationState) Init() {
+	if state.Sent == nil {
+		state.Sent = make(map[int]bool)
+	}
 }
 
 // Return a boolean, indicating whether any notifications have been sent for this launch
 func (state *NotificationState) AnyNotificationsSent() bool {
-	for _, state := range state.Map {
-		if state {
+	for _, sent := range state.Sent {
+		if sent {
 			return true
 		}
 	}
@@ -61,82 +78,208 @@ func (state *NotificationState) AnyNotificationsSent() bool {
 	return false
 }
 
-// Functions checks if a NET slip of $slip seconds resets any notification send states
-func (launch *Launch) AnyStatesResetByNetSlip(slip int64) (bool, map[string]bool) {
-	// Map notification types to seconds
-	notificationTypePreSendTime := map[string]int64{
-		"Sent24h": 24 * 3600, "Sent12h": 12 * 3600, "Sent1h": 3600, "Sent5min": 5 * 60,
+// Returns true if a final notification, sent at most FinalLeadTime minutes before NET, has been sent
+func (state *NotificationState) FinalNotificationSent() bool {
+	for leadTime, sent := range state.Sent {
+		if sent && leadTime <= users.FinalLeadTime {
+			return true
+		}
 	}
 
+	return false
+}
+
+// Functions checks if a NET slip of $slip seconds resets any notification send states at time $now.
+// The reset states are returned keyed by notification type, e.g. "24h": true.
+func (launch *Launch) AnyStatesResetByNetSlip(slip int64, now time.Time) (bool, map[string]bool) {
 	// Track states that are reset, if any
-	resetStates := map[string]bool{
-		"Sent24h": false, "Sent12h": false, "Sent1h": false, "Sent5min": false,
-	}
+	resetStates := map[string]bool{}
 
 	// Only do a disk op if a state was altered
 	stateAltered := false
 
 	// Loop over current notification send states
-	for notification, sent := range launch.NotificationState.Map {
+	for leadTime, sent := range launch.NotificationState.Sent {
 		if !sent {
 			continue
 		}
 
 		// Get time this notification window ends at
-		windowEndTime := launch.NETUnix - notificationTypePreSendTime[notification]
+		windowEndTime := launch.NETUnix - int64(leadTime)*60
 
 		// Time until window end, plus NET slip
-		windowDelta := windowEndTime - time.Now().Unix() + slip
+		windowDelta := windowEndTime - now.Unix() + slip
 
 		// Check if the NET slip puts us back before this notification window
-		if windowEndTime > time.Now().Unix()-slip {
+		if windowEndTime > now.Unix()-slip {
 			// Launch was postponed: flip the notification state
-			launch.NotificationState.Map[notification] = false
+			launch.NotificationState.Sent[leadTime] = false
 
 			// Record the state being reset
-			resetStates[notification] = true
+			resetStates[users.LeadTimeType(leadTime)] = true
 			stateAltered = true
 
 			log.Debug().Msgf("Launch had its notification=%s reset with delta of %s (launch=%s)",
-				notification, durafmt.ParseShort(time.Duration(windowDelta)*time.Second), launch.Id)
+				users.LeadTimeType(leadTime), durafmt.ParseShort(time.Duration(windowDelta)*time.Second), launch.Id)
+		}
+	}
+
+	return stateAltered, resetStates
+}
+
+// Saves the lead time send states of the launches. Newly sent notifications are inserted,
+// keeping the send time of notifications already stored, and states that were reset are
+// deleted. Launches without loaded states (e.g. launches parsed from an API update) are
+// left untouched.
+func (db *Database) SaveNotificationStates(launches []*Launch) error {
+	sends := []*NotificationSend{}
+	resets := map[string][]int{}
+	now := db.Now()
+
+	for _, launch := range launches {
+		for leadTime, sent := range launch.NotificationState.Sent {
+			if sent {
+				sends = append(sends, &NotificationSend{LaunchId: launch.Id, LeadTime: leadTime, SentAt: now})
+			} else {
+				resets[launch.Id] = append(resets[launch.Id], leadTime)
+			}
+		}
+	}
+
+	if len(sends) == 0 && len(resets) == 0 {
+		return nil
+	}
+
+	return db.Conn.Transaction(func(tx *gorm.DB) error {
+		for id, leadTimes := range resets {
+			if err := tx.Where("launch_id = ? AND lead_time IN ?", id, leadTimes).Delete(&NotificationSend{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(sends) == 0 {
+			return nil
 		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&sends).Error
+	})
+}
+
+// Loads the lead time send states of the launches from the notification_sends-table
+func (db *Database) LoadNotificationStates(launches []*Launch) {
+	if len(launches) == 0 {
+		return
+	}
+
+	// Map launches by ID, so the states can be assigned to them
+	launchMap := make(map[string]*Launch, len(launches))
+	ids := make([]string, 0, len(launches))
+
+	for _, launch := range launches {
+		launchMap[launch.Id] = launch
+		ids = append(ids, launch.Id)
 	}
 
-	if stateAltered {
-		// Save updated states
-		launch.NotificationState.UpdateFlags(launch)
-		return true, resetStates
+	sends := []NotificationSend{}
+	result := db.Conn.Where("launch_id IN ?", ids).Find(&sends)
+
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("Loading notification send states failed")
+		return
 	}
 
-	return false, resetStates
+	for _, launch := range launches {
+		launch.NotificationState.Sent = make(map[int]bool)
+	}
+
+	for _, send := range sends {
+		launchMap[send.LaunchId].NotificationState.Sent[send.LeadTime] = true
+	}
 }
 
-// Gets all notification recipients for this notification
-func (launch *Launch) NotificationRecipients(db *Database, notificationType string, platform string) []*users.User {
-	// Load users from database
-	usersWithNotificationEnabled := []*users.User{}
+// Returns the lead times any chat has enabled, in descending order. The list is
+// cached until a chat is saved.
+func (db *Database) LeadTimesInUse() []int {
+	db.leadTimesMutex.Lock()
+	defer db.leadTimesMutex.Unlock()
 
-	// Map notification type to a database table name
-	tableNotifType := ""
+	if db.leadTimes != nil {
+		return db.leadTimes
+	}
 
-	if notificationType == "postpone" {
-		tableNotifType = "enabled_postpone"
-	} else {
-		tableNotifType = fmt.Sprintf("enabled%s", notificationType)
+	lists := []string{}
+	result := db.Conn.Model(&users.User{}).Distinct("lead_times").Where("lead_times <> ''").Pluck("lead_times", &lists)
+
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("Loading lead times in use failed")
+		return []int{}
 	}
 
+	db.leadTimes = users.MergeLeadTimeLists(lists)
+	return db.leadTimes
+}
+
+// Clears the cached lead times in use, e.g. after a chat changed its notification times
+func (db *Database) invalidateLeadTimes() {
+	db.leadTimesMutex.Lock()
+	db.leadTimes = nil
+	db.leadTimesMutex.Unlock()
+}
+
+// Gets all notification recipients for this notification
+func (launch *Launch) NotificationRecipients(db *Database, notificationType string, platform string) []*users.User {
 	log.Debug().Msgf("NotificationRecipients() called with notificationType=%s, platform=%s (launch=%s)",
 		notificationType, platform, launch.Slug)
 
-	// Get all chats that have this notification type enabled
-	result := db.Conn.Model(&users.User{}).Where(
-		fmt.Sprintf("%s = ? AND platform = ?", tableNotifType), 1, platform,
-	).Find(&usersWithNotificationEnabled)
+	return launch.FilterRecipients(db, db.NotificationSubscribers(notificationType, platform))
+}
 
-	if result.Error != nil {
+// Loads all chats that have a notification type enabled, before any per-launch filtering
+func (db *Database) NotificationSubscribers(notificationType string, platform string) []*users.User {
+	usersWithNotificationEnabled := []*users.User{}
+	query := db.Conn.Model(&users.User{}).Where("platform = ?", platform)
+
+	switch notificationType {
+	case "postpone", "outcome", "webcast", "hold":
+		query = query.Where(fmt.Sprintf("enabled_%s = ?", notificationType), 1)
+	default:
+		leadTime, ok := users.LeadTimeOfType(notificationType)
+
+		if !ok {
+			log.Error().Msgf("Unknown notification type=%s when loading subscribers", notificationType)
+			return usersWithNotificationEnabled
+		}
+
+		// Lead times are stored as a comma-separated list: match a whole item
+		query = query.Where("(',' || lead_times || ',') LIKE ?", fmt.Sprintf("%%,%d,%%", leadTime))
+	}
+
+	// Get all chats that have this notification type enabled
+	if result := query.Find(&usersWithNotificationEnabled); result.Error != nil {
 		log.Error().Err(result.Error).Msg("Error loading notification recipient list")
 	}
 
+	return usersWithNotificationEnabled
+}
+
+// Returns true if the launch passes all of the chat's filters: mutes, keywords, filter rules,
+// the crewed-only setting, and orbit and mission type filters
+func (launch *Launch) PassesChatFilters(user *users.User) bool {
+	if !user.ShouldReceiveLaunch(launch.FilterInfo()) {
+		return false
+	}
+
+	// Skip uncrewed flights for chats that only follow crewed flights
+	if user.CrewedOnly && !launch.IsCrewed() {
+		return false
+	}
+
+	// Skip launches filtered out by the chat's orbit and mission type filters
+	return user.PassesMissionFilters(launch.Mission.Orbit.Abbrev, launch.Mission.Type)
+}
+
+// Filters chats down to the ones that should be notified of this launch
+func (launch *Launch) FilterRecipients(db *Database, usersWithNotificationEnabled []*users.User) []*users.User {
 	// List of final recipients
 	recipients := []*users.User{}
 
@@ -147,7 +290,7 @@ func (launch *Launch) NotificationRecipients(db *Database, notificationType stri
 	// Filter all users from the list
 	for _, user := range usersWithNotificationEnabled {
 		// Check if user should receive this launch notification
-		if !user.ShouldReceiveLaunch(launch.Id, launch.LaunchProvider.Id, launch.Name, launch.Rocket.Config.Name, launch.Mission.Name) {
+		if !launch.PassesChatFilters(user) {
 			continue
 		}
 
@@ -201,3 +344,102 @@ func (launch *Launch) SaveSentNotificationIds(ids []string, db *Database) {
 		log.Debug().Msgf("Sent notification message IDs saved")
 	}
 }
+
+// Records the chats that received a final notification of the launch, from the
+// chat_id:msg_id pairs of the sent notifications
+func (launch *Launch) AddFinalNotifiedChats(sentIds []string) {
+	chats := []string{}
+
+	if launch.FinalNotifiedChats != "" {
+		chats = strings.Split(launch.FinalNotifiedChats, ",")
+	}
+
+	for _, idPair := range sentIds {
+		chatId := strings.Split(idPair, ":")[0]
+
+		if !launch.ReceivedFinalNotification(chatId) {
+			chats = append(chats, chatId)
+			launch.FinalNotifiedChats = strings.Join(chats, ",")
+		}
+	}
+}
+
+// Returns true if the chat received a final notification of the launch
+func (launch *Launch) ReceivedFinalNotification(chatId string) bool {
+	for _, id := range strings.Split(launch.FinalNotifiedChats, ",") {
+		if id != "" && id == chatId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Moves the fixed 24h/12h/1h/5min notification time settings and send states of a database
+// from before lead times into the lead_times-column and the notification_sends-table
+func (db *Database) migrateFixedNotificationTimes(leadTimes bool, sends bool) error {
+	return db.Conn.Transaction(func(tx *gorm.DB) error {
+		if leadTimes {
+			chats := []struct {
+				Id, Platform                                   string
+				Enabled24h, Enabled12h, Enabled1h, Enabled5min bool
+			}{}
+
+			if err := tx.Raw("SELECT id, platform, enabled24h, enabled12h, enabled1h, enabled5min FROM users").Scan(&chats).Error; err != nil {
+				return err
+			}
+
+			for _, chat := range chats {
+				// Lead times are stored in descending order
+				enabled := []string{}
+
+				for i, on := range []bool{chat.Enabled24h, chat.Enabled12h, chat.Enabled1h, chat.Enabled5min} {
+					if on {
+						enabled = append(enabled, []string{"1440", "720", "60", "5"}[i])
+					}
+				}
+
+				err := tx.Model(&users.User{}).Where("id = ? AND platform = ?", chat.Id, chat.Platform).
+					Update("lead_times", strings.Join(enabled, ",")).Error
+
+				if err != nil {
+					return err
+				}
+			}
+
+			log.Info().Msgf("Migrated notification times of %d chat(s) to lead times", len(chats))
+		}
+
+		if sends {
+			launches := []struct {
+				Id                                 string
+				Sent24h, Sent12h, Sent1h, Sent5min bool
+			}{}
+
+			if err := tx.Raw("SELECT id, sent24h, sent12h, sent1h, sent5min FROM launches WHERE launched = 0").Scan(&launches).Error; err != nil {
+				return err
+			}
+
+			rows := []*NotificationSend{}
+			now := db.Now()
+
+			for _, launch := range launches {
+				for leadTime, sent := range map[int]bool{1440: launch.Sent24h, 720: launch.Sent12h, 60: launch.Sent1h, 5: launch.Sent5min} {
+					if sent {
+						rows = append(rows, &NotificationSend{LaunchId: launch.Id, LeadTime: leadTime, SentAt: now})
+					}
+				}
+			}
+
+			if len(rows) != 0 {
+				if err := tx.Create(&rows).Error; err != nil {
+					return err
+				}
+			}
+
+			log.Info().Msgf("Migrated %d notification send state(s) to the notification_sends-table", len(rows))
+		}
+
+		return nil
+	})
+}
@@ -2,7 +2,9 @@ package db
 
 import (
 	"launchbot/users"
+	"launchbot/utils"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -47,10 +49,7 @@ func TestRecipientLoading(t *testing.T) {
 		Id:              "12345",
 		Platform:        "tg",
 		SubscribedTo:    "123",
-		Enabled24h:      true,
-		Enabled12h:      false,
-		Enabled1h:       false,
-		Enabled5min:     false,
+		LeadTimes:       "1440",
 		EnabledPostpone: true,
 	}
 
@@ -85,7 +84,7 @@ func TestKeywordFilteringInNotificationRecipients(t *testing.T) {
 			user: users.User{
 				Id:              "test1",
 				Platform:        "tg",
-				Enabled24h:      true,
+				LeadTimes:       "1440",
 				SubscribedAll:   true,
 				BlockedKeywords: "Starlink",
 			},
@@ -99,7 +98,7 @@ func TestKeywordFilteringInNotificationRecipients(t *testing.T) {
 			user: users.User{
 				Id:              "test2",
 				Platform:        "tg",
-				Enabled24h:      true,
+				LeadTimes:       "1440",
 				SubscribedAll:   true,
 				BlockedKeywords: "OneWeb",
 			},
@@ -113,7 +112,7 @@ func TestKeywordFilteringInNotificationRecipients(t *testing.T) {
 			user: users.User{
 				Id:              "test3",
 				Platform:        "tg",
-				Enabled24h:      true,
+				LeadTimes:       "1440",
 				SubscribedAll:   true,
 				AllowedKeywords: "Falcon",
 			},
@@ -127,7 +126,7 @@ func TestKeywordFilteringInNotificationRecipients(t *testing.T) {
 			user: users.User{
 				Id:              "test4",
 				Platform:        "tg",
-				Enabled24h:      true,
+				LeadTimes:       "1440",
 				SubscribedAll:   true,
 				AllowedKeywords: "Falcon,Dragon",
 			},
@@ -141,7 +140,7 @@ func TestKeywordFilteringInNotificationRecipients(t *testing.T) {
 			user: users.User{
 				Id:              "test5",
 				Platform:        "tg",
-				Enabled24h:      true,
+				LeadTimes:       "1440",
 				SubscribedAll:   true,
 				AllowedKeywords: "Falcon",
 				BlockedKeywords: "Starlink",
@@ -156,7 +155,7 @@ func TestKeywordFilteringInNotificationRecipients(t *testing.T) {
 			user: users.User{
 				Id:              "test6",
 				Platform:        "tg",
-				Enabled24h:      true,
+				LeadTimes:       "1440",
 				SubscribedAll:   true,
 				BlockedKeywords: "starlink",
 			},
@@ -170,7 +169,7 @@ func TestKeywordFilteringInNotificationRecipients(t *testing.T) {
 			user: users.User{
 				Id:              "test7",
 				Platform:        "tg",
-				Enabled24h:      true,
+				LeadTimes:       "1440",
 				SubscribedAll:   true,
 				AllowedKeywords: "Star",
 			},
@@ -184,7 +183,7 @@ func TestKeywordFilteringInNotificationRecipients(t *testing.T) {
 			user: users.User{
 				Id:              "test8",
 				Platform:        "tg",
-				Enabled24h:      true,
+				LeadTimes:       "1440",
 				SubscribedAll:   true,
 				BlockedKeywords: "Starlink,OneWeb,Iridium",
 			},
@@ -196,10 +195,365 @@ func TestKeywordFilteringInNotificationRecipients(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Test using ShouldReceiveLaunch which now incorporates keyword filtering
 			// We'll use a dummy launch ID and provider ID for this test
-			result := tt.user.ShouldReceiveLaunch("test-launch-id", 1, tt.launchName, tt.vehicleName, tt.missionName)
+			result := tt.user.ShouldReceiveLaunch(users.LaunchInfo{
+				Id: "test-launch-id", ProviderId: 1, Name: tt.launchName, VehicleName: tt.vehicleName, MissionName: tt.missionName,
+			})
 			if result != tt.shouldReceive {
 				t.Errorf("ShouldReceiveLaunch: expected %v, got %v", tt.shouldReceive, result)
 			}
 		})
 	}
 }
+
+func TestOutcomeNotificationMessage(t *testing.T) {
+	launch := &Launch{
+		Id:             "test-outcome",
+		Slug:           "test-outcome",
+		Name:           "Falcon Heavy | Test Mission",
+		Status:         LaunchStatus{Id: 7, Abbrev: "Partial Failure"},
+		FailReason:     "Upper stage underperformance",
+		LaunchProvider: LaunchProvider{Id: 121, Name: "SpaceX"},
+		Rocket: Rocket{
+			Config: RocketConfiguration{Name: "Falcon Heavy", FullName: "Falcon Heavy"},
+			Stages: []Launcher{
+				{Role: "Core", Serial: "B1066", LandingAttempt: false},
+				{
+					Role: "Booster", Serial: "B1064", LandingAttempt: true, LandingSuccess: true,
+					LandingType: LandingType{Abbrev: "RTLS"},
+				},
+				{
+					Role: "Booster", Serial: "B1065", LandingAttempt: true, LandingSuccess: false,
+					LandingType: LandingType{Abbrev: "RTLS"},
+				},
+			},
+		},
+	}
+
+	text, _ := launch.OutcomeNotificationMessage()
+
+	expected := []string{
+		"Launch partially failed", "Upper stage underperformance",
+		"`\\(RTLS\\)` landed", "`\\(RTLS\\)` did not land",
+	}
+
+	for _, substring := range expected {
+		if !strings.Contains(text, substring) {
+			t.Errorf("Outcome message is missing %q:\n%s", substring, text)
+		}
+	}
+
+	// Expended core should not show up in the recovery section
+	if strings.Contains(text, "B1066") {
+		t.Errorf("Outcome message should not include an expended core:\n%s", text)
+	}
+
+	// Successful launches should not include a failure reason
+	launch.Status.Id = 3
+
+	if text, _ = launch.OutcomeNotificationMessage(); strings.Contains(text, "Reason") {
+		t.Errorf("Successful outcome message should not include a failure reason:\n%s", text)
+	}
+}
+
+// Fast-forwards through a launch campaign with a fake clock, checking each notification decision
+func TestNotificationTimeline(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC))
+	net := clock.Now().Add(30 * time.Hour)
+
+	db := Database{}
+	cache := &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}, Clock: clock}
+	db.Cache = cache
+
+	if !db.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	launch := &Launch{Id: "timeline", Slug: "timeline", Name: "Timeline", Status: LaunchStatus{Abbrev: "Go"}, NETUnix: net.Unix()}
+
+	if err := db.Update([]*Launch{launch}, true, false); err != nil {
+		t.Fatalf("failed to insert launch: %v", err)
+	}
+
+	cache.UpdateWithNew([]*Launch{launch})
+
+	// A chat with the four classic lead times enabled
+	db.SaveUser(&users.User{Id: "1", Platform: "tg", SubscribedAll: true, LeadTimes: "1440,720,60,5"})
+
+	// Each notification is sent a minute before its window
+	for _, step := range []struct {
+		notifType string
+		beforeNet time.Duration
+	}{
+		{"24h", 24 * time.Hour}, {"12h", 12 * time.Hour}, {"1h", time.Hour}, {"5min", 5 * time.Minute},
+	} {
+		notification := cache.FindNextNotification()
+		expectedSendTime := net.Add(-step.beforeNet - time.Minute)
+
+		if notification.Type != step.notifType || notification.SendTime != expectedSendTime.Unix() {
+			t.Fatalf("expected type=%s at %s, got type=%s at %s", step.notifType, expectedSendTime,
+				notification.Type, time.Unix(notification.SendTime, 0).UTC())
+		}
+
+		// Fast-forward to the send time, and flag the notification as sent
+		clock.Set(expectedSendTime)
+		launch.NotificationState.MarkSent(int(step.beforeNet.Minutes()))
+
+		if step.notifType == "1h" {
+			// Half an hour later, a two-hour slip resets the 1-hour notification, but not the 12-hour one
+			clock.Advance(30 * time.Minute)
+			anyReset, resetStates := launch.AnyStatesResetByNetSlip(7200, clock.Now())
+
+			if !anyReset || !resetStates["1h"] || resetStates["12h"] {
+				t.Errorf("expected only the 1-hour notification to be reset, got %v", resetStates)
+			}
+
+			// Revert the reset: the NET did not actually move
+			launch.NotificationState.MarkSent(60)
+		}
+	}
+
+	if notification := launch.NextNotification(&db); !notification.AllSent {
+		t.Errorf("expected all notifications to be sent, got type=%s", notification.Type)
+	}
+
+	// A launch that appears half an hour before NET has its earlier notifications marked as missed
+	late := &Launch{Id: "late", Slug: "late", Name: "Late", Status: LaunchStatus{Abbrev: "Go"},
+		NETUnix: clock.Now().Add(30 * time.Minute).Unix()}
+	late.NotificationState.Init()
+
+	if notification := late.NextNotification(&db); notification.Type != "5min" {
+		t.Errorf("expected the 5-minute notification for a late launch, got type=%s", notification.Type)
+	}
+
+	if !late.NotificationState.IsSent(60) || !late.NotificationState.IsSent(1440) {
+		t.Errorf("expected missed notifications to be flagged as sent, got %+v", late.NotificationState)
+	}
+}
+
+// Tests that launches sharing a send time are grouped by lead time, and that custom lead times
+// only reach the chats that enabled them
+func TestCustomLeadTimeGroups(t *testing.T) {
+	clock := utils.NewFakeClock(time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC))
+
+	db := Database{}
+	cache := &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}, Clock: clock}
+	db.Cache = cache
+
+	if !db.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	db.SaveUser(&users.User{Id: "1", Platform: "tg", SubscribedAll: true, LeadTimes: "190,90"})
+	db.SaveUser(&users.User{Id: "2", Platform: "tg", SubscribedAll: true, LeadTimes: "1440,5"})
+
+	if leadTimes := db.LeadTimesInUse(); len(leadTimes) != 4 || leadTimes[0] != 1440 || leadTimes[3] != 5 {
+		t.Fatalf("Unexpected lead times in use: %v", leadTimes)
+	}
+
+	// The 90-minute notification of one launch is due at the same time as the 190-minute one of another
+	soon := &Launch{Id: "soon", Slug: "soon", Name: "Soon", Status: LaunchStatus{Abbrev: "Go"},
+		NETUnix: clock.Now().Add(91 * time.Minute).Unix()}
+	later := &Launch{Id: "later", Slug: "later", Name: "Later", Status: LaunchStatus{Abbrev: "Go"},
+		NETUnix: clock.Now().Add(191 * time.Minute).Unix()}
+
+	if err := db.Update([]*Launch{soon, later}, true, false); err != nil {
+		t.Fatalf("failed to insert launches: %v", err)
+	}
+
+	cache.UpdateWithNew([]*Launch{soon, later})
+	notification := cache.FindNextNotification()
+
+	if len(notification.Groups) != 2 || notification.Groups[0].LeadTime != 90 || notification.Groups[1].LeadTime != 190 {
+		t.Fatalf("Expected a 90-minute and a 190-minute group, got %+v", notification.Groups)
+	}
+
+	if notification.Groups[0].IDs[0] != "soon" || notification.Groups[1].IDs[0] != "later" {
+		t.Errorf("Launches are in the wrong groups: %+v", notification.Groups)
+	}
+
+	// Only the chat with the 90-minute notification receives it ("90" must not match "190")
+	if recipients := soon.NotificationRecipients(&db, "90min", "tg"); len(recipients) != 1 || recipients[0].Id != "1" {
+		t.Errorf("Expected only chat=1 to receive the 90-minute notification, got %d recipient(s)", len(recipients))
+	}
+
+	// Send states are persisted per lead time
+	soon.NotificationState.MarkSent(90)
+	soon.NotificationState.MarkSent(190)
+
+	if err := db.Update([]*Launch{soon}, false, false); err != nil {
+		t.Fatalf("failed to save launch: %v", err)
+	}
+
+	loaded := &Launch{Id: "soon"}
+	db.LoadNotificationStates([]*Launch{loaded})
+
+	if !loaded.NotificationState.IsSent(90) || loaded.NotificationState.IsSent(5) {
+		t.Errorf("Notification states were not persisted: %+v", loaded.NotificationState.Sent)
+	}
+}
+
+// Tests that hold notifications only go to notified chats with holds enabled
+func TestHoldNotificationSendable(t *testing.T) {
+	db := Database{}
+	db.Cache = &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}}
+
+	if !db.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	launch := &Launch{
+		Id: "hold", Slug: "hold", Name: "Falcon 9 | Starlink",
+		Status:         LaunchStatus{Id: 5, Abbrev: "Hold"},
+		HoldReason:     "Range violation (boat)",
+		LaunchProvider: LaunchProvider{Id: 121, Name: "SpaceX"},
+		// Chats 1 and 2 were notified of this launch
+		SentNotificationIds: "1:100,2:200",
+	}
+
+	// Chat 1 has holds enabled, chat 2 has them disabled, and chat 3 was never notified
+	chats := []*users.User{
+		{Id: "1", Platform: "tg", SubscribedAll: true, LeadTimes: "1440"},
+		{Id: "2", Platform: "tg", SubscribedAll: true, LeadTimes: "1440"},
+		{Id: "3", Platform: "tg", SubscribedAll: true, LeadTimes: "1440"},
+	}
+
+	for _, chat := range chats {
+		db.SaveUser(chat)
+	}
+
+	// Holds are enabled by default: disable them for chat 2
+	chats[1].SetNotificationTimeFlag("hold", false)
+	db.SaveUser(chats[1])
+
+	sendable := launch.HoldNotificationSendable(&db, false, "tg")
+
+	if len(sendable.Recipients) != 1 || sendable.Recipients[0].Id != "1" {
+		t.Errorf("expected only chat 1 as a recipient, got %d recipient(s)", len(sendable.Recipients))
+	}
+
+	if text := sendable.Message.TextContent; !strings.Contains(text, "Launch on hold") || !strings.Contains(text, "Range violation \\(boat\\)") {
+		t.Errorf("hold message is missing the header or the reason:\n%s", text)
+	}
+
+	if text, _ := launch.HoldNotificationMessage(true); !strings.Contains(text, "Launch scrubbed") {
+		t.Errorf("scrub message is missing its header:\n%s", text)
+	}
+
+	// Only status changes into a hold, or from go to an unconfirmed time, are notified
+	for _, tt := range []struct {
+		old, new    string
+		hold, scrub bool
+	}{
+		{"Go", "Hold", true, false},
+		{"Hold", "TBD", false, true},
+		{"Go", "TBC", false, true},
+		{"TBD", "TBC", false, false},
+		{"Hold", "Go", false, false},
+	} {
+		event := ChangeEvent{Type: StatusChange, Old: tt.old, New: tt.new}
+
+		if event.IsHold() != tt.hold || event.IsScrub() != tt.scrub {
+			t.Errorf("%s ➙ %s: expected hold=%v, scrub=%v", tt.old, tt.new, tt.hold, tt.scrub)
+		}
+	}
+}
+
+// Tests that outcomes only go to chats that received a final notification of the launch
+func TestOutcomeNotificationRecipients(t *testing.T) {
+	db := Database{}
+	db.Cache = &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}}
+
+	if !db.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	launch := &Launch{
+		Id: "outcome", Slug: "outcome", Name: "Falcon 9 | Starlink",
+		Status:         LaunchStatus{Id: 3, Name: "Launch Successful", Abbrev: "Success"},
+		LaunchProvider: LaunchProvider{Id: 121, Name: "SpaceX"},
+		// Chats 1, 2 and 3 were notified of this launch
+		SentNotificationIds: "1:100,2:200,3:300",
+	}
+
+	// Chats 1 and 2 received the final notification
+	launch.AddFinalNotifiedChats([]string{"1:101", "2:201"})
+	launch.AddFinalNotifiedChats([]string{"1:102"})
+
+	if launch.FinalNotifiedChats != "1,2" {
+		t.Errorf("expected chats 1 and 2 to be recorded once, got %s", launch.FinalNotifiedChats)
+	}
+
+	// Chat 2 removed its final lead time after receiving the final notification, and chat 3
+	// only received the 24h notification before enabling a final lead time
+	chats := []*users.User{
+		{Id: "1", Platform: "tg", SubscribedAll: true, LeadTimes: "1440,5", EnabledOutcome: true},
+		{Id: "2", Platform: "tg", SubscribedAll: true, LeadTimes: "1440", EnabledOutcome: true},
+		{Id: "3", Platform: "tg", SubscribedAll: true, LeadTimes: "1440,15", EnabledOutcome: true},
+		{Id: "4", Platform: "tg", SubscribedAll: true, LeadTimes: "5", EnabledOutcome: true},
+	}
+
+	for _, chat := range chats {
+		db.SaveUser(chat)
+	}
+
+	sendable := launch.OutcomeNotificationSendable(&db, "tg")
+	recipients := map[string]bool{}
+
+	for _, chat := range sendable.Recipients {
+		recipients[chat.Id] = true
+	}
+
+	if len(recipients) != 2 || !recipients["1"] || !recipients["2"] {
+		t.Errorf("expected chats 1 and 2 as recipients, got %v", recipients)
+	}
+}
+
+// Tests that saving send states keeps the send times of stored states, and only deletes reset states
+func TestSaveNotificationStates(t *testing.T) {
+	clock := utils.NewFakeClock(time.Now().Truncate(time.Second))
+
+	db := Database{}
+	db.Cache = &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}, Clock: clock}
+
+	if !db.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	launch := &Launch{Id: "states"}
+	launch.NotificationState.MarkSent(1440)
+
+	if err := db.SaveNotificationStates([]*Launch{launch}); err != nil {
+		t.Fatalf("saving states failed: %v", err)
+	}
+
+	first := clock.Now()
+
+	// A later send adds a row, without touching the earlier one
+	clock.Advance(time.Hour)
+	launch.NotificationState.MarkSent(60)
+
+	if err := db.SaveNotificationStates([]*Launch{launch}); err != nil {
+		t.Fatalf("saving states failed: %v", err)
+	}
+
+	sends := []NotificationSend{}
+	db.Conn.Order("lead_time desc").Find(&sends)
+
+	if len(sends) != 2 || !sends[0].SentAt.Equal(first) || !sends[1].SentAt.Equal(clock.Now()) {
+		t.Fatalf("unexpected send states: %+v", sends)
+	}
+
+	// A reset state is deleted, while the others are kept
+	launch.NotificationState.Sent[1440] = false
+
+	if err := db.SaveNotificationStates([]*Launch{launch}); err != nil {
+		t.Fatalf("saving states failed: %v", err)
+	}
+
+	sends = []NotificationSend{}
+	db.Conn.Find(&sends)
+
+	if len(sends) != 1 || sends[0].LeadTime != 60 {
+		t.Errorf("expected only the 1-hour state to remain, got %+v", sends)
+	}
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm/clause"
+)
+
+// A launch site (LL2 pad location), e.g. Cape Canaveral. Sites are stored as they are
+// seen in API updates, so chats can subscribe to them.
+type LaunchSite struct {
+	Id               int    `gorm:"primaryKey;autoIncrement:false"`
+	Name             string // Full name, e.g. "Cape Canaveral, FL, USA"
+	CountryCode      string
+	TotalLaunchCount int
+	UpdatedAt        time.Time
+}
+
+// Returns the site's name without the region and country, e.g. "Cape Canaveral"
+func (site *LaunchSite) ShortName() string {
+	return strings.TrimSpace(strings.Split(site.Name, ",")[0])
+}
+
+// Upserts the launch sites of the launches into the sites-table
+func (db *Database) SaveLaunchSites(launches []*Launch) error {
+	sites := []*LaunchSite{}
+	seen := make(map[int]bool)
+
+	for _, launch := range launches {
+		location := launch.LaunchPad.Location
+
+		if location.Id == 0 || seen[location.Id] {
+			continue
+		}
+
+		seen[location.Id] = true
+
+		sites = append(sites, &LaunchSite{
+			Id: location.Id, Name: location.Name, CountryCode: location.CountryCode,
+			TotalLaunchCount: location.TotalLaunchCount,
+		})
+	}
+
+	if len(sites) == 0 {
+		return nil
+	}
+
+	return db.Conn.Clauses(clause.OnConflict{UpdateAll: true}).Create(&sites).Error
+}
+
+// Loads all known launch sites, busiest first
+func (db *Database) LaunchSites() []*LaunchSite {
+	sites := []*LaunchSite{}
+	result := db.Conn.Order("total_launch_count desc, name").Find(&sites)
+
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("Loading launch sites failed")
+	}
+
+	return sites
+}
+
+// Loads a launch site by its ID
+func (db *Database) LaunchSite(id int) (*LaunchSite, bool) {
+	site := LaunchSite{}
+	result := db.Conn.Limit(1).Find(&site, "id = ?", id)
+
+	return &site, result.Error == nil && result.RowsAffected != 0
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"launchbot/users"
+	"testing"
+	"time"
+)
+
+// Tests that launch sites are stored from updates, and that chats following a site receive its launches
+func TestLaunchSiteSubscriptions(t *testing.T) {
+	db := Database{}
+	db.Cache = &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}}
+
+	if !db.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	kourou := PadLocation{Id: 3, Name: "Kourou, French Guiana", CountryCode: "GUF", TotalLaunchCount: 320}
+	cape := PadLocation{Id: 12, Name: "Cape Canaveral, FL, USA", CountryCode: "USA", TotalLaunchCount: 950}
+
+	ariane := &Launch{
+		Id: "ariane", Slug: "ariane", Name: "Ariane 6 | Galileo",
+		LaunchProvider: LaunchProvider{Id: 115, Name: "Arianespace"},
+		LaunchPad:      LaunchPad{Name: "ELA-4", Location: kourou},
+		NETUnix:        time.Now().Add(time.Hour).Unix(),
+	}
+
+	falcon := &Launch{
+		Id: "falcon", Slug: "falcon", Name: "Falcon 9 | Starlink",
+		LaunchProvider: LaunchProvider{Id: 121, Name: "SpaceX"},
+		LaunchPad:      LaunchPad{Name: "SLC-40", Location: cape},
+		NETUnix:        time.Now().Add(2 * time.Hour).Unix(),
+	}
+
+	if err := db.Update([]*Launch{ariane, falcon}, true, false); err != nil {
+		t.Fatalf("saving launches failed: %v", err)
+	}
+
+	sites := db.LaunchSites()
+
+	if len(sites) != 2 || sites[0].Id != cape.Id || sites[0].ShortName() != "Cape Canaveral" {
+		t.Fatalf("unexpected launch sites: %+v", sites)
+	}
+
+	if site, ok := db.LaunchSite(kourou.Id); !ok || site.ShortName() != "Kourou" {
+		t.Errorf("unexpected launch site for id=%d: %+v", kourou.Id, site)
+	}
+
+	// Chat follows Kourou, but no providers
+	chat := &users.User{Id: "1", Platform: "tg", LeadTimes: "1440"}
+	chat.ToggleLocationSubscription(kourou.Id, true)
+	chat.ToggleLocationSubscription(cape.Id, true)
+	chat.ToggleLocationSubscription(cape.Id, false)
+
+	if chat.SubscribedLocations != "3" {
+		t.Errorf("unexpected subscribed locations: %s", chat.SubscribedLocations)
+	}
+
+	db.SaveUser(chat)
+
+	if recipients := ariane.NotificationRecipients(&db, "24h", "tg"); len(recipients) != 1 {
+		t.Errorf("expected the chat to receive the Kourou launch, got %d recipient(s)", len(recipients))
+	}
+
+	if recipients := falcon.NotificationRecipients(&db, "24h", "tg"); len(recipients) != 0 {
+		t.Errorf("expected no recipients for the Cape Canaveral launch, got %d", len(recipients))
+	}
+
+	if db.GetSubscriberCount() != 1 {
+		t.Errorf("chat following only a launch site is not counted as a subscriber")
+	}
+}
+
+// Tests that the cached vehicles and families are listed, and that chats following them receive their launches
+func TestVehicleSubscriptions(t *testing.T) {
+	falconHeavy := RocketConfiguration{Id: 161, Name: "Falcon Heavy", Family: "Falcon"}
+	falcon9 := RocketConfiguration{Id: 164, Name: "Falcon 9", Family: "Falcon"}
+	electron := RocketConfiguration{Id: 26, Name: "Electron", Family: "Electron"}
+
+	launches := []*Launch{
+		{Id: "fh", Rocket: Rocket{Config: falconHeavy}, LaunchProvider: LaunchProvider{Id: 121}},
+		{Id: "f9-1", Rocket: Rocket{Config: falcon9}, LaunchProvider: LaunchProvider{Id: 121}},
+		{Id: "f9-2", Rocket: Rocket{Config: falcon9}, LaunchProvider: LaunchProvider{Id: 121}},
+		{Id: "electron", Rocket: Rocket{Config: electron}, LaunchProvider: LaunchProvider{Id: 147}},
+	}
+
+	cache := &Cache{Launches: launches, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}}
+
+	vehicles := cache.Vehicles()
+
+	if len(vehicles) != 3 || vehicles[0].Name != "Electron" || vehicles[2].Name != "Falcon Heavy" {
+		t.Errorf("unexpected vehicles: %+v", vehicles)
+	}
+
+	if families := cache.RocketFamilies(); len(families) != 2 || families[0] != "Electron" || families[1] != "Falcon" {
+		t.Errorf("unexpected families: %v", families)
+	}
+
+	// Every Falcon Heavy and Electron, but not every Falcon 9
+	chat := &users.User{Id: "1"}
+	chat.ToggleVehicleSubscription(falconHeavy.Id, true)
+	chat.ToggleFamilySubscription("Electron", true)
+
+	subscribed := cache.LaunchesUserHasSubscribedTo(chat)
+
+	if len(subscribed) != 2 || subscribed[0].Id != "fh" || subscribed[1].Id != "electron" {
+		t.Errorf("unexpected subscribed launches: %d", len(subscribed))
+	}
+
+	// Following the family includes every vehicle in it
+	chat.ToggleFamilySubscription("Falcon", true)
+
+	if subscribed := cache.LaunchesUserHasSubscribedTo(chat); len(subscribed) != 4 {
+		t.Errorf("expected all launches after following the Falcon family, got %d", len(subscribed))
+	}
+}
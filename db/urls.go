@@ -0,0 +1,91 @@
+package db
+
+import (
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// Replaces the stored info and video URLs of the launches with their current URLs
+func (db *Database) SaveContentURLs(launches []*Launch) error {
+	ids := make([]string, 0, len(launches))
+	urls := []*ContentURL{}
+
+	// Adds the URLs of a single kind into the list of URLs to insert
+	add := func(launch *Launch, kind string, contentUrls []ContentURL) {
+		for i := range contentUrls {
+			url := &contentUrls[i]
+
+			// URLs are re-inserted on each update, so old row IDs are not kept
+			url.Id = 0
+			url.LaunchId = launch.Id
+			url.Kind = kind
+
+			urls = append(urls, url)
+		}
+	}
+
+	for _, launch := range launches {
+		ids = append(ids, launch.Id)
+		add(launch, "info", launch.InfoURL)
+		add(launch, "video", launch.VidURL)
+	}
+
+	return db.Conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("launch_id IN ?", ids).Delete(&ContentURL{}).Error; err != nil {
+			return err
+		}
+
+		if len(urls) == 0 {
+			return nil
+		}
+
+		return tx.Create(&urls).Error
+	})
+}
+
+// Loads the info and video URLs of the launches from the content_urls-table
+func (db *Database) LoadContentURLs(launches []*Launch) {
+	if len(launches) == 0 {
+		return
+	}
+
+	// Map launches by ID, so the URLs can be assigned to them
+	launchMap := make(map[string]*Launch, len(launches))
+	ids := make([]string, 0, len(launches))
+
+	for _, launch := range launches {
+		launchMap[launch.Id] = launch
+		ids = append(ids, launch.Id)
+	}
+
+	urls := []ContentURL{}
+	result := db.Conn.Where("launch_id IN ?", ids).Order("launch_id, id").Find(&urls)
+
+	if result.Error != nil {
+		log.Error().Err(result.Error).Msg("Loading content URLs failed")
+		return
+	}
+
+	for _, launch := range launches {
+		launch.InfoURL = []ContentURL{}
+		launch.VidURL = []ContentURL{}
+	}
+
+	for _, url := range urls {
+		launch := launchMap[url.LaunchId]
+
+		switch url.Kind {
+		case "info":
+			launch.InfoURL = append(launch.InfoURL, url)
+		case "video":
+			launch.VidURL = append(launch.VidURL, url)
+		}
+	}
+}
+
+// Loads all launch data stored outside the launches-table
+func (db *Database) LoadLaunchRelations(launches []*Launch) {
+	db.LoadLaunchers(launches)
+	db.LoadContentURLs(launches)
+	db.LoadNotificationStates(launches)
+}
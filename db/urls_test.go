@@ -0,0 +1,161 @@
+package db
+
+import (
+	"launchbot/sendables"
+	"launchbot/users"
+	"strings"
+	"testing"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// Tests that info and video URLs survive a restart, and that all streams are listed
+func TestContentURLs(t *testing.T) {
+	db := Database{}
+	db.Cache = &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}}
+
+	if !db.Open(t.TempDir()) {
+		t.Fatal("Failed to open database")
+	}
+
+	launch := &Launch{
+		Id: "streams", Slug: "streams", Name: "Falcon 9 | Starlink",
+		NETUnix: time.Now().Add(time.Hour).Unix(),
+		InfoURL: []ContentURL{{Priority: 10, Title: "Mission page", Url: "https://example.com/info"}},
+		VidURL: []ContentURL{
+			{Priority: 20, Title: "Everyday Astronaut", Url: "https://example.com/ea", Language: ContentLanguage{Name: "English", Code: "en"},
+				Type: ContentType{Name: "Unofficial Webcast"}},
+			{Priority: 10, Title: "SpaceX", Url: "https://example.com/spacex", Language: ContentLanguage{Name: "English", Code: "en"},
+				Publisher: "SpaceX"},
+			{Priority: 30, Title: "Tim Dodd (DE)", Url: "https://example.com/de", Language: ContentLanguage{Name: "German", Code: "de"}},
+		},
+	}
+
+	if err := db.Update([]*Launch{launch}, true, false); err != nil {
+		t.Fatalf("saving launch failed: %v", err)
+	}
+
+	// Simulate a restart by populating a fresh cache from disk
+	db.Cache = &Cache{Database: &db, LaunchMap: make(map[string]*Launch), Users: &users.UserCache{}}
+	db.Cache.Populate()
+
+	loaded, ok := db.Cache.LaunchMap["streams"]
+
+	if !ok {
+		t.Fatal("launch not found in the populated cache")
+	}
+
+	if len(loaded.InfoURL) != 1 || len(loaded.VidURL) != 3 {
+		t.Fatalf("expected 1 info URL and 3 video URLs, got %d and %d", len(loaded.InfoURL), len(loaded.VidURL))
+	}
+
+	if loaded.VidURL[2].Language.Code != "de" {
+		t.Errorf("expected stream language to be loaded, got %#v", loaded.VidURL[2])
+	}
+
+	// Streams are listed in priority order, with their languages
+	streams := loaded.StreamList()
+	spacex, ea := strings.Index(streams, "SpaceX"), strings.Index(streams, "Everyday Astronaut")
+
+	if spacex == -1 || ea == -1 || spacex > ea {
+		t.Errorf("expected streams in priority order:\n%s", streams)
+	}
+
+	if !strings.Contains(streams, "[Tim Dodd \\(DE\\)](https://example.com/de) \\(German\\)") {
+		t.Errorf("expected stream title, link and language:\n%s", streams)
+	}
+
+	// The expanded notification lists every stream, the regular one only the highest-priority link
+	if expanded := loaded.NotificationMessage("24h", true, "launchbot", time.Now()); !strings.Contains(expanded, "example.com/de") {
+		t.Errorf("expanded notification is missing streams:\n%s", expanded)
+	}
+
+	if regular := loaded.NotificationMessage("24h", false, "launchbot", time.Now()); strings.Contains(regular, "Live streams") {
+		t.Errorf("regular notification should not list streams:\n%s", regular)
+	}
+}
+
+// Tests that each recipient is sent the webcast in their preferred language
+func TestPreferredWebcast(t *testing.T) {
+	launch := &Launch{
+		Id: "streams", Slug: "streams", Name: "Falcon 9 | Starlink",
+		LaunchProvider: LaunchProvider{Name: "SpaceX"},
+		WebcastLink:    "https://example.com/spacex",
+		VidURL: []ContentURL{
+			{Priority: 20, Title: "Everyday Astronaut", Url: "https://example.com/ea", Language: ContentLanguage{Name: "English", Code: "en"},
+				Type: ContentType{Name: "Unofficial Webcast"}},
+			{Priority: 10, Title: "SpaceX", Url: "https://example.com/spacex", Language: ContentLanguage{Name: "English", Code: "en"},
+				Publisher: "SpaceX"},
+			{Priority: 30, Title: "Tim Dodd (DE)", Url: "https://example.com/de", Language: ContentLanguage{Name: "German", Code: "de"}},
+		},
+	}
+
+	text := launch.NotificationMessage("1h", false, "", time.Now())
+	kb := [][]tb.InlineButton{{{Text: "🔇 Mute launch"}}, {{Text: "🔴 Watch live", URL: launch.WebcastLink}}}
+	opts := tb.SendOptions{ReplyMarkup: &tb.ReplyMarkup{InlineKeyboard: kb}}
+
+	message := &sendables.Message{
+		TextContent: text, SendOptions: opts,
+		WebcastLink: launch.WebcastLink, Webcasts: launch.Webcasts(),
+	}
+
+	// German is preferred over English, and the webcast exists
+	german := &users.User{Id: "1"}
+	german.ToggleWebcastLanguage("fi", true)
+	german.ToggleWebcastLanguage("de", true)
+	german.ToggleWebcastLanguage("en", true)
+
+	germanText, germanOpts := sendables.SetPreferredWebcast(text, opts, message, german)
+
+	if !strings.Contains(germanText, "(https://example.com/de)") || strings.Contains(germanText, "spacex") {
+		t.Errorf("text does not link to the german webcast:\n%s", germanText)
+	}
+
+	if url := germanOpts.ReplyMarkup.InlineKeyboard[1][0].URL; url != "https://example.com/de" {
+		t.Errorf("keyboard links to %s instead of the german webcast", url)
+	}
+
+	// The shared keyboard must not be modified
+	if kb[1][0].URL != launch.WebcastLink {
+		t.Errorf("shared keyboard was modified: %s", kb[1][0].URL)
+	}
+
+	// No matching language falls back to the default link
+	finnish := &users.User{Id: "2", WebcastLanguages: "fi"}
+	finnishText, finnishOpts := sendables.SetPreferredWebcast(text, opts, message, finnish)
+
+	if finnishText != text || finnishOpts.ReplyMarkup.InlineKeyboard[1][0].URL != launch.WebcastLink {
+		t.Error("chat without a matching webcast did not fall back to the default link")
+	}
+
+	// The highest-priority webcast of a language is used
+	if preferred := message.PreferredWebcast([]string{"en"}, users.WebcastAnySource); preferred != "https://example.com/spacex" {
+		t.Errorf("expected the highest-priority english webcast, got %s", preferred)
+	}
+
+	// Third-party streams are preferred over the official webcast, within the language
+	if preferred := message.PreferredWebcast([]string{"en"}, users.WebcastThirdParty); preferred != "https://example.com/ea" {
+		t.Errorf("expected the english third-party stream, got %s", preferred)
+	}
+
+	// Without a language preference, the source alone picks the webcast
+	thirdParty := &users.User{Id: "3"}
+	thirdParty.SetWebcastSource(users.WebcastThirdParty)
+
+	if text, _ := sendables.SetPreferredWebcast(text, opts, message, thirdParty); !strings.Contains(text, "(https://example.com/ea)") {
+		t.Errorf("text does not link to the third-party stream:\n%s", text)
+	}
+
+	// Without a stream from the preferred source in the language, any source is used
+	if preferred := message.PreferredWebcast([]string{"de"}, users.WebcastOfficial); preferred != "https://example.com/de" {
+		t.Errorf("expected the german stream, got %s", preferred)
+	}
+
+	// Disabling a language removes it from the preferences
+	german.ToggleWebcastLanguage("de", false)
+
+	if german.WebcastLanguages != "fi,en" {
+		t.Errorf("unexpected preferences after disabling german: %s", german.WebcastLanguages)
+	}
+}
@@ -226,6 +226,9 @@ func (db *Database) SaveUser(user *users.User) {
 	if err != nil {
 		log.Error().Err(err).Msgf("Saving user=%s failed (SaveUser)", user.Id)
 	}
+
+	// The chat's notification times may have changed
+	db.invalidateLeadTimes()
 }
 
 // Save a batch of users to disk
@@ -252,6 +255,7 @@ func (db *Database) SaveUserBatch(users []*users.User) {
 	}
 
 	log.Debug().Msgf("Saved a batch of %d user(s) with %d error(s)", len(users), failCount)
+	db.invalidateLeadTimes()
 }
 
 // Remove a user from the database
@@ -273,6 +277,10 @@ func (db *Database) RemoveUser(user *users.User) {
 
 	// Flush user from the cache so it doesn't linger around
 	db.Cache.FlushUser(user.Id, user.Platform)
+	db.invalidateLeadTimes()
+
+	// Drop any notifications held back during the chat's quiet hours
+	db.removeChatDeferredNotifications(user)
 }
 
 // Migrate a chat to its new id
@@ -0,0 +1,3 @@
+// Package e2e contains offline end-to-end tests, running the bot against a fake
+// Telegram Bot API server and a fake LL2 API server.
+package e2e
@@ -0,0 +1,565 @@
+package e2e
+
+import (
+	"encoding/json"
+	"io"
+	"launchbot/api"
+	"launchbot/bots/telegram/telegramtest"
+	"launchbot/db"
+	"launchbot/users"
+	"launchbot/web"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that /next replies with the next launch, and that its buttons edit the message
+func TestNextCommand(t *testing.T) {
+	h := newHarness(t)
+	h.addChat(1001)
+
+	h.ll2.SetLaunches(
+		launch("first", "Starlink", h.clock.Now().Add(48*time.Hour)),
+		launch("second", "Transporter", h.clock.Now().Add(72*time.Hour)),
+	)
+	h.update()
+
+	h.command(1001, "/next")
+
+	reply := h.waitFor("sendMessage", 1001, nil)
+
+	if !strings.Contains(reply.Params["text"], "Starlink") {
+		t.Errorf("expected /next to show the first launch, got %s", reply.Params["text"])
+	}
+
+	// Move to the next launch with the inline keyboard
+	h.callback(1001, reply.MessageId, "next", "n/1/+")
+
+	edit := h.waitFor("editMessageText", 1001, nil)
+
+	if !strings.Contains(edit.Params["text"], "Transporter") {
+		t.Errorf("expected the next-button to show the second launch, got %s", edit.Params["text"])
+	}
+}
+
+// Tests that /settings replies with the settings menu, and that toggles are saved
+func TestSettingsCallbacks(t *testing.T) {
+	h := newHarness(t)
+	h.addChat(1002)
+
+	h.command(1002, "/settings")
+	reply := h.waitFor("sendMessage", 1002, nil)
+
+	if !strings.Contains(reply.Params["reply_markup"], "sub/times") {
+		t.Errorf("expected the settings menu to link to notification times, got %s", reply.Params["reply_markup"])
+	}
+
+	// Open the notification time settings, and disable outcome notifications
+	h.callback(1002, reply.MessageId, "settings", "sub/times")
+	h.waitFor("editMessageText", 1002, nil)
+
+	h.callback(1002, reply.MessageId, "notificationToggle", "time/outcome/0")
+	h.waitFor("editMessageReplyMarkup", 1002, nil)
+
+	_, err := h.telegram.WaitFor("answerCallbackQuery", 10*time.Second, func(call telegramtest.Call) bool {
+		return strings.Contains(call.Params["text"], "outcome")
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.session.Cache.FindUser("1002", "tg").EnabledOutcome {
+		t.Errorf("expected outcome notifications to be disabled")
+	}
+}
+
+// Fast-forwards through a launch, checking that scheduled notifications are
+// delivered, and that the previous notification is deleted when the next one is sent
+func TestScheduledNotifications(t *testing.T) {
+	h := newHarness(t)
+	h.addChat(1003)
+
+	// The 24-hour notification is sent a minute before its window: have it come up in two seconds
+	net := h.clock.Now().Add(24*time.Hour + time.Minute + 2*time.Second)
+	h.ll2.SetLaunches(launch("campaign", "Starlink", net))
+	h.update()
+
+	if !h.session.Scheduler.IsRunning() {
+		t.Fatal("scheduler is not running")
+	}
+
+	h.scheduleNext()
+	first := h.waitFor("sendMessage", 1003, func(call telegramtest.Call) bool {
+		return strings.Contains(call.Params["text"], "24 hours")
+	})
+
+	// Fast-forward to two seconds before the 5-minute notification: the 12- and 1-hour ones are disabled
+	h.clock.Set(net.Add(-5*time.Minute - time.Minute - 2*time.Second))
+	h.scheduleNext()
+
+	h.waitFor("sendMessage", 1003, func(call telegramtest.Call) bool {
+		return call.MessageId != first.MessageId
+	})
+
+	// Sending the 5-minute notification removes the 24-hour one
+	firstId := strconv.Itoa(first.MessageId)
+
+	h.waitFor("deleteMessages", 1003, func(call telegramtest.Call) bool {
+		return strings.Contains(call.Params["message_ids"], firstId)
+	})
+}
+
+// Tests that a throttled request budget never holds up notifications
+func TestThrottledNotification(t *testing.T) {
+	h := newHarness(t)
+	h.addChat(1013)
+
+	net := h.clock.Now().Add(24*time.Hour + 11*time.Minute + 2*time.Second)
+	h.ll2.SetLaunches(launch("throttled", "Starlink", net))
+	h.update()
+
+	// The data is due a refresh, but LL2 blocks requests for a minute: the notification is sent with the cached data
+	h.clock.Advance(10 * time.Minute)
+	api.InitializeBudget(h.session).Throttle(time.Minute)
+	requests := h.ll2.Requests()
+
+	h.scheduleNext()
+	h.waitFor("sendMessage", 1013, func(call telegramtest.Call) bool {
+		return strings.Contains(call.Params["text"], "24 hours")
+	})
+
+	if h.ll2.Requests() != requests {
+		t.Errorf("expected no requests while throttled, got %d", h.ll2.Requests()-requests)
+	}
+}
+
+// Tests that data updated moments ago is not refreshed before notifications
+func TestRecentDataNotRefreshed(t *testing.T) {
+	h := newHarness(t)
+	h.addChat(1014)
+
+	net := h.clock.Now().Add(24*time.Hour + time.Minute + 2*time.Second)
+	h.ll2.SetLaunches(launch("fresh", "Starlink", net))
+	h.update()
+
+	requests := h.ll2.Requests()
+
+	h.scheduleNext()
+	h.waitFor("sendMessage", 1014, func(call telegramtest.Call) bool {
+		return strings.Contains(call.Params["text"], "24 hours")
+	})
+
+	if h.ll2.Requests() != requests {
+		t.Errorf("expected no refresh of fresh data, got %d request(s)", h.ll2.Requests()-requests)
+	}
+}
+
+// Tests that a webcast going live is notified once, and only to chats that opted in
+func TestWebcastNotification(t *testing.T) {
+	h := newHarness(t)
+	h.addChat(1004)
+
+	subscriber := h.addChat(1005)
+	subscriber.EnabledWebcast = true
+	h.session.Db.SaveUser(subscriber)
+
+	net := h.clock.Now().Add(30 * time.Minute)
+
+	webcast := func(live bool) *db.Launch {
+		l := launch("webcast", "Starlink", net)
+		l.WebcastIsLive = live
+		l.VidURL = []db.ContentURL{{Priority: 10, Url: "https://example.com/live"}}
+		return l
+	}
+
+	h.ll2.SetLaunches(webcast(false))
+	h.update()
+
+	// Webcast goes live between updates
+	h.ll2.SetLaunches(webcast(true))
+	h.update()
+
+	notification := h.waitFor("sendMessage", 1005, func(call telegramtest.Call) bool {
+		return strings.Contains(call.Params["text"], "Webcast is live")
+	})
+
+	if !strings.Contains(notification.Params["reply_markup"], "https://example.com/live") {
+		t.Errorf("expected a link to the webcast, got %s", notification.Params["reply_markup"])
+	}
+
+	// Another update with the webcast still live must not notify again
+	h.update()
+	time.Sleep(500 * time.Millisecond)
+
+	if calls := h.telegram.Calls("sendMessage"); len(calls) != 1 {
+		t.Errorf("expected exactly one notification, got %d", len(calls))
+	}
+
+	if !h.session.Cache.LaunchMap["webcast"].NotificationState.SentWebcast {
+		t.Errorf("expected the webcast notification to be flagged as sent")
+	}
+}
+
+// Tests that notifications are held back during quiet hours, and delivered according
+// to each chat's policy once quiet hours end
+func TestQuietHours(t *testing.T) {
+	h := newHarness(t)
+	quietHours := h.clock.Now().UTC().Add(-time.Hour).Format("15:04") + "-" + h.clock.Now().UTC().Add(time.Hour).Format("15:04")
+
+	// One chat has its notifications held, and one has them summarized
+	for id, policy := range map[int64]users.QuietPolicy{1006: users.QuietHold, 1007: users.QuietSummary} {
+		chat := h.addChat(id)
+		chat.EnabledWebcast = true
+		chat.QuietPolicy = policy
+
+		if err := chat.SetQuietHours(quietHours); err != nil {
+			t.Fatal(err)
+		}
+
+		h.session.Db.SaveUser(chat)
+	}
+
+	webcast := func(live bool) *db.Launch {
+		l := launch("quiet", "Starlink", h.clock.Now().Add(30*time.Minute))
+		l.WebcastIsLive = live
+		l.VidURL = []db.ContentURL{{Priority: 10, Url: "https://example.com/live"}}
+		return l
+	}
+
+	h.ll2.SetLaunches(webcast(false))
+	h.update()
+
+	h.ll2.SetLaunches(webcast(true))
+	h.update()
+	time.Sleep(500 * time.Millisecond)
+
+	if calls := h.telegram.Calls("sendMessage"); len(calls) != 0 {
+		t.Fatalf("expected no notifications during quiet hours, got %d", len(calls))
+	}
+
+	// Quiet hours end
+	h.clock.Set(h.clock.Now().Add(2 * time.Hour))
+	h.session.Telegram.DeliverQuietHourNotifications()
+
+	held := h.waitFor("sendMessage", 1006, nil)
+
+	if !strings.Contains(held.Params["text"], "Held back") || !strings.Contains(held.Params["text"], "Webcast is live") {
+		t.Errorf("expected the held webcast notification, got %s", held.Params["text"])
+	}
+
+	summary := h.waitFor("sendMessage", 1007, nil)
+
+	if !strings.Contains(summary.Params["text"], "Quiet hours summary") || !strings.Contains(summary.Params["text"], "webcast went live") {
+		t.Errorf("expected a summary of the held notifications, got %s", summary.Params["text"])
+	}
+
+	// Held notifications are only delivered once
+	h.session.Telegram.DeliverQuietHourNotifications()
+	time.Sleep(250 * time.Millisecond)
+
+	if calls := h.telegram.Calls("sendMessage"); len(calls) != 2 {
+		t.Errorf("expected exactly two messages after quiet hours, got %d", len(calls))
+	}
+}
+
+// Tests that a held lead time notification whose launch slipped during quiet hours is summarized, not sent as is
+func TestQuietHoursStaleNotification(t *testing.T) {
+	h := newHarness(t)
+	quietHours := h.clock.Now().UTC().Add(-time.Hour).Format("15:04") + "-" + h.clock.Now().UTC().Add(time.Hour).Format("15:04")
+
+	chat := h.addChat(1015)
+	chat.QuietPolicy = users.QuietHold
+
+	if err := chat.SetQuietHours(quietHours); err != nil {
+		t.Fatal(err)
+	}
+
+	h.session.Db.SaveUser(chat)
+
+	net := h.clock.Now().Add(24*time.Hour + time.Minute + 2*time.Second)
+	h.ll2.SetLaunches(launch("slipped", "Starlink", net))
+	h.update()
+
+	// Wait for the 24-hour notification to be held
+	h.scheduleNext()
+
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(100 * time.Millisecond) {
+		if held, _ := h.session.Db.DueDeferredNotifications(h.clock.Now().Add(2 * time.Hour)); len(held) != 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("expected the 24-hour notification to be held")
+		}
+	}
+
+	if calls := h.telegram.Calls("sendMessage"); len(calls) != 0 {
+		t.Fatalf("expected no notifications during quiet hours, got %d", len(calls))
+	}
+
+	// The launch slips by a day before quiet hours end
+	h.ll2.SetLaunches(launch("slipped", "Starlink", net.Add(24*time.Hour)))
+	h.update()
+
+	h.clock.Set(h.clock.Now().Add(2 * time.Hour))
+	h.session.Telegram.DeliverQuietHourNotifications()
+
+	h.waitFor("sendMessage", 1015, func(call telegramtest.Call) bool {
+		return strings.Contains(call.Params["text"], "Quiet hours summary")
+	})
+
+	time.Sleep(250 * time.Millisecond)
+
+	for _, call := range h.telegram.Calls("sendMessage") {
+		if strings.Contains(call.Params["text"], "24 hours") {
+			t.Errorf("expected the stale 24-hour notification not to be sent, got %s", call.Params["text"])
+		}
+	}
+}
+
+// Tests that a final notification held during quiet hours is followed by the launch's outcome
+func TestQuietHoursOutcome(t *testing.T) {
+	h := newHarness(t)
+
+	// Quiet hours end in a minute or two, before the launch
+	quietHours := h.clock.Now().UTC().Add(-time.Hour).Format("15:04") + "-" + h.clock.Now().UTC().Add(2*time.Minute).Format("15:04")
+
+	chat := h.addChat(1016)
+	chat.LeadTimes = "5"
+	chat.QuietPolicy = users.QuietHold
+
+	if err := chat.SetQuietHours(quietHours); err != nil {
+		t.Fatal(err)
+	}
+
+	h.session.Db.SaveUser(chat)
+
+	// The 5-minute notification is sent a minute before its window: have it come up in two seconds
+	net := h.clock.Now().Add(5*time.Minute + time.Minute + 2*time.Second)
+	h.ll2.SetLaunches(launch("held", "Starlink", net))
+	h.update()
+	h.scheduleNext()
+
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(100 * time.Millisecond) {
+		if held, _ := h.session.Db.DueDeferredNotifications(h.clock.Now().Add(time.Hour)); len(held) != 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("expected the 5-minute notification to be held")
+		}
+	}
+
+	// Quiet hours end before the launch: the held notification is delivered
+	h.clock.Set(h.clock.Now().Add(3 * time.Minute))
+	h.session.Telegram.DeliverQuietHourNotifications()
+
+	held := h.waitFor("sendMessage", 1016, func(call telegramtest.Call) bool {
+		return strings.Contains(call.Params["text"], "Held back")
+	})
+
+	cached := h.session.Cache.LaunchMap["held"]
+
+	if !strings.Contains(cached.SentNotificationIds, "1016:"+strconv.Itoa(held.MessageId)) || !cached.ReceivedFinalNotification("1016") {
+		t.Fatalf("expected the held notification to be saved as sent, got ids=%s", cached.SentNotificationIds)
+	}
+
+	// The launch succeeds: the chat is sent the outcome
+	h.clock.Set(net.Add(10 * time.Minute))
+
+	success := launch("held", "Starlink", net)
+	success.Status = db.LaunchStatus{Id: 3, Name: "Launch Successful", Abbrev: "Success"}
+	h.ll2.SetLaunches(success)
+	h.update()
+
+	h.waitFor("sendMessage", 1016, func(call telegramtest.Call) bool {
+		return strings.Contains(call.Params["text"], "Launch successful")
+	})
+}
+
+// Tests that a daily digest lists the launches the chat follows in the coming day
+func TestDailyDigest(t *testing.T) {
+	h := newHarness(t)
+
+	chat := h.addChat(1008)
+	chat.SetDigestFrequency(users.DigestDaily)
+	chat.SetDigestHour(h.clock.Now().UTC().Hour())
+	h.session.Db.SaveUser(chat)
+
+	h.ll2.SetLaunches(
+		launch("soon", "Starlink", h.clock.Now().Add(6*time.Hour)),
+		launch("later", "Transporter", h.clock.Now().Add(72*time.Hour)),
+	)
+	h.update()
+
+	api.SendDigests(h.session, "")
+
+	digest := h.waitFor("sendMessage", 1008, nil)
+	text := digest.Params["text"]
+
+	if !strings.Contains(text, "daily launch digest") || !strings.Contains(text, "Starlink") {
+		t.Errorf("expected a digest listing the coming launch, got %s", text)
+	}
+
+	if strings.Contains(text, "Transporter") {
+		t.Errorf("expected launches past the coming day to be left out, got %s", text)
+	}
+}
+
+// Tests that /calendar sends the subscribed launches as an iCalendar file, and that a
+// launch keeps its UID in a new export after it slips
+func TestCalendarExport(t *testing.T) {
+	h := newHarness(t)
+	h.addChat(1009)
+
+	net := h.clock.Now().Add(48 * time.Hour).UTC().Truncate(time.Minute)
+	h.ll2.SetLaunches(launch("slipping", "Starlink", net))
+	h.update()
+
+	h.command(1009, "/calendar")
+	export := h.waitFor("sendDocument", 1009, nil)
+
+	if export.Params["file_name"] != "launches.ics" || !strings.Contains(export.Files["document"], "UID:slipping@launchbot") {
+		t.Fatalf("expected a calendar export of the launch, got %v", export)
+	}
+
+	// The launch slips by a day: a new export has the same event, at the new time
+	h.ll2.SetLaunches(launch("slipping", "Starlink", net.Add(24*time.Hour)))
+	h.update()
+
+	h.command(1009, "/calendar")
+	updated := h.waitFor("sendDocument", 1009, func(call telegramtest.Call) bool {
+		return call.MessageId != export.MessageId
+	})
+
+	if !strings.Contains(updated.Files["document"], "UID:slipping@launchbot") ||
+		!strings.Contains(updated.Files["document"], "DTSTART:"+net.Add(24*time.Hour).Format("20060102T150405Z")) {
+		t.Errorf("expected the slipped launch at its new time, got %s", updated.Files["document"])
+	}
+}
+
+// Tests that a chat's calendar feed applies its filters, follows launches as they move,
+// and stops working once its token is revoked
+func TestCalendarFeed(t *testing.T) {
+	h := newHarness(t)
+	feed := httptest.NewServer(web.NewServer(h.session).Handler())
+	defer feed.Close()
+
+	chat := h.addChat(1010)
+	chat.BlockedKeywords = "transporter"
+	chat.ToggleLaunchMute("muted", true)
+
+	if err := chat.GenerateCalendarToken(); err != nil {
+		t.Fatal(err)
+	}
+
+	h.session.Db.SaveUser(chat)
+
+	net := h.clock.Now().Add(48 * time.Hour).UTC().Truncate(time.Minute)
+
+	h.ll2.SetLaunches(
+		launch("followed", "Starlink", net),
+		launch("blocked", "Transporter", net.Add(time.Hour)),
+		launch("muted", "Bandwagon", net.Add(2*time.Hour)),
+	)
+	h.update()
+
+	get := func(token string) (int, string) {
+		response, err := http.Get(feed.URL + "/calendar/" + token + ".ics")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+
+		return response.StatusCode, string(body)
+	}
+
+	status, calendar := get(chat.CalendarToken)
+
+	if status != http.StatusOK || !strings.Contains(calendar, "UID:followed@launchbot") {
+		t.Fatalf("expected the feed to list the followed launch, got %d: %s", status, calendar)
+	}
+
+	if strings.Contains(calendar, "UID:blocked@launchbot") || strings.Contains(calendar, "UID:muted@launchbot") {
+		t.Errorf("expected blocked and muted launches to be left out, got %s", calendar)
+	}
+
+	// The launch slips: the feed follows without any action from the chat
+	h.ll2.SetLaunches(launch("followed", "Starlink", net.Add(24*time.Hour)))
+	h.update()
+
+	if _, calendar := get(chat.CalendarToken); !strings.Contains(calendar, "DTSTART:"+net.Add(24*time.Hour).Format("20060102T150405Z")) {
+		t.Errorf("expected the feed to have the launch at its new time, got %s", calendar)
+	} else if !strings.Contains(calendar, "SEQUENCE:1\r\n") {
+		t.Errorf("expected the moved launch's sequence to increase, got %s", calendar)
+	}
+
+	// Unknown and revoked tokens have no feed
+	if status, _ := get("not-a-token"); status != http.StatusNotFound {
+		t.Errorf("expected an unknown token to be rejected, got %d", status)
+	}
+
+	token := chat.CalendarToken
+	chat.RevokeCalendarToken()
+	h.session.Db.SaveUser(chat)
+
+	if status, _ := get(token); status != http.StatusNotFound {
+		t.Errorf("expected a revoked token to be rejected, got %d", status)
+	}
+}
+
+// Tests that the REST API serves a launch's history of changes from the database
+func TestLaunchHistoryApi(t *testing.T) {
+	h := newHarness(t)
+	h.session.Config.HttpServer.ApiKeys = []string{"e2e"}
+
+	server := httptest.NewServer(web.NewServer(h.session).Handler())
+	defer server.Close()
+
+	net := h.clock.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	h.ll2.SetLaunches(launch("history", "Starlink", net))
+	h.update()
+
+	// The launch slips
+	slipped := net.Add(24 * time.Hour)
+	h.ll2.SetLaunches(launch("history", "Starlink", slipped))
+	h.update()
+
+	get := func(path string) (int, []byte) {
+		request, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		request.Header.Set("X-API-Key", "e2e")
+
+		response, err := http.DefaultClient.Do(request)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+
+		return response.StatusCode, body
+	}
+
+	status, body := get("/launches/history/history")
+	changes := []struct{ Type, Old, New string }{}
+
+	if err := json.Unmarshal(body, &changes); status != http.StatusOK || err != nil {
+		t.Fatalf("expected the launch's history, got %d: %s", status, body)
+	}
+
+	if len(changes) != 1 || changes[0].Type != "net" || changes[0].New != slipped.Format(time.RFC3339) {
+		t.Errorf("expected the NET change in the history, got %+v", changes)
+	}
+
+	if status, _ := get("/launches/unknown/history"); status != http.StatusNotFound {
+		t.Errorf("expected an unknown launch to be rejected, got %d", status)
+	}
+}
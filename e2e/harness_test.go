@@ -0,0 +1,185 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"launchbot/api"
+	"launchbot/api/ll2test"
+	"launchbot/bots/telegram/telegramtest"
+	"launchbot/config"
+	"launchbot/db"
+	"launchbot/users"
+	"launchbot/utils"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron"
+	tb "gopkg.in/telebot.v3"
+)
+
+// A bot session wired to fake Telegram and LL2 servers, with a fake clock
+type harness struct {
+	t        *testing.T
+	session  *config.Session
+	telegram *telegramtest.Server
+	ll2      *ll2test.Server
+	clock    *utils.FakeClock
+	updateId int32 // Id of the last update fed to the bot
+}
+
+// Starts the fake servers, and initializes a session against them
+func newHarness(t *testing.T) *harness {
+	if testing.Short() {
+		t.Skip("Skipping end-to-end test in short mode")
+	}
+
+	h := &harness{
+		t:        t,
+		telegram: telegramtest.NewServer(),
+		ll2:      ll2test.NewServer(),
+		clock:    utils.NewFakeClock(time.Now().Truncate(time.Second)),
+	}
+
+	// Write a config pointing the bot at the fake Bot API server
+	dataPath := t.TempDir()
+	configPath := filepath.Join(dataPath, "config.json")
+
+	cfg := config.Config{
+		Token:          config.ApiTokens{Telegram: "123456:e2e"},
+		DbFolder:       dataPath,
+		ApiHorizon:     config.Horizon{Launches: 10},
+		TelegramApiURL: h.telegram.URL,
+	}
+
+	bytes, _ := json.Marshal(&cfg)
+
+	if err := os.WriteFile(configPath, bytes, 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	h.session = &config.Session{
+		Started: time.Now(),
+		Version: "e2e",
+		Github:  "github.com/499602D2/tg-launchbot",
+		Clock:   h.clock,
+	}
+
+	h.session.InitializeWithPaths(configPath, dataPath)
+
+	// Fetch launches from the fake LL2 server
+	source := api.NewLL2Source("launchbot-e2e", false, h.session.Config.ApiHorizon)
+	source.Endpoint = h.ll2.URL
+	source.Clock = h.session
+	api.SetLaunchSource(source)
+	api.InitializeBudget(h.session)
+
+	// Run scheduled jobs, and the message sender
+	h.session.Scheduler = gocron.NewScheduler(time.UTC)
+	h.session.Scheduler.StartAsync()
+
+	go h.session.Telegram.ThreadedSender()
+
+	// Give the sender a moment to set its queues up
+	time.Sleep(100 * time.Millisecond)
+
+	t.Cleanup(func() {
+		h.session.Scheduler.Stop()
+		h.telegram.Close()
+		h.ll2.Close()
+	})
+
+	return h
+}
+
+// Creates a private chat subscribed to all launches, with the default notification times
+func (h *harness) addChat(id int64) *users.User {
+	chat := &users.User{
+		Id: fmt.Sprint(id), Platform: "tg", Type: users.Private, SubscribedAll: true,
+		LeadTimes: "1440,5", EnabledPostpone: true, EnabledOutcome: true,
+	}
+
+	h.session.Db.SaveUser(chat)
+	return chat
+}
+
+// Creates a launch that is go for launch at the given time
+func launch(id string, name string, net time.Time) *db.Launch {
+	return &db.Launch{
+		Id:     id,
+		Slug:   id,
+		Name:   name,
+		NET:    net.UTC().Format(time.RFC3339),
+		Status: db.LaunchStatus{Id: 1, Name: "Go for Launch", Abbrev: "Go"},
+		LaunchProvider: db.LaunchProvider{
+			Id: 121, Name: "SpaceX", Abbrev: "SpX", Type: "Commercial", CountryCode: "USA",
+		},
+		Rocket: db.Rocket{Config: db.RocketConfiguration{Name: "Falcon 9", FullName: "Falcon 9 Block 5"}},
+		Mission: db.Mission{
+			Name: name, Type: "Communications", Orbit: db.Orbit{Name: "Low Earth Orbit", Abbrev: "LEO"},
+		},
+		LaunchPad: db.LaunchPad{
+			Name:     "Space Launch Complex 40",
+			Location: db.PadLocation{Name: "Cape Canaveral, FL, USA", CountryCode: "USA"},
+		},
+	}
+}
+
+// Runs an API update against the fake LL2 server
+func (h *harness) update() {
+	if !api.Updater(h.session, false) {
+		h.t.Fatal("API update failed")
+	}
+}
+
+// Runs the scheduler, as done after an API update
+func (h *harness) scheduleNext() {
+	if !api.Scheduler(h.session, false, nil, false) {
+		h.t.Fatal("scheduling failed")
+	}
+}
+
+// Feeds an update to the bot, as if it was received from Telegram
+func (h *harness) process(update tb.Update) {
+	update.ID = int(atomic.AddInt32(&h.updateId, 1))
+	h.session.Telegram.Bot.ProcessUpdate(update)
+}
+
+// Sends a command to the bot from a private chat
+func (h *harness) command(chatId int64, text string) {
+	h.process(tb.Update{Message: &tb.Message{
+		ID:       int(h.updateId) + 1000,
+		Text:     text,
+		Chat:     &tb.Chat{ID: chatId, Type: tb.ChatPrivate},
+		Sender:   &tb.User{ID: chatId},
+		Unixtime: time.Now().Unix(),
+	}})
+}
+
+// Presses an inline button in a message the bot sent to a private chat
+func (h *harness) callback(chatId int64, messageId int, unique string, data string) {
+	h.process(tb.Update{Callback: &tb.Callback{
+		ID:     fmt.Sprintf("callback-%d", h.updateId+1),
+		Data:   fmt.Sprintf("\f%s|%s", unique, data),
+		Sender: &tb.User{ID: chatId},
+		Message: &tb.Message{
+			ID:   messageId,
+			Chat: &tb.Chat{ID: chatId, Type: tb.ChatPrivate},
+		},
+	}})
+}
+
+// Waits for a Bot API call to a chat, failing the test if none arrives in time
+func (h *harness) waitFor(method string, chatId int64, filter func(telegramtest.Call) bool) telegramtest.Call {
+	call, err := h.telegram.WaitFor(method, 10*time.Second, func(call telegramtest.Call) bool {
+		return call.ChatId() == chatId && (filter == nil || filter(call))
+	})
+
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	return call
+}
@@ -19,7 +19,7 @@ type Sendable struct {
 	Type             Type              // sendables.Type (Notification, Command, Delete)
 	IsHighPriority   bool              // High-priority flag (anything that's not a notification)
 	IsBatch          bool              // If true, use batch deletion API for Delete type
-	NotificationType string            // "24h", "12h", "1h", "5min", "postpone"
+	NotificationType string            // A lead time's type (e.g. "24h"), "postpone", "outcome", "webcast", "hold"
 	LaunchId         string            // Launch ID associated with this sendable
 	Message          *Message          // Message (may be nil)
 	MessageIDs       map[string]string // Message ids in the form chat:msg_id for deletions
@@ -36,6 +36,24 @@ type Message struct {
 	AddUserTime bool  // If flipped to true, TextContent contains "$USERTIME"
 	RefTime     int64 // Reference time to use for replacing $USERTIME with
 	SendOptions tb.SendOptions
+	WebcastLink string      // Default webcast link in the message, replaced per recipient if they prefer another
+	Webcasts    []Webcast   // All webcasts of the launch, to choose a recipient's preferred one from
+	Attachment  *Attachment // Optional: a file sent with TextContent as its caption
+}
+
+// A file sent as a document, e.g. a calendar export
+type Attachment struct {
+	FileName string
+	MIME     string
+	Content  []byte
+}
+
+// A webcast a recipient may prefer over the default webcast link
+type Webcast struct {
+	Url      string
+	Language string // Language code, e.g. "en"
+	Priority int    // Lower is better
+	Official bool   // The launch provider's own webcast, instead of a third-party stream
 }
 
 type Type string
@@ -206,3 +224,90 @@ func SetChannelNotificationFooter(text string, uname string) string {
 		utils.PrepareInputForMarkdown(fmt.Sprintf("🔕 *Stop with /settings@%s*", uname), "text"),
 		utils.PrepareInputForMarkdown(fmt.Sprintf("🚀 *Powered by @%s*", uname), "text"))
 }
+
+// Returns the highest-priority webcast in the first preferred language that has one,
+// or an empty string if none of the webcasts match the preferences. Webcasts from the
+// preferred source are picked over others, if any of them match the languages.
+func (message *Message) PreferredWebcast(languages []string, source users.WebcastSource) string {
+	// Finds the highest-priority webcast passing the filter
+	find := func(matches func(webcast *Webcast) bool) *Webcast {
+		var preferred *Webcast
+
+		for i := range message.Webcasts {
+			webcast := &message.Webcasts[i]
+
+			if matches(webcast) && (preferred == nil || webcast.Priority < preferred.Priority) {
+				preferred = webcast
+			}
+		}
+
+		return preferred
+	}
+
+	fromSource := func(webcast *Webcast) bool {
+		return source == users.WebcastAnySource || webcast.Official == (source == users.WebcastOfficial)
+	}
+
+	if len(languages) == 0 {
+		if preferred := find(fromSource); preferred != nil && source != users.WebcastAnySource {
+			return preferred.Url
+		}
+
+		return ""
+	}
+
+	// Without a webcast from the preferred source, fall back to any source
+	for _, matchSource := range []bool{true, false} {
+		for _, language := range languages {
+			preferred := find(func(webcast *Webcast) bool {
+				return strings.EqualFold(webcast.Language, language) && (!matchSource || fromSource(webcast))
+			})
+
+			if preferred != nil {
+				return preferred.Url
+			}
+		}
+	}
+
+	return ""
+}
+
+// Swaps the default webcast link in the text and the keyboard for the recipient's preferred
+// webcast, if one exists. The send options are copied, as workers share the sendable.
+func SetPreferredWebcast(text string, opts tb.SendOptions, message *Message, user *users.User) (string, tb.SendOptions) {
+	if message.WebcastLink == "" || len(message.Webcasts) == 0 ||
+		(user.WebcastLanguages == "" && user.WebcastSource == users.WebcastAnySource) {
+		return text, opts
+	}
+
+	preferred := message.PreferredWebcast(user.PreferredWebcastLanguages(), user.WebcastSource)
+
+	if preferred == "" || preferred == message.WebcastLink {
+		// Fall back to the default link
+		return text, opts
+	}
+
+	// Links in the text are escaped for MarkdownV2
+	text = strings.ReplaceAll(text,
+		fmt.Sprintf("(%s)", utils.PrepareInputForMarkdown(message.WebcastLink, "link")),
+		fmt.Sprintf("(%s)", utils.PrepareInputForMarkdown(preferred, "link")))
+
+	if opts.ReplyMarkup != nil && len(opts.ReplyMarkup.InlineKeyboard) != 0 {
+		kb := make([][]tb.InlineButton, len(opts.ReplyMarkup.InlineKeyboard))
+
+		for i, row := range opts.ReplyMarkup.InlineKeyboard {
+			kb[i] = make([]tb.InlineButton, len(row))
+			copy(kb[i], row)
+
+			for j := range kb[i] {
+				if kb[i][j].URL == message.WebcastLink {
+					kb[i][j].URL = preferred
+				}
+			}
+		}
+
+		opts.ReplyMarkup = &tb.ReplyMarkup{InlineKeyboard: kb}
+	}
+
+	return text, opts
+}
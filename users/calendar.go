@@ -0,0 +1,23 @@
+package users
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// Creates a new, unguessable calendar feed token for the chat, replacing any previous token
+func (user *User) GenerateCalendarToken() error {
+	token := make([]byte, 24)
+
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+
+	user.CalendarToken = base64.RawURLEncoding.EncodeToString(token)
+	return nil
+}
+
+// Revokes the chat's calendar feed token, disabling its feed
+func (user *User) RevokeCalendarToken() {
+	user.CalendarToken = ""
+}
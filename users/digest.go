@@ -0,0 +1,63 @@
+package users
+
+import "time"
+
+// How often a chat receives a digest of upcoming launches
+type DigestFrequency string
+
+const (
+	DigestOff    DigestFrequency = ""       // No digests
+	DigestDaily  DigestFrequency = "daily"  // A digest of the coming day, every day
+	DigestWeekly DigestFrequency = "weekly" // A digest of the coming week, on the chosen weekday
+)
+
+// Local hour digests are delivered at, unless the chat picks another one
+const DefaultDigestHour = 8
+
+// Enables or disables digests, returning false for an unknown frequency
+func (user *User) SetDigestFrequency(frequency DigestFrequency) bool {
+	switch frequency {
+	case DigestOff, DigestDaily, DigestWeekly:
+		user.DigestFrequency = frequency
+		return true
+	}
+
+	return false
+}
+
+// Sets the local hour digests are delivered at, wrapping around midnight
+func (user *User) SetDigestHour(hour int) {
+	user.DigestHour = ((hour % 24) + 24) % 24
+}
+
+// Sets the weekday weekly digests are delivered on, wrapping around the week
+func (user *User) SetDigestWeekday(weekday int) {
+	user.DigestWeekday = time.Weekday(((weekday % 7) + 7) % 7)
+}
+
+// Returns how far ahead the chat's digest looks, or zero if digests are disabled
+func (user *User) DigestPeriod() time.Duration {
+	switch user.DigestFrequency {
+	case DigestDaily:
+		return 24 * time.Hour
+	case DigestWeekly:
+		return 7 * 24 * time.Hour
+	}
+
+	return 0
+}
+
+// Returns true if the chat's digest is due during the local hour of this time
+func (user *User) DigestDue(now time.Time) bool {
+	if user.DigestFrequency == DigestOff {
+		return false
+	}
+
+	local := user.localTime(now)
+
+	if local.Hour() != user.DigestHour {
+		return false
+	}
+
+	return user.DigestFrequency == DigestDaily || local.Weekday() == user.DigestWeekday
+}
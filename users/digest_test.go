@@ -0,0 +1,52 @@
+package users
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDigestDue(t *testing.T) {
+	user := User{Locale: "Europe/Helsinki"}
+	user.SetTimeZone()
+
+	// 05:00 UTC on a Friday is 08:00 in Helsinki in the summer
+	friday := time.Date(2022, 7, 1, 5, 0, 0, 0, time.UTC)
+
+	if user.DigestDue(friday) {
+		t.Error("Chat without digests should never have a digest due")
+	}
+
+	user.SetDigestFrequency(DigestDaily)
+	user.SetDigestHour(DefaultDigestHour)
+
+	if !user.DigestDue(friday) || user.DigestDue(friday.Add(time.Hour)) {
+		t.Error("Expected a daily digest to be due at 08:00 local time only")
+	}
+
+	user.SetDigestFrequency(DigestWeekly)
+	user.SetDigestWeekday(int(time.Monday))
+
+	if user.DigestDue(friday) || !user.DigestDue(friday.AddDate(0, 0, 3)) {
+		t.Error("Expected a weekly digest to be due on Mondays only")
+	}
+
+	if user.SetDigestFrequency("hourly") || user.DigestFrequency != DigestWeekly {
+		t.Error("Expected an unknown frequency to be ignored")
+	}
+}
+
+func TestDigestSettingsWrap(t *testing.T) {
+	user := User{}
+
+	for hour, expected := range map[int]int{-1: 23, 24: 0, 12: 12} {
+		if user.SetDigestHour(hour); user.DigestHour != expected {
+			t.Errorf("SetDigestHour(%d) = %d; expected %d", hour, user.DigestHour, expected)
+		}
+	}
+
+	for weekday, expected := range map[int]time.Weekday{-1: time.Saturday, 7: time.Sunday, 3: time.Wednesday} {
+		if user.SetDigestWeekday(weekday); user.DigestWeekday != expected {
+			t.Errorf("SetDigestWeekday(%d) = %s; expected %s", weekday, user.DigestWeekday, expected)
+		}
+	}
+}
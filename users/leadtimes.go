@@ -0,0 +1,226 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+Lead times are how long before a launch its notifications are sent, stored in
+minutes. Each chat picks its own set of lead times, and the notification type of
+a lead time is a short string such as "3d", "24h", "90min" or "5min".
+*/
+
+// Lead times shown as presets in the notification time settings
+var PresetLeadTimes = []int{3 * 1440, 1440, 720, 120, 60, 30, 15, 5}
+
+// Lead times a new chat starts with
+const DefaultLeadTimes = "1440,5"
+
+const (
+	// Longest lead time a chat can pick, in minutes
+	MaxLeadTime = 7 * 1440
+
+	// Maximum number of lead times a chat can have enabled
+	MaxLeadTimes = 10
+
+	/* Notifications sent at most this many minutes before lift-off are a launch's final
+	notifications: they show the exact time until launch, and chats receiving one are
+	sent the launch's outcome. */
+	FinalLeadTime = 15
+)
+
+// Matches a single "<number><unit>" part of a lead time, e.g. "2h" or "30 minutes"
+var leadTimePart = regexp.MustCompile(`^(\d+)\s*(days|day|d|hours|hour|hrs|hr|h|minutes|minute|mins|min|m)`)
+
+// Returns the notification type of a lead time, e.g. "24h"
+func LeadTimeType(minutes int) string {
+	switch {
+	case minutes >= 2*1440 && minutes%1440 == 0:
+		return fmt.Sprintf("%dd", minutes/1440)
+	case minutes >= 60 && minutes%60 == 0:
+		return fmt.Sprintf("%dh", minutes/60)
+	}
+
+	return fmt.Sprintf("%dmin", minutes)
+}
+
+// Parses a lead time, either a notification type or a duration such as "1h 30min" or "3 days"
+func ParseLeadTime(text string) (int, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+
+	if text == "" {
+		return 0, errors.New("no time given")
+	}
+
+	minutes := 0
+
+	for text != "" {
+		match := leadTimePart.FindStringSubmatch(text)
+
+		if match == nil {
+			return 0, fmt.Errorf("could not understand \"%s\", try e.g. 3d, 2h or 30min", text)
+		}
+
+		value, err := strconv.Atoi(match[1])
+
+		if err != nil || value > MaxLeadTime {
+			return 0, fmt.Errorf("%s is too large", match[1])
+		}
+
+		switch match[2][0] {
+		case 'd':
+			value *= 1440
+		case 'h':
+			value *= 60
+		}
+
+		minutes += value
+		text = strings.TrimSpace(text[len(match[0]):])
+	}
+
+	if minutes < 1 {
+		return 0, errors.New("the time must be at least one minute")
+	}
+
+	if minutes > MaxLeadTime {
+		return 0, fmt.Errorf("the time can be at most %d days", MaxLeadTime/1440)
+	}
+
+	return minutes, nil
+}
+
+// Returns the lead time of a notification type, and false if it's not a lead time (e.g. "postpone")
+func LeadTimeOfType(notificationType string) (int, bool) {
+	minutes, err := ParseLeadTime(notificationType)
+
+	if err != nil || LeadTimeType(minutes) != notificationType {
+		return 0, false
+	}
+
+	return minutes, true
+}
+
+// Returns a lead time as a duration in words, e.g. "24 hours" or "60 minutes"
+func LeadTimeDescription(minutes int) string {
+	plural := func(value int, unit string) string {
+		if value == 1 {
+			return fmt.Sprintf("%d %s", value, unit)
+		}
+
+		return fmt.Sprintf("%d %ss", value, unit)
+	}
+
+	switch {
+	case minutes >= 2*1440 && minutes%1440 == 0:
+		return plural(minutes/1440, "day")
+	case minutes < 120:
+		return plural(minutes, "minute")
+	case minutes%60 == 0:
+		return plural(minutes/60, "hour")
+	}
+
+	return plural(minutes/60, "hour") + " " + plural(minutes%60, "minute")
+}
+
+// Returns a short label for a lead time, used in buttons, e.g. "24-hour"
+func LeadTimeLabel(minutes int) string {
+	switch {
+	case minutes >= 2*1440 && minutes%1440 == 0:
+		return fmt.Sprintf("%d-day", minutes/1440)
+	case minutes >= 60 && minutes%60 == 0:
+		return fmt.Sprintf("%d-hour", minutes/60)
+	}
+
+	return fmt.Sprintf("%d-minute", minutes)
+}
+
+// Parses a comma-separated list of lead times, in descending order
+func parseLeadTimeList(list string) []int {
+	leadTimes := []int{}
+
+	for _, value := range strings.Split(list, ",") {
+		minutes, err := strconv.Atoi(strings.TrimSpace(value))
+
+		if err == nil && minutes > 0 {
+			leadTimes = append(leadTimes, minutes)
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(leadTimes)))
+	return leadTimes
+}
+
+// Returns the union of several comma-separated lead time lists, in descending order
+func MergeLeadTimeLists(lists []string) []int {
+	seen := map[int]bool{}
+	leadTimes := []int{}
+
+	for _, list := range lists {
+		for _, minutes := range parseLeadTimeList(list) {
+			if !seen[minutes] {
+				seen[minutes] = true
+				leadTimes = append(leadTimes, minutes)
+			}
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(leadTimes)))
+	return leadTimes
+}
+
+// Returns the chat's enabled lead times, in descending order
+func (user *User) EnabledLeadTimes() []int {
+	return parseLeadTimeList(user.LeadTimes)
+}
+
+// Returns true if the chat has enabled notifications at this lead time
+func (user *User) HasLeadTime(minutes int) bool {
+	for _, leadTime := range user.EnabledLeadTimes() {
+		if leadTime == minutes {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true if the chat receives a launch's final notification
+func (user *User) HasFinalLeadTime() bool {
+	leadTimes := user.EnabledLeadTimes()
+	return len(leadTimes) != 0 && leadTimes[len(leadTimes)-1] <= FinalLeadTime
+}
+
+// Enables or disables notifications at a lead time
+func (user *User) SetLeadTime(minutes int, enabled bool) error {
+	leadTimes := []int{}
+
+	for _, leadTime := range user.EnabledLeadTimes() {
+		if leadTime != minutes {
+			leadTimes = append(leadTimes, leadTime)
+		}
+	}
+
+	if enabled {
+		if len(leadTimes) >= MaxLeadTimes {
+			return fmt.Errorf("you can have at most %d notification times", MaxLeadTimes)
+		}
+
+		leadTimes = append(leadTimes, minutes)
+	}
+
+	// Keep the stored list in descending order
+	sort.Sort(sort.Reverse(sort.IntSlice(leadTimes)))
+	values := make([]string, 0, len(leadTimes))
+
+	for _, leadTime := range leadTimes {
+		values = append(values, strconv.Itoa(leadTime))
+	}
+
+	user.LeadTimes = strings.Join(values, ",")
+	return nil
+}
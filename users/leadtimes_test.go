@@ -0,0 +1,84 @@
+package users
+
+import "testing"
+
+func TestParseLeadTime(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected int
+		valid    bool
+	}{
+		{"3d", 3 * 1440, true},
+		{"3 days", 3 * 1440, true},
+		{"24h", 1440, true},
+		{"1h 30min", 90, true},
+		{"2 hours 15 minutes", 135, true},
+		{"90MIN", 90, true},
+		{"15m", 15, true},
+		{"0min", 0, false},
+		{"8d", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+
+	for _, tt := range tests {
+		minutes, err := ParseLeadTime(tt.text)
+
+		if (err == nil) != tt.valid || minutes != tt.expected {
+			t.Errorf("ParseLeadTime(%q) = %d, %v; expected %d (valid=%v)", tt.text, minutes, err, tt.expected, tt.valid)
+		}
+	}
+}
+
+func TestLeadTimeTypes(t *testing.T) {
+	for minutes, expected := range map[int]string{4320: "3d", 1440: "24h", 720: "12h", 90: "90min", 60: "1h", 5: "5min", 2*1440 + 60: "49h"} {
+		if notificationType := LeadTimeType(minutes); notificationType != expected {
+			t.Errorf("LeadTimeType(%d) = %s, expected %s", minutes, notificationType, expected)
+		}
+
+		// Types map back to the same lead time
+		if leadTime, ok := LeadTimeOfType(expected); !ok || leadTime != minutes {
+			t.Errorf("LeadTimeOfType(%s) = %d, %v; expected %d", expected, leadTime, ok, minutes)
+		}
+	}
+
+	// Only canonical types, and no event notification types, are lead times
+	for _, notificationType := range []string{"60min", "1d", "postpone", "outcome"} {
+		if _, ok := LeadTimeOfType(notificationType); ok {
+			t.Errorf("LeadTimeOfType(%s) should not be a lead time", notificationType)
+		}
+	}
+}
+
+func TestSetLeadTime(t *testing.T) {
+	user := User{LeadTimes: DefaultLeadTimes}
+
+	if !user.HasLeadTime(1440) || !user.HasFinalLeadTime() {
+		t.Fatal("Default lead times should include the 24-hour and 5-minute notifications")
+	}
+
+	// Lead times are kept in descending order, without duplicates
+	user.SetLeadTime(90, true)
+	user.SetLeadTime(4320, true)
+	user.SetLeadTime(90, true)
+
+	if user.LeadTimes != "4320,1440,90,5" {
+		t.Errorf("Unexpected lead times: %s", user.LeadTimes)
+	}
+
+	// Without a lead time of at most 15 minutes, there are no final notifications
+	user.SetNotificationTimeFlag("5min", false)
+
+	if user.HasFinalLeadTime() || user.LeadTimes != "4320,1440,90" {
+		t.Errorf("Disabling the 5-minute notification failed: %s", user.LeadTimes)
+	}
+
+	// The number of lead times is limited
+	for minutes := 1; minutes <= MaxLeadTimes; minutes++ {
+		user.SetLeadTime(minutes, true)
+	}
+
+	if len(user.EnabledLeadTimes()) != MaxLeadTimes {
+		t.Errorf("Expected at most %d lead times, got %s", MaxLeadTimes, user.LeadTimes)
+	}
+}
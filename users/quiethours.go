@@ -0,0 +1,163 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Quiet hours are a daily period, in the chat's own time zone, during which no
+notifications are delivered. What happens to the notifications that come up during
+quiet hours depends on the chat's quiet hours policy.
+*/
+
+// What is done with notifications that come up during quiet hours
+type QuietPolicy string
+
+const (
+	QuietHold    QuietPolicy = "hold"    // Deliver the notifications when quiet hours end
+	QuietSummary QuietPolicy = "summary" // Summarize the notifications in one message when quiet hours end
+	QuietDrop    QuietPolicy = "drop"    // Never deliver the notifications
+)
+
+// Quiet hours policies, in the order they are shown in
+var QuietPolicies = []QuietPolicy{QuietHold, QuietSummary, QuietDrop}
+
+// Matches quiet hours such as "22-7", "22:30-06:00" or "23.00 – 7.30"
+var quietHoursPattern = regexp.MustCompile(`^(\d{1,2})(?:[:.](\d{2}))?\s*[-–]\s*(\d{1,2})(?:[:.](\d{2}))?$`)
+
+// Parses quiet hours, returning their start and end as minutes after midnight
+func ParseQuietHours(text string) (int, int, error) {
+	match := quietHoursPattern.FindStringSubmatch(strings.TrimSpace(text))
+
+	if match == nil {
+		return 0, 0, errors.New("could not understand the hours, try e.g. 22-7 or 22:30-06:00")
+	}
+
+	// Converts an hour and an optional minute into minutes after midnight
+	toMinutes := func(hour string, minute string) (int, error) {
+		h, _ := strconv.Atoi(hour)
+		m := 0
+
+		if minute != "" {
+			m, _ = strconv.Atoi(minute)
+		}
+
+		if h > 23 || m > 59 {
+			return 0, fmt.Errorf("%s:%02d is not a valid time", hour, m)
+		}
+
+		return h*60 + m, nil
+	}
+
+	start, err := toMinutes(match[1], match[2])
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end, err := toMinutes(match[3], match[4])
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if start == end {
+		return 0, 0, errors.New("quiet hours must start and end at different times")
+	}
+
+	return start, end, nil
+}
+
+// Sets the chat's quiet hours from text, e.g. "22-7"
+func (user *User) SetQuietHours(text string) error {
+	start, end, err := ParseQuietHours(text)
+
+	if err != nil {
+		return err
+	}
+
+	user.QuietHours = fmt.Sprintf("%02d:%02d-%02d:%02d", start/60, start%60, end/60, end%60)
+	return nil
+}
+
+// Sets the chat's quiet hours policy, ignoring unknown policies
+func (user *User) SetQuietPolicy(policy QuietPolicy) bool {
+	for _, known := range QuietPolicies {
+		if policy == known {
+			user.QuietPolicy = policy
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the chat's quiet hours policy, defaulting to holding notifications back
+func (user *User) QuietHoursPolicy() QuietPolicy {
+	if user.QuietPolicy == "" {
+		return QuietHold
+	}
+
+	return user.QuietPolicy
+}
+
+// Returns the start and end of the chat's quiet hours in minutes after midnight, and false if not set
+func (user *User) quietHoursRange() (int, int, bool) {
+	if user.QuietHours == "" {
+		return 0, 0, false
+	}
+
+	start, end, err := ParseQuietHours(user.QuietHours)
+	return start, end, err == nil
+}
+
+// Returns the time in the chat's time zone
+func (user *User) localTime(now time.Time) time.Time {
+	if user.Time.Location == nil {
+		user.SetTimeZone()
+	}
+
+	return now.In(user.Time.Location)
+}
+
+// Returns true if the chat is in its quiet hours at this time
+func (user *User) InQuietHours(now time.Time) bool {
+	start, end, ok := user.quietHoursRange()
+
+	if !ok {
+		return false
+	}
+
+	local := user.localTime(now)
+	minute := local.Hour()*60 + local.Minute()
+
+	if start < end {
+		return minute >= start && minute < end
+	}
+
+	// Quiet hours span midnight, e.g. 22:00-07:00
+	return minute >= start || minute < end
+}
+
+// Returns when the chat's current, or next, quiet hours end
+func (user *User) QuietHoursEnd(now time.Time) time.Time {
+	_, end, ok := user.quietHoursRange()
+
+	if !ok {
+		return now
+	}
+
+	local := user.localTime(now)
+	endTime := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, local.Location())
+
+	if !endTime.After(local) {
+		endTime = endTime.AddDate(0, 0, 1)
+	}
+
+	return endTime
+}
@@ -0,0 +1,73 @@
+package users
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	tests := []struct {
+		text       string
+		start, end int
+		valid      bool
+	}{
+		{"22-7", 22 * 60, 7 * 60, true},
+		{"23:30-06:00", 23*60 + 30, 6 * 60, true},
+		{"1.15 – 5.45", 75, 5*60 + 45, true},
+		{"9-17", 9 * 60, 17 * 60, true},
+		{"24-7", 0, 0, false},
+		{"22:75-7", 0, 0, false},
+		{"7-7", 0, 0, false},
+		{"at night", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		start, end, err := ParseQuietHours(tt.text)
+
+		if (err == nil) != tt.valid || start != tt.start || end != tt.end {
+			t.Errorf("ParseQuietHours(%q) = %d, %d, %v; expected %d, %d (valid=%v)",
+				tt.text, start, end, err, tt.start, tt.end, tt.valid)
+		}
+	}
+}
+
+func TestQuietHoursInTimeZone(t *testing.T) {
+	user := User{Locale: "Europe/Helsinki"}
+	user.SetTimeZone()
+
+	if user.InQuietHours(time.Now()) {
+		t.Error("Chat without quiet hours should never be in quiet hours")
+	}
+
+	if err := user.SetQuietHours("22-7"); err != nil || user.QuietHours != "22:00-07:00" {
+		t.Fatalf("Setting quiet hours failed: %v (%s)", err, user.QuietHours)
+	}
+
+	// 01:00 UTC is 04:00 in Helsinki in the summer: inside quiet hours spanning midnight
+	night := time.Date(2022, 7, 1, 1, 0, 0, 0, time.UTC)
+
+	if !user.InQuietHours(night) {
+		t.Error("Expected 04:00 local time to be in quiet hours")
+	}
+
+	if end := user.QuietHoursEnd(night); !end.Equal(time.Date(2022, 7, 1, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected quiet hours to end at 07:00 local time, got %s", end.UTC())
+	}
+
+	// 20:00 UTC is 23:00 in Helsinki: quiet hours end the next morning
+	evening := time.Date(2022, 7, 1, 20, 0, 0, 0, time.UTC)
+
+	if !user.InQuietHours(evening) || !user.QuietHoursEnd(evening).Equal(time.Date(2022, 7, 2, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected quiet hours until the next morning, got %s", user.QuietHoursEnd(evening).UTC())
+	}
+
+	// 12:00 UTC is 15:00 in Helsinki: outside quiet hours
+	if user.InQuietHours(time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Error("Expected 15:00 local time to be outside quiet hours")
+	}
+
+	// Unknown policies are rejected, and the default policy holds notifications
+	if user.QuietHoursPolicy() != QuietHold || user.SetQuietPolicy("later") || !user.SetQuietPolicy(QuietDrop) {
+		t.Errorf("Unexpected quiet hours policy handling: %s", user.QuietPolicy)
+	}
+}
@@ -0,0 +1,504 @@
+package users
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+)
+
+/*
+Filter rules are small boolean expressions matched against a launch, e.g.
+
+	provider:SpaceX AND NOT mission:Starlink
+	vehicle:"Falcon Heavy" OR vehicle:/electron|neutron/
+	(pad:Kourou OR pad:Wenchang) orbit:GTO
+
+Terms match case-insensitively anywhere in their field: quoted phrases keep their spaces,
+and /slashes/ make a term a regular expression. Terms without a field match the launch,
+vehicle and mission names. Adjacent terms are implicitly joined with AND, which binds
+tighter than OR. Operators must be written in upper case.
+*/
+
+// Fields a rule term can be scoped to
+var ruleFields = []string{"provider", "vehicle", "mission", "pad", "orbit"}
+
+// Maximum length of a single rule
+const MaxRuleLength = 300
+
+// A parsed filter rule
+type Rule struct {
+	Source string
+	root   ruleNode
+}
+
+// An error in a rule, with the position (in characters) it was found at
+type RuleError struct {
+	Position int
+	Message  string
+}
+
+func (err *RuleError) Error() string {
+	return fmt.Sprintf("%s (at character %d)", err.Message, err.Position+1)
+}
+
+// Returns true if the rule matches the launch
+func (rule *Rule) Matches(launch LaunchInfo) bool {
+	return rule.root.eval(&launch)
+}
+
+type ruleNode interface {
+	eval(launch *LaunchInfo) bool
+}
+
+type andNode struct{ left, right ruleNode }
+type orNode struct{ left, right ruleNode }
+type notNode struct{ node ruleNode }
+
+// Matches a phrase or a regular expression against a field, or against the names if field is empty
+type matchNode struct {
+	field  string
+	phrase string // Lower-case phrase, if not a regex
+	regex  *regexp.Regexp
+}
+
+func (node *andNode) eval(launch *LaunchInfo) bool {
+	return node.left.eval(launch) && node.right.eval(launch)
+}
+
+func (node *orNode) eval(launch *LaunchInfo) bool {
+	return node.left.eval(launch) || node.right.eval(launch)
+}
+
+func (node *notNode) eval(launch *LaunchInfo) bool {
+	return !node.node.eval(launch)
+}
+
+func (node *matchNode) eval(launch *LaunchInfo) bool {
+	for _, value := range launch.ruleFieldValues(node.field) {
+		if value == "" {
+			continue
+		}
+
+		if node.regex != nil {
+			if node.regex.MatchString(value) {
+				return true
+			}
+		} else if strings.Contains(strings.ToLower(value), node.phrase) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the values a field matches against
+func (launch *LaunchInfo) ruleFieldValues(field string) []string {
+	switch field {
+	case "provider":
+		return []string{launch.ProviderName}
+	case "vehicle":
+		return []string{launch.VehicleName, launch.Family}
+	case "mission":
+		return []string{launch.MissionName, launch.MissionType}
+	case "pad":
+		return []string{launch.PadName, launch.LocationName}
+	case "orbit":
+		return []string{launch.Orbit, launch.OrbitName}
+	}
+
+	return []string{launch.Name, launch.VehicleName, launch.MissionName}
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenTerm
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type ruleToken struct {
+	kind     tokenKind
+	position int
+	text     string     // Source text of the token, for error messages
+	term     *matchNode // Set for terms
+}
+
+// Splits a rule into tokens
+func tokenizeRule(source string) ([]ruleToken, error) {
+	runes := []rune(source)
+	tokens := []ruleToken{}
+	i := 0
+
+	for i < len(runes) {
+		start := i
+
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, ruleToken{kind: tokenOpen, position: start, text: "("})
+			i++
+			continue
+		case r == ')':
+			tokens = append(tokens, ruleToken{kind: tokenClose, position: start, text: ")"})
+			i++
+			continue
+		}
+
+		// Read a word, up to the next space, parenthesis or quote
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()\"", runes[i]) &&
+			!(runes[i] == '/' && i == start) {
+			i++
+
+			// A field prefix may be directly followed by a phrase or a regex
+			if runes[i-1] == ':' && i < len(runes) && (runes[i] == '"' || runes[i] == '/') {
+				break
+			}
+		}
+
+		word := string(runes[start:i])
+
+		switch word {
+		case "AND":
+			tokens = append(tokens, ruleToken{kind: tokenAnd, position: start, text: word})
+			continue
+		case "OR":
+			tokens = append(tokens, ruleToken{kind: tokenOr, position: start, text: word})
+			continue
+		case "NOT":
+			tokens = append(tokens, ruleToken{kind: tokenNot, position: start, text: word})
+			continue
+		}
+
+		term := &matchNode{}
+		value := word
+
+		// Split the field from the value
+		if colon := strings.IndexRune(word, ':'); colon != -1 {
+			field := strings.ToLower(word[:colon])
+
+			if !isRuleField(field) {
+				return nil, &RuleError{start, fmt.Sprintf("unknown field \"%s\", expected one of: %s",
+					word[:colon], strings.Join(ruleFields, ", "))}
+			}
+
+			term.field = field
+			value = word[colon+1:]
+		}
+
+		// A phrase or a regex follows the field, or starts the term
+		if value == "" && i < len(runes) && (runes[i] == '"' || runes[i] == '/') {
+			delimiter := runes[i]
+			end := i + 1
+
+			for end < len(runes) && runes[end] != delimiter {
+				// Allow escaping slashes inside a regex
+				if delimiter == '/' && runes[end] == '\\' && end+1 < len(runes) {
+					end++
+				}
+
+				end++
+			}
+
+			if end >= len(runes) {
+				kind := map[rune]string{'"': "quote", '/': "regex"}[delimiter]
+				return nil, &RuleError{i, fmt.Sprintf("unterminated %s: add a closing %c", kind, delimiter)}
+			}
+
+			value = string(runes[i+1 : end])
+
+			if delimiter == '/' {
+				regex, err := regexp.Compile("(?i)" + strings.ReplaceAll(value, `\/`, "/"))
+
+				if err != nil {
+					return nil, &RuleError{i, fmt.Sprintf("invalid regex /%s/: %s", value, err.Error())}
+				}
+
+				term.regex = regex
+			}
+
+			i = end + 1
+		}
+
+		if strings.TrimSpace(value) == "" {
+			if term.field != "" {
+				return nil, &RuleError{start, fmt.Sprintf("field \"%s\" has no value, e.g. %s:something", term.field, term.field)}
+			}
+
+			return nil, &RuleError{start, "empty term"}
+		}
+
+		if term.regex == nil {
+			term.phrase = strings.ToLower(value)
+		}
+
+		tokens = append(tokens, ruleToken{kind: tokenTerm, position: start, text: string(runes[start:i]), term: term})
+	}
+
+	return append(tokens, ruleToken{kind: tokenEnd, position: len(runes)}), nil
+}
+
+func isRuleField(field string) bool {
+	for _, known := range ruleFields {
+		if field == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A recursive-descent parser over the tokens of a rule
+type ruleParser struct {
+	tokens []ruleToken
+	index  int
+}
+
+func (parser *ruleParser) peek() ruleToken {
+	return parser.tokens[parser.index]
+}
+
+func (parser *ruleParser) next() ruleToken {
+	token := parser.tokens[parser.index]
+
+	if token.kind != tokenEnd {
+		parser.index++
+	}
+
+	return token
+}
+
+// or := and ("OR" and)*
+func (parser *ruleParser) parseOr() (ruleNode, error) {
+	left, err := parser.parseAnd()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for parser.peek().kind == tokenOr {
+		parser.next()
+		right, err := parser.parseAnd()
+
+		if err != nil {
+			return nil, err
+		}
+
+		left = &orNode{left, right}
+	}
+
+	return left, nil
+}
+
+// and := not (["AND"] not)*
+func (parser *ruleParser) parseAnd() (ruleNode, error) {
+	left, err := parser.parseNot()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch parser.peek().kind {
+		case tokenAnd:
+			parser.next()
+		case tokenTerm, tokenNot, tokenOpen:
+			// Adjacent terms are implicitly joined with AND
+		default:
+			return left, nil
+		}
+
+		right, err := parser.parseNot()
+
+		if err != nil {
+			return nil, err
+		}
+
+		left = &andNode{left, right}
+	}
+}
+
+// not := "NOT" not | primary
+func (parser *ruleParser) parseNot() (ruleNode, error) {
+	if parser.peek().kind == tokenNot {
+		parser.next()
+		node, err := parser.parseNot()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &notNode{node}, nil
+	}
+
+	return parser.parsePrimary()
+}
+
+// primary := "(" or ")" | term
+func (parser *ruleParser) parsePrimary() (ruleNode, error) {
+	token := parser.next()
+
+	switch token.kind {
+	case tokenTerm:
+		return token.term, nil
+	case tokenOpen:
+		node, err := parser.parseOr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := parser.next(); closing.kind != tokenClose {
+			return nil, &RuleError{token.position, "unclosed parenthesis: add a closing )"}
+		}
+
+		return node, nil
+	case tokenEnd:
+		if parser.index > 0 && len(parser.tokens) > 1 {
+			previous := parser.tokens[parser.index-1]
+			return nil, &RuleError{previous.position, fmt.Sprintf("expected a term after \"%s\"", previous.text)}
+		}
+
+		return nil, &RuleError{0, "rule is empty"}
+	case tokenClose:
+		return nil, &RuleError{token.position, "unexpected ), without a matching ("}
+	}
+
+	return nil, &RuleError{token.position, fmt.Sprintf("expected a term before \"%s\"", token.text)}
+}
+
+// Parses and validates a filter rule
+func ParseRule(source string) (*Rule, error) {
+	source = strings.TrimSpace(source)
+
+	if len(source) > MaxRuleLength {
+		return nil, &RuleError{MaxRuleLength, fmt.Sprintf("rule is too long (max. %d characters)", MaxRuleLength)}
+	}
+
+	// Rules are stored newline-separated
+	if i := strings.IndexAny(source, "\r\n"); i != -1 {
+		return nil, &RuleError{i, "a rule must fit on a single line"}
+	}
+
+	tokens, err := tokenizeRule(source)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &ruleParser{tokens: tokens}
+	root, err := parser.parseOr()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything must be consumed, e.g. a stray ) is an error
+	if token := parser.peek(); token.kind != tokenEnd {
+		if token.kind == tokenClose {
+			return nil, &RuleError{token.position, "unexpected ), without a matching ("}
+		}
+
+		return nil, &RuleError{token.position, fmt.Sprintf("unexpected \"%s\"", token.text)}
+	}
+
+	return &Rule{Source: source, root: root}, nil
+}
+
+// Maximum number of rules of each action a chat can have
+const MaxRulesPerAction = 20
+
+// Returns the stored rules list of an action, "block" or "allow"
+func (user *User) ruleList(action string) *string {
+	if action == "allow" {
+		return &user.AllowRules
+	}
+
+	return &user.BlockRules
+}
+
+// Returns the sources of the chat's rules of an action, "block" or "allow"
+func (user *User) RuleSources(action string) []string {
+	list := *user.ruleList(action)
+
+	if list == "" {
+		return []string{}
+	}
+
+	return strings.Split(list, "\n")
+}
+
+// Returns the chat's parsed rules of an action, "block" or "allow". Rules are validated
+// when they are added, so invalid stored rules are only logged and skipped.
+func (user *User) Rules(action string) []*Rule {
+	rules := []*Rule{}
+
+	for _, source := range user.RuleSources(action) {
+		rule, err := ParseRule(source)
+
+		if err != nil {
+			log.Warn().Err(err).Msgf("Skipping invalid %s rule of chat=%s: %s", action, user.Id, source)
+			continue
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// Validates and adds a rule of an action, "block" or "allow"
+func (user *User) AddRule(action string, source string) (*Rule, error) {
+	rule, err := ParseRule(source)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sources := user.RuleSources(action)
+
+	if len(sources) >= MaxRulesPerAction {
+		return nil, fmt.Errorf("you can have at most %d %s rules", MaxRulesPerAction, action)
+	}
+
+	for _, existing := range sources {
+		if existing == rule.Source {
+			return nil, fmt.Errorf("this %s rule already exists", action)
+		}
+	}
+
+	*user.ruleList(action) = strings.Join(append(sources, rule.Source), "\n")
+	return rule, nil
+}
+
+// Removes the rule at an index from the rules of an action, "block" or "allow"
+func (user *User) RemoveRule(action string, index int) bool {
+	sources := user.RuleSources(action)
+
+	if index < 0 || index >= len(sources) {
+		return false
+	}
+
+	*user.ruleList(action) = strings.Join(append(sources[:index], sources[index+1:]...), "\n")
+	return true
+}
+
+// Returns the first rule matching the launch, or nil
+func matchingRule(rules []*Rule, launch LaunchInfo) *Rule {
+	for _, rule := range rules {
+		if rule.Matches(launch) {
+			return rule
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package users
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseRuleErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		position int
+		message  string
+	}{
+		{"Empty rule", "   ", 0, "rule is empty"},
+		{"Unknown field", "provider:SpaceX AND rocket:Falcon", 20, "unknown field \"rocket\""},
+		{"Field without a value", "provider: AND pad:Kourou", 0, "field \"provider\" has no value"},
+		{"Unterminated quote", "vehicle:\"Falcon Heavy", 8, "unterminated quote"},
+		{"Unterminated regex", "mission:/starlink", 8, "unterminated regex"},
+		{"Invalid regex", "mission:/star(link/", 8, "invalid regex"},
+		{"Dangling operator", "provider:SpaceX AND", 16, "expected a term after \"AND\""},
+		{"Leading operator", "OR pad:Kourou", 0, "expected a term before \"OR\""},
+		{"Unclosed parenthesis", "(pad:Kourou OR pad:Wenchang", 0, "unclosed parenthesis"},
+		{"Stray parenthesis", "pad:Kourou)", 10, "unexpected ), without a matching ("},
+		{"Multiple lines", "pad:Kourou\norbit:GTO", 10, "single line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRule(tt.source)
+
+			var ruleErr *RuleError
+			if !errors.As(err, &ruleErr) {
+				t.Fatalf("Expected a RuleError, got %v", err)
+			}
+
+			if ruleErr.Position != tt.position || !strings.Contains(ruleErr.Message, tt.message) {
+				t.Errorf("Expected \"%s\" at %d, got \"%s\" at %d", tt.message, tt.position, ruleErr.Message, ruleErr.Position)
+			}
+		})
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	launch := LaunchInfo{
+		Name: "Falcon Heavy | USSF-67", ProviderName: "SpaceX", VehicleName: "Falcon Heavy", Family: "Falcon",
+		MissionName: "USSF-67", MissionType: "Government/Top Secret", PadName: "Launch Complex 39A",
+		LocationName: "Kennedy Space Center, FL, USA", Orbit: "GSO", OrbitName: "Geostationary Orbit",
+	}
+
+	tests := []struct {
+		source   string
+		expected bool
+	}{
+		{"provider:spacex", true},
+		{"provider:SpaceX AND NOT mission:Starlink", true},
+		{"provider:SpaceX NOT vehicle:falcon", false},
+		{"vehicle:\"falcon heavy\"", true},
+		{"vehicle:\"falcon 9\" OR vehicle:/electron|neutron/", false},
+		{"vehicle:/^falcon (9|heavy)$/", true},
+		{"(pad:Kourou OR pad:Kennedy) orbit:GSO", true},
+		{"pad:Kourou OR pad:Wenchang orbit:GSO", false},
+		{"NOT (orbit:LEO OR orbit:SSO)", true},
+		{"mission:\"top secret\"", true},
+		{"ussf", true},
+		{"starlink", false},
+	}
+
+	for _, tt := range tests {
+		rule, err := ParseRule(tt.source)
+
+		if err != nil {
+			t.Fatalf("Parsing %s failed: %v", tt.source, err)
+		}
+
+		if rule.Matches(launch) != tt.expected {
+			t.Errorf("Expected %s to match=%v", tt.source, tt.expected)
+		}
+	}
+}
+
+func TestRulePrecedence(t *testing.T) {
+	starlink := LaunchInfo{Id: "1", ProviderId: 121, ProviderName: "SpaceX", Name: "Falcon 9 | Starlink", VehicleName: "Falcon 9", MissionName: "Starlink"}
+	electron := LaunchInfo{Id: "2", ProviderId: 147, ProviderName: "Rocket Lab", Name: "Electron | Test", VehicleName: "Electron", MissionName: "Test"}
+
+	user := User{SubscribedAll: true}
+
+	if _, err := user.AddRule("block", "provider:SpaceX mission:Starlink"); err != nil {
+		t.Fatal(err)
+	}
+
+	if user.ShouldReceiveLaunch(starlink) || !user.ShouldReceiveLaunch(electron) {
+		t.Error("Block rule should only hide Starlink launches")
+	}
+
+	// Block rules win over allow rules
+	user = User{}
+	user.AddRule("allow", "vehicle:falcon OR vehicle:electron")
+	user.AddRule("block", "mission:starlink")
+
+	if user.ShouldReceiveLaunch(starlink) || !user.ShouldReceiveLaunch(electron) {
+		t.Error("Allow rule should include Electron launches, but not blocked Starlink launches")
+	}
+
+	// Blocked keywords win over allow rules
+	user.BlockedKeywords = "Electron"
+
+	if user.ShouldReceiveLaunch(electron) {
+		t.Error("Blocked keyword should win over an allow rule")
+	}
+
+	// Duplicates are rejected, and rules can be removed by index
+	if _, err := user.AddRule("allow", "  vehicle:falcon OR vehicle:electron "); err == nil {
+		t.Error("Duplicate rule should be rejected")
+	}
+
+	if !user.RemoveRule("block", 0) || user.BlockRules != "" || user.RemoveRule("block", 0) {
+		t.Errorf("Removing the block rule failed: %s", user.BlockRules)
+	}
+
+	if len(user.Rules("block")) != 0 || !user.ShouldReceiveLaunch(starlink) {
+		t.Error("Removed rule is still used")
+	}
+
+	// Rules loaded from elsewhere, e.g. the database, are used as they are
+	user.AllowRules = "mission:starlink"
+
+	if rules := user.Rules("allow"); len(rules) != 1 || rules[0].Source != "mission:starlink" {
+		t.Errorf("Expected the changed allow rule, got %v", rules)
+	}
+}
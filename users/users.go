@@ -18,19 +18,37 @@ type User struct {
 	Type                  ChatType
 	Locale                string   // E.g. "Europe/Berlin"
 	Time                  UserTime `gorm:"-:all"`
-	Enabled24h            bool     `gorm:"index:enabled;index:disabled;default:1"`
-	Enabled12h            bool     `gorm:"index:enabled;index:disabled;default:0"`
-	Enabled1h             bool     `gorm:"index:enabled;index:disabled;default:0"`
-	Enabled5min           bool     `gorm:"index:enabled;index:disabled;default:1"`
+	LeadTimes             string   `gorm:"default:1440,5"` // Comma-separated lead times in minutes, e.g. "1440,5" for 24h and 5min
 	EnabledPostpone       bool     `gorm:"index:enabled;index:disabled;default:1"`
+	EnabledOutcome        bool     `gorm:"index:enabled;index:disabled;default:1"`
+	EnabledWebcast        bool     `gorm:"index:enabled;index:disabled;default:0"` // Opt-in: webcast went live
+	EnabledHold           bool     `gorm:"index:enabled;index:disabled;default:1"` // Launch went on hold, or was scrubbed
+	QuietHours            string      // Daily quiet hours in the chat's time zone, e.g. "22:00-07:00" (empty = disabled)
+	QuietPolicy           QuietPolicy // What is done with notifications during quiet hours (hold, summary, drop)
+	DigestFrequency       DigestFrequency // Launch digests: "" (disabled), "daily" or "weekly"
+	DigestHour            int          `gorm:"default:8"` // Local hour digests are delivered at
+	DigestWeekday         time.Weekday `gorm:"default:1"` // Weekday weekly digests are delivered on (0 = Sunday)
+	CalendarToken         string       `gorm:"index"` // Token of the chat's calendar feed URL (empty = no feed)
 	AnyoneCanSendCommands bool     // Group setting to enable non-admins to call commands
 	TopicId               int64   // Optional: forum topic ID for notifications (0 = disabled)
 	SubscribedAll         bool     `gorm:"index:enabled;index:disabled"`
 	SubscribedTo          string   // List of comma-separated LSP IDs
+	CrewedOnly            bool     // Only notify of crewed flights
 	UnsubscribedFrom      string   // List of comma-separated LSP IDs
+	SubscribedLocations   string   // List of comma-separated launch site (pad location) IDs
+	SubscribedVehicles    string   // List of comma-separated rocket configuration IDs
+	SubscribedFamilies    string   // List of comma-separated rocket family names, e.g. "Falcon"
+	BlockRules            string   // Newline-separated filter rules that exclude launches
+	AllowRules            string   // Newline-separated filter rules that include launches
+	OrbitsIncluded        string   // Comma-separated orbits (e.g. "LEO") launches must be to, if any are set
+	OrbitsExcluded        string   // Comma-separated orbits never notified
+	MissionTypesIncluded  string   // Comma-separated mission types (e.g. "Communications") launches must have, if any are set
+	MissionTypesExcluded  string   // Comma-separated mission types never notified
 	MutedLaunches         string   // A comma-separated string of muted launches by ID
 	BlockedKeywords       string   // Comma-separated keywords to exclude from notifications (always overrides subscriptions)
 	AllowedKeywords       string   // Comma-separated keywords to include in notifications (always overrides subscriptions)
+	WebcastLanguages      string   // Comma-separated webcast language codes, in order of preference (e.g. "en,de")
+	WebcastSource         WebcastSource // Preferred webcast source: "" (any), "official" or "third-party"
 	SubscribedNewsletter  bool
 	MigratedFromId        string     // If the chat has been migrated, keep its original id
 	Stats                 stats.User `gorm:"embedded"`
@@ -269,10 +287,67 @@ func (user *User) HasAllowedKeyword(keyword string) bool {
 	return false
 }
 
+// Returns the chat's preferred webcast languages, in order of preference
+func (user *User) PreferredWebcastLanguages() []string {
+	if user.WebcastLanguages == "" {
+		return []string{}
+	}
+
+	return strings.Split(user.WebcastLanguages, ",")
+}
+
+// Returns true if the chat prefers webcasts in this language
+func (user *User) HasWebcastLanguage(code string) bool {
+	for _, language := range user.PreferredWebcastLanguages() {
+		if strings.EqualFold(language, code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Toggles a preferred webcast language. Languages are preferred in the order they were enabled in.
+func (user *User) ToggleWebcastLanguage(code string, newState bool) {
+	languages := []string{}
+
+	for _, language := range user.PreferredWebcastLanguages() {
+		if !strings.EqualFold(language, code) {
+			languages = append(languages, language)
+		}
+	}
+
+	if newState {
+		languages = append(languages, strings.ToLower(code))
+	}
+
+	user.WebcastLanguages = strings.Join(languages, ",")
+}
+
+// Where a chat prefers its webcasts to come from
+type WebcastSource string
+
+const (
+	WebcastAnySource  WebcastSource = ""            // No preference: the launch's main webcast is used
+	WebcastOfficial   WebcastSource = "official"    // The launch provider's own webcast
+	WebcastThirdParty WebcastSource = "third-party" // Streams by others, e.g. commentary streams
+)
+
+// Sets the preferred webcast source, returning false for an unknown source
+func (user *User) SetWebcastSource(source WebcastSource) bool {
+	switch source {
+	case WebcastAnySource, WebcastOfficial, WebcastThirdParty:
+		user.WebcastSource = source
+		return true
+	}
+
+	return false
+}
+
 // Return a bool indicating if user has any notification subscription times enabled
 func (user *User) AnyNotificationTimesEnabled() bool {
-	// Beautiful and concise at only 105 characters 8)
-	return (user.Enabled24h || user.Enabled12h || user.Enabled1h || user.Enabled5min || user.EnabledPostpone)
+	return (user.LeadTimes != "" ||
+		user.EnabledPostpone || user.EnabledOutcome || user.EnabledWebcast || user.EnabledHold)
 }
 
 // Returns a list of integers for all enabled and disabled launch provider IDs
@@ -332,32 +407,257 @@ func (user *User) GetNotificationStatusById(id int) bool {
 	return false
 }
 
-// Check if user should receive a launch notification based on provider and keyword subscriptions
-func (user *User) ShouldReceiveLaunch(launchId string, providerId int, launchName, vehicleName, missionName string) bool {
+// Launch information used to decide if a chat should receive a launch
+type LaunchInfo struct {
+	Id           string
+	ProviderId   int
+	ProviderName string
+	LocationId   int    // Launch site (pad location) ID
+	LocationName string // e.g. "Cape Canaveral, FL, USA"
+	PadName      string
+	VehicleId    int    // Rocket configuration ID
+	Family       string // Rocket family, e.g. "Falcon"
+	Name         string
+	VehicleName  string
+	MissionName  string
+	MissionType  string // e.g. "Communications"
+	Orbit        string // Orbit abbreviation, e.g. "LEO"
+	OrbitName    string // e.g. "Low Earth Orbit"
+}
+
+/*
+Check if user should receive a launch notification based on provider, launch site, vehicle and keyword
+subscriptions, and the chat's filter rules.
+
+Precedence, from strongest to weakest:
+  - a muted launch is never received
+  - a blocked keyword or a matching block rule always excludes the launch
+  - an allowed keyword or a matching allow rule always includes the launch
+  - otherwise, a subscription to the provider, the launch site, the vehicle or its family includes the launch
+*/
+func (user *User) ShouldReceiveLaunch(launch LaunchInfo) bool {
 	// Check if explicitly muted
-	if user.HasMutedLaunch(launchId) {
-		log.Debug().Str("user", user.Id).Str("launch_id", launchId).Msg("Launch explicitly muted")
+	if user.HasMutedLaunch(launch.Id) {
+		log.Debug().Str("user", user.Id).Str("launch_id", launch.Id).Msg("Launch explicitly muted")
 		return false
 	}
 
 	// Build search text for keyword matching (launch name + vehicle name)
-	searchText := strings.ToLower(launchName + " " + vehicleName)
+	searchText := strings.ToLower(launch.Name + " " + launch.VehicleName)
 
 	// Check blocked keywords first (always exclude)
 	if user.matchesBlockedKeywords(searchText) {
-		log.Debug().Str("user", user.Id).Str("launch_id", launchId).Str("launch_name", launchName).Msg("Launch blocked by keyword filter")
+		log.Debug().Str("user", user.Id).Str("launch_id", launch.Id).Str("launch_name", launch.Name).Msg("Launch blocked by keyword filter")
+		return false
+	}
+
+	// Block rules are equivalent to blocked keywords
+	if rule := matchingRule(user.Rules("block"), launch); rule != nil {
+		log.Debug().Str("user", user.Id).Str("launch_id", launch.Id).Str("rule", rule.Source).Msg("Launch blocked by filter rule")
 		return false
 	}
 
 	// Check allowed keywords (always include if matched)
 	if user.matchesAllowedKeywords(searchText) {
-		log.Debug().Str("user", user.Id).Str("launch_id", launchId).Str("launch_name", launchName).Msg("Launch allowed by keyword filter")
+		log.Debug().Str("user", user.Id).Str("launch_id", launch.Id).Str("launch_name", launch.Name).Msg("Launch allowed by keyword filter")
+		return true
+	}
+
+	// Allow rules are equivalent to allowed keywords
+	if rule := matchingRule(user.Rules("allow"), launch); rule != nil {
+		log.Debug().Str("user", user.Id).Str("launch_id", launch.Id).Str("rule", rule.Source).Msg("Launch allowed by filter rule")
 		return true
 	}
 
 	// Otherwise, use normal provider subscription logic
-	subscribed := user.GetNotificationStatusById(providerId)
-	return subscribed
+	if user.GetNotificationStatusById(launch.ProviderId) {
+		return true
+	}
+
+	// Chats can also follow launch sites and vehicles, regardless of the provider
+	return user.FollowsSiteOrVehicle(launch)
+}
+
+// Returns true if the chat follows the launch's site, vehicle or rocket family
+func (user *User) FollowsSiteOrVehicle(launch LaunchInfo) bool {
+	return user.IsSubscribedToLocation(launch.LocationId) || user.IsSubscribedToVehicle(launch.VehicleId, launch.Family)
+}
+
+// Returns true if the chat has any launch site, vehicle or rocket family subscriptions
+func (user *User) HasSiteOrVehicleSubscriptions() bool {
+	return user.SubscribedLocations != "" || user.SubscribedVehicles != "" || user.SubscribedFamilies != ""
+}
+
+// Get user's subscription status by launch site (pad location) ID
+func (user *User) IsSubscribedToLocation(id int) bool {
+	return id != 0 && listContains(user.SubscribedLocations, fmt.Sprint(id))
+}
+
+// Toggle subscription status for a launch site (pad location) ID
+func (user *User) ToggleLocationSubscription(id int, newState bool) {
+	user.SubscribedLocations = listToggle(user.SubscribedLocations, fmt.Sprint(id), newState)
+}
+
+// Get user's subscription status by rocket configuration ID, or by the rocket's family
+func (user *User) IsSubscribedToVehicle(id int, family string) bool {
+	if id != 0 && listContains(user.SubscribedVehicles, fmt.Sprint(id)) {
+		return true
+	}
+
+	return family != "" && user.IsSubscribedToFamily(family)
+}
+
+// Get user's subscription status by rocket family name
+func (user *User) IsSubscribedToFamily(family string) bool {
+	return listContainsFold(user.SubscribedFamilies, family)
+}
+
+// Toggle subscription status for a rocket configuration ID
+func (user *User) ToggleVehicleSubscription(id int, newState bool) {
+	user.SubscribedVehicles = listToggle(user.SubscribedVehicles, fmt.Sprint(id), newState)
+}
+
+// Toggle subscription status for a rocket family
+func (user *User) ToggleFamilySubscription(family string, newState bool) {
+	user.SubscribedFamilies = listToggle(user.SubscribedFamilies, family, newState)
+}
+
+// State of an orbit or mission type filter value
+type FilterState int
+
+const (
+	FilterNone    FilterState = iota // Value is not filtered
+	FilterInclude                    // Launches must match one of the included values
+	FilterExclude                    // Launches matching the value are never notified
+)
+
+// Returns the include and exclude lists of a mission filter kind, "orbit" or "type"
+func (user *User) missionFilterLists(kind string) (*string, *string) {
+	switch kind {
+	case "orbit":
+		return &user.OrbitsIncluded, &user.OrbitsExcluded
+	case "type":
+		return &user.MissionTypesIncluded, &user.MissionTypesExcluded
+	}
+
+	log.Warn().Msgf("Unknown mission filter kind=%s", kind)
+	return new(string), new(string)
+}
+
+// Returns the filter state of an orbit or mission type value
+func (user *User) MissionFilterState(kind string, value string) FilterState {
+	included, excluded := user.missionFilterLists(kind)
+
+	switch {
+	case listContainsFold(*included, value):
+		return FilterInclude
+	case listContainsFold(*excluded, value):
+		return FilterExclude
+	}
+
+	return FilterNone
+}
+
+// Cycles the filter state of an orbit or mission type value: none -> include -> exclude -> none.
+// Returns the new state.
+func (user *User) CycleMissionFilter(kind string, value string) FilterState {
+	newState := (user.MissionFilterState(kind, value) + 1) % 3
+	included, excluded := user.missionFilterLists(kind)
+
+	*included = listToggle(*included, value, newState == FilterInclude)
+	*excluded = listToggle(*excluded, value, newState == FilterExclude)
+
+	return newState
+}
+
+// Returns all orbit or mission type values the chat filters by, included and excluded
+func (user *User) MissionFilterValues(kind string) []string {
+	values := []string{}
+	included, excluded := user.missionFilterLists(kind)
+
+	for _, list := range []string{*included, *excluded} {
+		if list != "" {
+			values = append(values, strings.Split(list, ",")...)
+		}
+	}
+
+	return values
+}
+
+// Clears all orbit and mission type filters
+func (user *User) ClearMissionFilters() {
+	user.OrbitsIncluded, user.OrbitsExcluded = "", ""
+	user.MissionTypesIncluded, user.MissionTypesExcluded = "", ""
+}
+
+// Returns true if the chat has any orbit or mission type filters
+func (user *User) HasMissionFilters() bool {
+	return user.OrbitsIncluded != "" || user.OrbitsExcluded != "" ||
+		user.MissionTypesIncluded != "" || user.MissionTypesExcluded != ""
+}
+
+/*
+Checks a launch's orbit and mission type against the chat's filters. Excluded values
+always filter the launch out. If any values are included, the launch must match one
+of them: launches with an unknown orbit or type don't match any included value.
+*/
+func (user *User) PassesMissionFilters(orbit string, missionType string) bool {
+	checks := []struct {
+		included, excluded string
+		value              string
+	}{
+		{user.OrbitsIncluded, user.OrbitsExcluded, orbit},
+		{user.MissionTypesIncluded, user.MissionTypesExcluded, missionType},
+	}
+
+	for _, check := range checks {
+		if check.value != "" && listContainsFold(check.excluded, check.value) {
+			return false
+		}
+
+		if check.included != "" && !listContainsFold(check.included, check.value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Checks if a comma-separated list contains a value, ignoring case
+func listContainsFold(list string, value string) bool {
+	return listContains(strings.ToLower(list), strings.ToLower(value))
+}
+
+// Checks if a comma-separated list contains a value
+func listContains(list string, value string) bool {
+	if list == "" {
+		return false
+	}
+
+	for _, item := range strings.Split(list, ",") {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Adds or removes a value from a comma-separated list, returning the updated list
+func listToggle(list string, value string, newState bool) string {
+	items := []string{}
+
+	for _, item := range strings.Split(list, ",") {
+		if item != "" && !strings.EqualFold(item, value) {
+			items = append(items, item)
+		}
+	}
+
+	if newState {
+		items = append(items, value)
+	}
+
+	return strings.Join(items, ",")
 }
 
 // Check if text matches any blocked keywords
@@ -402,37 +702,44 @@ func (user *User) SetAllFlag(newState bool) {
 	user.UnsubscribedFrom = ""
 }
 
-// Toggle a single notification-time subscription status
+// Toggle a single notification-time subscription status. The flag is either a
+// lead time's notification type (e.g. "24h"), or one of the event notification types.
 func (user *User) SetNotificationTimeFlag(flagName string, newState bool) {
 	switch flagName {
-	case "24h":
-		user.Enabled24h = newState
-	case "12h":
-		user.Enabled12h = newState
-	case "1h":
-		user.Enabled1h = newState
-	case "5min":
-		user.Enabled5min = newState
 	case "postpone":
 		user.EnabledPostpone = newState
+	case "outcome":
+		user.EnabledOutcome = newState
+	case "webcast":
+		user.EnabledWebcast = newState
+	case "hold":
+		user.EnabledHold = newState
 	default:
-		log.Warn().Msgf("Invalid flag in SetNotificationTimeFlag: %s", flagName)
+		leadTime, ok := LeadTimeOfType(flagName)
+
+		if !ok {
+			log.Warn().Msgf("Invalid flag in SetNotificationTimeFlag: %s", flagName)
+			return
+		}
+
+		if err := user.SetLeadTime(leadTime, newState); err != nil {
+			log.Debug().Err(err).Msgf("Enabling lead time=%s failed for chat=%s", flagName, user.Id)
+			return
+		}
 	}
 
-	// Disable postpone notifications if user disables all other notification types
-	// User can still explicitly enable only postpone notifications.
-	if !user.Enabled24h && !user.Enabled12h && !user.Enabled1h && !user.Enabled5min {
-		if flagName != "postpone" {
+	// Disable postpone, outcome, webcast and hold notifications if user disables all other notification types
+	// User can still explicitly enable only postpone, outcome, webcast or hold notifications.
+	if user.LeadTimes == "" {
+		if flagName != "postpone" && flagName != "outcome" && flagName != "webcast" && flagName != "hold" {
 			user.EnabledPostpone = false
+			user.EnabledOutcome = false
+			user.EnabledWebcast = false
+			user.EnabledHold = false
 		}
 	}
 }
 
-// Has user subscribed to all notification times?
-func (user *User) AllNotificationTimesEnabled() bool {
-	return user.Enabled24h && user.Enabled12h && user.Enabled1h && user.Enabled5min && user.EnabledPostpone
-}
-
 // Toggle subscription status for a list of launch provider IDs
 func (user *User) ToggleIdSubscription(ids []string, newState bool) {
 	// Load notification states as a map of id:bool for easy updates
@@ -559,13 +866,3 @@ func (user *User) SavedTimeZoneInfo() string {
 
 	return fmt.Sprintf("%s (%s)", user.Locale, user.Time.UtcOffset)
 }
-
-// Load user's notification time preferences into a map
-func (user *User) NotificationTimePreferenceMap() map[string]bool {
-	return map[string]bool{
-		"24h":  user.Enabled24h,
-		"12h":  user.Enabled12h,
-		"1h":   user.Enabled1h,
-		"5min": user.Enabled5min,
-	}
-}
@@ -219,6 +219,9 @@ func TestShouldReceiveLaunch(t *testing.T) {
 		user          User
 		launchId      string
 		providerId    int
+		locationId    int
+		vehicleId     int
+		family        string
 		launchName    string
 		vehicleName   string
 		missionName   string
@@ -345,14 +348,134 @@ func TestShouldReceiveLaunch(t *testing.T) {
 			missionName:   "Communications",
 			expectedResult: false,
 		},
+		// Test launch site subscription without a provider subscription
+		{
+			name:          "Subscribed to launch site",
+			user:          User{SubscribedLocations: "12,27"},
+			launchId:      "launch-321",
+			providerId:    99,
+			locationId:    27,
+			launchName:    "Starlink Mission",
+			vehicleName:   "Falcon 9",
+			missionName:   "Communications",
+			expectedResult: true,
+		},
+		// Test launch site IDs are matched exactly
+		{
+			name:          "Launch site IDs match exactly",
+			user:          User{SubscribedLocations: "127"},
+			launchId:      "launch-321",
+			providerId:    99,
+			locationId:    27,
+			launchName:    "Starlink Mission",
+			vehicleName:   "Falcon 9",
+			missionName:   "Communications",
+			expectedResult: false,
+		},
+		// Test vehicle subscription without a provider subscription
+		{
+			name:          "Subscribed to vehicle",
+			user:          User{SubscribedVehicles: "161"},
+			launchId:      "launch-654",
+			providerId:    121,
+			vehicleId:     161,
+			family:        "Falcon",
+			launchName:    "Falcon Heavy | USSF-67",
+			vehicleName:   "Falcon Heavy",
+			missionName:   "USSF-67",
+			expectedResult: true,
+		},
+		// Test other vehicles of a provider are not included by a vehicle subscription
+		{
+			name:          "Vehicle subscription does not include other vehicles",
+			user:          User{SubscribedVehicles: "161"},
+			launchId:      "launch-655",
+			providerId:    121,
+			vehicleId:     164,
+			family:        "Falcon",
+			launchName:    "Falcon 9 | Starlink",
+			vehicleName:   "Falcon 9",
+			missionName:   "Starlink",
+			expectedResult: false,
+		},
+		// Test rocket family subscription, matched case-insensitively
+		{
+			name:          "Subscribed to rocket family",
+			user:          User{SubscribedFamilies: "Electron,falcon"},
+			launchId:      "launch-655",
+			providerId:    121,
+			vehicleId:     164,
+			family:        "Falcon",
+			launchName:    "Falcon 9 | Starlink",
+			vehicleName:   "Falcon 9",
+			missionName:   "Starlink",
+			expectedResult: true,
+		},
+		// Test blocked keyword takes precedence over a launch site subscription
+		{
+			name:          "Blocked keyword overrides launch site",
+			user:          User{SubscribedLocations: "27", BlockedKeywords: "Starlink"},
+			launchId:      "launch-321",
+			providerId:    99,
+			locationId:    27,
+			launchName:    "Starlink Mission",
+			vehicleName:   "Falcon 9",
+			missionName:   "Communications",
+			expectedResult: false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := tt.user.ShouldReceiveLaunch(tt.launchId, tt.providerId, tt.launchName, tt.vehicleName, tt.missionName)
+			result := tt.user.ShouldReceiveLaunch(LaunchInfo{
+				Id: tt.launchId, ProviderId: tt.providerId, LocationId: tt.locationId, VehicleId: tt.vehicleId,
+				Family: tt.family, Name: tt.launchName, VehicleName: tt.vehicleName, MissionName: tt.missionName,
+			})
 			if result != tt.expectedResult {
 				t.Errorf("Expected %v, got %v", tt.expectedResult, result)
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestMissionFilters(t *testing.T) {
+	user := User{}
+
+	if !user.PassesMissionFilters("GTO", "Communications") {
+		t.Error("Launches should pass without filters")
+	}
+
+	// None -> include -> exclude -> none
+	if state := user.CycleMissionFilter("orbit", "LEO"); state != FilterInclude {
+		t.Errorf("Expected LEO to be included, got %d", state)
+	}
+
+	if !user.PassesMissionFilters("leo", "Communications") || user.PassesMissionFilters("GTO", "Communications") {
+		t.Error("Only LEO launches should pass with LEO included")
+	}
+
+	if user.PassesMissionFilters("", "Communications") {
+		t.Error("Launches with an unknown orbit should not pass an include filter")
+	}
+
+	user.CycleMissionFilter("orbit", "SSO")
+	user.CycleMissionFilter("type", "Test Flight")
+	user.CycleMissionFilter("type", "Test Flight")
+
+	if user.MissionFilterState("type", "Test Flight") != FilterExclude || user.MissionTypesIncluded != "" {
+		t.Errorf("Test Flight should be excluded: included=%s, excluded=%s", user.MissionTypesIncluded, user.MissionTypesExcluded)
+	}
+
+	if !user.PassesMissionFilters("SSO", "Earth Science") || user.PassesMissionFilters("SSO", "Test Flight") {
+		t.Error("Excluded mission types should not pass")
+	}
+
+	if state := user.CycleMissionFilter("type", "Test Flight"); state != FilterNone || user.MissionTypesExcluded != "" {
+		t.Errorf("Test Flight should no longer be filtered, got state=%d", state)
+	}
+
+	user.ClearMissionFilters()
+
+	if user.HasMissionFilters() {
+		t.Error("Filters should be cleared")
+	}
+}
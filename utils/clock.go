@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of the current time for timing decisions, such as when
+// notifications are sent. Swapping it out allows simulating time in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock reads the system time
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock is a clock that only moves when told to
+type FakeClock struct {
+	now   time.Time
+	Mutex sync.Mutex
+}
+
+// Creates a new fake clock, stopped at the given time
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (clock *FakeClock) Now() time.Time {
+	clock.Mutex.Lock()
+	defer clock.Mutex.Unlock()
+
+	return clock.now
+}
+
+// Moves the clock forwards (or backwards, if negative) by a duration
+func (clock *FakeClock) Advance(duration time.Duration) {
+	clock.Mutex.Lock()
+	defer clock.Mutex.Unlock()
+
+	clock.now = clock.now.Add(duration)
+}
+
+// Sets the clock to a specific time
+func (clock *FakeClock) Set(now time.Time) {
+	clock.Mutex.Lock()
+	defer clock.Mutex.Unlock()
+
+	clock.now = now
+}
@@ -0,0 +1,377 @@
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"launchbot/db"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+/*
+The REST API is a read-only view of the launches the bot knows about, so other
+services can use the bot's data instead of polling LL2 themselves. Upcoming launches
+are served from the launch cache, and single launches from the cache or the database.
+
+Every request must carry one of the API keys in the config, either as a bearer token
+or in the X-API-Key header. Responses carry an ETag: clients polling the API with
+If-None-Match get an empty 304 response while the data has not changed.
+*/
+
+// A launch, as returned by the API
+type launchResponse struct {
+	Id          string           `json:"id"`
+	Slug        string           `json:"slug"`
+	Name        string           `json:"name"`
+	Status      statusResponse   `json:"status"`
+	NET         string           `json:"net"`
+	NETUnix     int64            `json:"net_unix"`
+	WindowStart string           `json:"window_start"`
+	WindowEnd   string           `json:"window_end"`
+	Probability int              `json:"probability"`
+	HoldReason  string           `json:"hold_reason,omitempty"`
+	FailReason  string           `json:"fail_reason,omitempty"`
+	Provider    providerResponse `json:"provider"`
+	Rocket      rocketResponse   `json:"rocket"`
+	Mission     missionResponse  `json:"mission"`
+	Pad         padResponse      `json:"pad"`
+	WebcastLive bool             `json:"webcast_live"`
+	WebcastURL  string           `json:"webcast_url,omitempty"`
+	Launched    bool             `json:"launched"`
+	LastUpdated string           `json:"last_updated"`
+}
+
+type statusResponse struct {
+	Id     int    `json:"id"`
+	Name   string `json:"name"`
+	Abbrev string `json:"abbrev"`
+}
+
+type providerResponse struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Abbrev      string `json:"abbrev"`
+	Type        string `json:"type"`
+	CountryCode string `json:"country_code"`
+}
+
+type rocketResponse struct {
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+}
+
+type missionResponse struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Orbit       string `json:"orbit"`
+	Description string `json:"description"`
+}
+
+type padResponse struct {
+	Name        string `json:"name"`
+	LocationId  int    `json:"location_id"`
+	Location    string `json:"location"`
+	CountryCode string `json:"country_code"`
+}
+
+// A change detected in a launch, as returned by the API
+type changeResponse struct {
+	Type       db.ChangeType `json:"type"`
+	Old        string        `json:"old"`
+	New        string        `json:"new"`
+	DetectedAt time.Time     `json:"detected_at"`
+}
+
+// An error, as returned by the API
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Converts a launch into its API representation
+func newLaunchResponse(launch *db.Launch) launchResponse {
+	return launchResponse{
+		Id:          launch.Id,
+		Slug:        launch.Slug,
+		Name:        launch.Name,
+		Status:      statusResponse{Id: launch.Status.Id, Name: launch.Status.Name, Abbrev: launch.Status.Abbrev},
+		NET:         launch.NET,
+		NETUnix:     launch.NETUnix,
+		WindowStart: launch.WindowStart,
+		WindowEnd:   launch.WindowEnd,
+		Probability: launch.Probability,
+		HoldReason:  launch.HoldReason,
+		FailReason:  launch.FailReason,
+		Provider: providerResponse{
+			Id: launch.LaunchProvider.Id, Name: launch.LaunchProvider.Name, Abbrev: launch.LaunchProvider.Abbrev,
+			Type: launch.LaunchProvider.Type, CountryCode: launch.LaunchProvider.CountryCode,
+		},
+		Rocket: rocketResponse{Name: launch.Rocket.Config.Name, FullName: launch.Rocket.Config.FullName},
+		Mission: missionResponse{
+			Name: launch.Mission.Name, Type: launch.Mission.Type,
+			Orbit: launch.Mission.Orbit.Name, Description: launch.Mission.Description,
+		},
+		Pad: padResponse{
+			Name: launch.LaunchPad.Name, LocationId: launch.LaunchPad.Location.Id,
+			Location: launch.LaunchPad.Location.Name, CountryCode: launch.LaunchPad.Location.CountryCode,
+		},
+		WebcastLive: launch.WebcastIsLive,
+		WebcastURL:  launch.WebcastLink,
+		Launched:    launch.Launched,
+		LastUpdated: launch.LastUpdated,
+	}
+}
+
+// Filters of the upcoming launches, from the request's query
+type launchFilter struct {
+	provider string    // Provider id, name or abbreviation
+	pad      string    // Launch site id, or a part of the pad's or the site's name
+	from     time.Time // Earliest NET
+	to       time.Time // Latest NET
+	limit    int       // Maximum amount of launches (0 = no limit)
+}
+
+// Parses the filters of a request, e.g. ?provider=SpaceX&pad=Kourou&from=2022-10-01T00:00:00Z&limit=5
+func parseLaunchFilter(r *http.Request) (launchFilter, error) {
+	query := r.URL.Query()
+	filter := launchFilter{provider: query.Get("provider"), pad: query.Get("pad")}
+
+	// Times may be given as RFC 3339 timestamps, or as unix seconds
+	parseTime := func(key string) (time.Time, error) {
+		value := query.Get(key)
+
+		if value == "" {
+			return time.Time{}, nil
+		}
+
+		if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Unix(unix, 0), nil
+		}
+
+		parsed, err := time.Parse(time.RFC3339, value)
+
+		if err != nil {
+			return time.Time{}, errors.New(key + " must be an RFC 3339 timestamp or unix seconds")
+		}
+
+		return parsed, nil
+	}
+
+	var err error
+
+	if filter.from, err = parseTime("from"); err != nil {
+		return filter, err
+	}
+
+	if filter.to, err = parseTime("to"); err != nil {
+		return filter, err
+	}
+
+	if value := query.Get("limit"); value != "" {
+		if filter.limit, err = strconv.Atoi(value); err != nil || filter.limit < 0 {
+			return filter, errors.New("limit must be a non-negative integer")
+		}
+	}
+
+	return filter, nil
+}
+
+// Returns true if the launch passes the filter
+func (filter *launchFilter) matches(launch *db.Launch) bool {
+	if filter.provider != "" {
+		provider := launch.LaunchProvider
+
+		if filter.provider != strconv.Itoa(provider.Id) &&
+			!strings.EqualFold(filter.provider, provider.Name) && !strings.EqualFold(filter.provider, provider.Abbrev) {
+			return false
+		}
+	}
+
+	if filter.pad != "" {
+		pad := strings.ToLower(filter.pad)
+
+		if pad != strconv.Itoa(launch.LaunchPad.Location.Id) &&
+			!strings.Contains(strings.ToLower(launch.LaunchPad.Name), pad) &&
+			!strings.Contains(strings.ToLower(launch.LaunchPad.Location.Name), pad) {
+			return false
+		}
+	}
+
+	if !filter.from.IsZero() && launch.NETUnix < filter.from.Unix() {
+		return false
+	}
+
+	return filter.to.IsZero() || launch.NETUnix <= filter.to.Unix()
+}
+
+// Serves the upcoming launches, from the launch cache
+func (server *Server) upcomingLaunches(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseLaunchFilter(r)
+
+	if err != nil {
+		writeJSON(w, r, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	launches := []launchResponse{}
+
+	// The responses are a snapshot of the cache, taken under its lock
+	server.session.Cache.Mutex.Lock()
+
+	for _, launch := range server.session.Cache.Launches {
+		if launch.Launched || !filter.matches(launch) {
+			continue
+		}
+
+		launches = append(launches, newLaunchResponse(launch))
+	}
+
+	server.session.Cache.Mutex.Unlock()
+
+	sort.SliceStable(launches, func(i, j int) bool {
+		return launches[i].NETUnix < launches[j].NETUnix
+	})
+
+	if filter.limit != 0 && len(launches) > filter.limit {
+		launches = launches[:filter.limit]
+	}
+
+	writeJSON(w, r, http.StatusOK, launches)
+}
+
+// Serves a single launch, from the launch cache or the database
+func (server *Server) launchById(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	// Only the cache lookup is done under the lock: the database is read after it
+	server.session.Cache.Mutex.Lock()
+	launch, cached := server.session.Cache.LaunchMap[id]
+
+	var response launchResponse
+
+	if cached {
+		response = newLaunchResponse(launch)
+	}
+
+	server.session.Cache.Mutex.Unlock()
+
+	if !cached {
+		launch, err := server.session.Db.LoadLaunch(id)
+
+		if err != nil {
+			writeJSON(w, r, http.StatusNotFound, errorResponse{Error: "launch not found"})
+			return
+		}
+
+		response = newLaunchResponse(launch)
+	}
+
+	writeJSON(w, r, http.StatusOK, response)
+}
+
+// Serves the changes detected in a launch, oldest first
+func (server *Server) launchHistory(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	server.session.Cache.Mutex.Lock()
+	_, cached := server.session.Cache.LaunchMap[id]
+	server.session.Cache.Mutex.Unlock()
+
+	if !cached {
+		if _, err := server.session.Db.LoadLaunch(id); err != nil {
+			writeJSON(w, r, http.StatusNotFound, errorResponse{Error: "launch not found"})
+			return
+		}
+	}
+
+	events, err := server.session.Db.ChangeEvents(id)
+
+	if err != nil {
+		log.Error().Err(err).Msgf("Loading the history of launch=%s failed", id)
+		writeJSON(w, r, http.StatusInternalServerError, errorResponse{Error: "loading the launch's history failed"})
+		return
+	}
+
+	changes := make([]changeResponse, 0, len(events))
+
+	for _, event := range events {
+		changes = append(changes, changeResponse{
+			Type: event.Type, Old: event.Old, New: event.New, DetectedAt: event.DetectedAt.UTC(),
+		})
+	}
+
+	writeJSON(w, r, http.StatusOK, changes)
+}
+
+// Wraps an API handler, rejecting requests without a valid API key
+func (server *Server) requireApiKey(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("X-API-Key")
+
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			key = bearer
+		}
+
+		for _, valid := range server.session.Config.HttpServer.ApiKeys {
+			if key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(valid)) == 1 {
+				handler(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer realm="launchbot"`)
+		writeJSON(w, r, http.StatusUnauthorized, errorResponse{Error: "a valid API key is required"})
+	}
+}
+
+// Writes a JSON response with an ETag, or an empty 304 response if the client already has it
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, value interface{}) {
+	body, err := json.Marshal(value)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Encoding an API response failed")
+		http.Error(w, "encoding the response failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+		return
+	}
+
+	hash := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	_, _ = w.Write(body)
+}
+
+// Returns true if an If-None-Match header matches the ETag
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"encoding/json"
+	"launchbot/config"
+	"launchbot/db"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Creates a server over a cache of launches, accepting the API key "secret"
+func newTestServer(launches ...*db.Launch) *httptest.Server {
+	cache := &db.Cache{Launches: launches, LaunchMap: make(map[string]*db.Launch)}
+
+	for _, launch := range launches {
+		cache.LaunchMap[launch.Id] = launch
+	}
+
+	session := &config.Session{
+		Config: &config.Config{HttpServer: config.HttpServer{Enabled: true, ApiKeys: []string{"secret"}}},
+		Cache:  cache,
+	}
+
+	return httptest.NewServer(NewServer(session).Handler())
+}
+
+// Sends a GET request with the headers, returning the response and the decoded launches
+func get(t *testing.T, url string, headers map[string]string) (*http.Response, []launchResponse) {
+	request, _ := http.NewRequest(http.MethodGet, url, nil)
+
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer response.Body.Close()
+
+	launches := []launchResponse{}
+	_ = json.NewDecoder(response.Body).Decode(&launches)
+
+	return response, launches
+}
+
+func TestUpcomingLaunches(t *testing.T) {
+	now := time.Now()
+
+	spacex := db.LaunchProvider{Id: 121, Name: "SpaceX", Abbrev: "SpX"}
+	rocketLab := db.LaunchProvider{Id: 147, Name: "Rocket Lab", Abbrev: "RL"}
+	florida := db.LaunchPad{Name: "Space Launch Complex 40", Location: db.PadLocation{Id: 12, Name: "Cape Canaveral, FL, USA"}}
+	mahia := db.LaunchPad{Name: "Rocket Lab Launch Complex 1A", Location: db.PadLocation{Id: 10, Name: "Onenui Station, Mahia Peninsula, New Zealand"}}
+
+	server := newTestServer(
+		&db.Launch{Id: "late", LaunchProvider: spacex, LaunchPad: florida, NETUnix: now.Add(72 * time.Hour).Unix()},
+		&db.Launch{Id: "early", LaunchProvider: spacex, LaunchPad: florida, NETUnix: now.Add(24 * time.Hour).Unix()},
+		&db.Launch{Id: "electron", LaunchProvider: rocketLab, LaunchPad: mahia, NETUnix: now.Add(48 * time.Hour).Unix()},
+		&db.Launch{Id: "launched", LaunchProvider: spacex, LaunchPad: florida, NETUnix: now.Add(-time.Hour).Unix(), Launched: true},
+	)
+
+	defer server.Close()
+
+	auth := map[string]string{"Authorization": "Bearer secret"}
+
+	// Requests without a valid key are rejected
+	for _, headers := range []map[string]string{nil, {"X-API-Key": "wrong"}} {
+		if response, _ := get(t, server.URL+"/launches/upcoming", headers); response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected a request with headers %v to be rejected, got %d", headers, response.StatusCode)
+		}
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"early", "electron", "late"}},
+		{"?provider=spx", []string{"early", "late"}},
+		{"?provider=147", []string{"electron"}},
+		{"?pad=mahia", []string{"electron"}},
+		{"?pad=12&limit=1", []string{"early"}},
+		{"?from=" + now.Add(36*time.Hour).UTC().Format(time.RFC3339), []string{"electron", "late"}},
+		{"?provider=SpaceX&to=" + now.Add(36*time.Hour).Format("2006-01-02T15:04:05Z07:00"), []string{"early"}},
+	}
+
+	for _, tt := range tests {
+		response, launches := get(t, server.URL+"/launches/upcoming"+tt.query, auth)
+		ids := []string{}
+
+		for _, launch := range launches {
+			ids = append(ids, launch.Id)
+		}
+
+		if response.StatusCode != http.StatusOK || len(ids) != len(tt.expected) {
+			t.Errorf("query %q: expected %v, got %d %v", tt.query, tt.expected, response.StatusCode, ids)
+			continue
+		}
+
+		for i := range ids {
+			if ids[i] != tt.expected[i] {
+				t.Errorf("query %q: expected %v, got %v", tt.query, tt.expected, ids)
+				break
+			}
+		}
+	}
+
+	if response, _ := get(t, server.URL+"/launches/upcoming?from=tomorrow", auth); response.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected an invalid time to be rejected, got %d", response.StatusCode)
+	}
+}
+
+func TestLaunchETag(t *testing.T) {
+	launch := &db.Launch{Id: "etag", Name: "Falcon 9 | Starlink", NETUnix: time.Now().Add(time.Hour).Unix()}
+	server := newTestServer(launch)
+
+	defer server.Close()
+
+	first, _ := get(t, server.URL+"/launches/etag", map[string]string{"X-API-Key": "secret"})
+	etag := first.Header.Get("ETag")
+
+	if first.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("expected the launch with an ETag, got %d (ETag=%q)", first.StatusCode, etag)
+	}
+
+	// The client's copy is still current
+	cached, _ := get(t, server.URL+"/launches/etag", map[string]string{"X-API-Key": "secret", "If-None-Match": etag})
+
+	if cached.StatusCode != http.StatusNotModified {
+		t.Errorf("expected 304 for an unchanged launch, got %d", cached.StatusCode)
+	}
+
+	// The launch changes: the client's copy is stale
+	launch.Name = "Falcon 9 | Starlink Group 4-1"
+	changed, _ := get(t, server.URL+"/launches/etag", map[string]string{"X-API-Key": "secret", "If-None-Match": etag})
+
+	if changed.StatusCode != http.StatusOK || changed.Header.Get("ETag") == etag {
+		t.Errorf("expected a new ETag for a changed launch, got %d (ETag=%q)", changed.StatusCode, changed.Header.Get("ETag"))
+	}
+}
@@ -0,0 +1,91 @@
+// Package web is LaunchBot's embedded HTTP server, serving the chats' calendar feeds and a read-only REST API.
+package web
+
+import (
+	"errors"
+	"launchbot/config"
+	"launchbot/db"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Server serves the session's data over HTTP
+type Server struct {
+	session *config.Session
+	http    *http.Server
+}
+
+// Creates a new server for the session. The server is started with Start.
+func NewServer(session *config.Session) *Server {
+	server := &Server{session: session}
+
+	server.http = &http.Server{
+		Addr:              session.Config.HttpServer.Address,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+
+	return server
+}
+
+// Returns the server's routes
+func (server *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /calendar/{token}", server.calendarFeed)
+
+	// The REST API is only available if API keys are configured
+	if len(server.session.Config.HttpServer.ApiKeys) != 0 {
+		mux.HandleFunc("GET /launches/upcoming", server.requireApiKey(server.upcomingLaunches))
+		mux.HandleFunc("GET /launches/{id}", server.requireApiKey(server.launchById))
+		mux.HandleFunc("GET /launches/{id}/history", server.requireApiKey(server.launchHistory))
+	}
+
+	return mux
+}
+
+// Starts the server in a go-routine, if it is enabled in the config
+func (server *Server) Start() {
+	if !server.session.Config.HttpServer.Enabled {
+		log.Debug().Msg("HTTP server disabled")
+		return
+	}
+
+	go func() {
+		log.Info().Msgf("HTTP server listening at %s", server.http.Addr)
+
+		if err := server.http.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("HTTP server stopped")
+		}
+	}()
+}
+
+// Serves a chat's calendar feed, at /calendar/<token>.ics
+func (server *Server) calendarFeed(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSuffix(r.PathValue("token"), ".ics")
+	chat, ok := server.session.Db.FindUserByCalendarToken(token)
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The chat's launches are loaded from the cache on every request, so the feed is always current.
+	// Only the cache is read under its lock: the NET change counts are a database query.
+	server.session.Cache.Mutex.Lock()
+	launches := server.session.Cache.LaunchesUserHasSubscribedTo(chat)
+	server.session.Cache.Mutex.Unlock()
+
+	calendar := db.LaunchCalendar(launches, "LaunchBot launches", server.session.Now(), server.session.Db.NetChangeCounts(launches))
+
+	log.Debug().Msgf("Serving calendar feed of chat=%s with %d launch(es)", chat.Id, len(launches))
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="launches.ics"`)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	_, _ = w.Write(calendar)
+}